@@ -6,13 +6,22 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"pirated-hollow-knight/internal/apperr"
 	"pirated-hollow-knight/internal/config"
 	"pirated-hollow-knight/internal/installer"
 	"pirated-hollow-knight/internal/launcher"
 	"pirated-hollow-knight/internal/log"
+	"pirated-hollow-knight/internal/progress"
 )
 
 func main() {
+	// Exit only once run has returned, so that every deferred cleanup has run.
+	os.Exit(run())
+}
+
+// run executes the command and returns the process exit code. Failures are
+// mapped to the exit codes documented in the README.
+func run() int {
 	// 1. Create a context that is cancelled on an interrupt signal.
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
@@ -22,33 +31,90 @@ func main() {
 	if err != nil {
 		// Use a basic logger since the custom one isn't configured yet.
 		fmt.Fprintf(os.Stderr, "[CRITICAL] Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		return exitCode(ctx, err)
 	}
 
-	// 3. Initialize the global logger with the level from the config.
-	log.Init(cfg.LogLevel)
+	// 3. Initialize the global logger: the console level and format, and the log file.
+	if err := log.Init(log.Options{Level: cfg.LogLevel, Format: cfg.LogFormat, File: cfg.LogFile}); err != nil {
+		fmt.Fprintf(os.Stderr, "[CRITICAL] Failed to set up logging: %v\n", err)
+		return exitCode(ctx, apperr.Wrap(apperr.ConfigInvalid, err))
+	}
+	defer log.Close()
+	progressOut := os.Stderr
+	if cfg.ProgressFile != "" {
+		f, err := os.OpenFile(cfg.ProgressFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Log.Critical("Could not open progress file: %v", err)
+			return exitCode(ctx, apperr.Wrap(apperr.ConfigInvalid, err))
+		}
+		defer f.Close()
+		progressOut = f
+	}
+	progress.Init(cfg.Progress, cfg.LogLevel, progressOut)
 
 	// 4. Route to the appropriate command based on the loaded config.
-	if cfg.RunClean {
-		if err := launcher.RunClean(cfg); err != nil {
-			log.Log.Fatal("Clean operation failed: %v", err)
-		}
-	} else {
-		runDefault(ctx, cfg)
+	var action string
+	switch cfg.Command {
+	case "clean":
+		action, err = "Clean operation", launcher.RunClean(cfg)
+	case "status":
+		action, err = "Status", launcher.RunStatus(ctx, cfg)
+	case "snapshots":
+		action, err = "Listing snapshots", launcher.RunSnapshots(ctx, cfg)
+	case "restore":
+		action, err = "Restore", launcher.RunRestore(ctx, cfg)
+	case "verify":
+		action, err = "Verify", launcher.RunVerify(ctx, cfg)
+	case "remote":
+		action, err = "Remote setup", launcher.RunRemote(ctx, cfg)
+	case "saves":
+		action, err = "Saves command", launcher.RunSaves(ctx, cfg)
+	case "practice":
+		action, err = "Listing practice saves", launcher.RunPractice(cfg)
+	case "stats":
+		action, err = "Stats", launcher.RunStats(cfg)
+	case "rclone":
+		action, err = "rclone command", launcher.RunRclone(ctx, cfg)
+	default:
+		action, err = runDefault(ctx, cfg)
+	}
+	if err != nil {
+		log.Log.Critical("%s failed (%s): %v", action, kindOf(ctx, err), err)
 	}
+	return exitCode(ctx, err)
 }
 
-// runDefault executes the main application logic: ensuring dependencies and launching the game.
-func runDefault(ctx context.Context, cfg *config.Config) {
+// runDefault executes the main application logic: ensuring dependencies and
+// launching the game. On failure it also names the step that failed.
+func runDefault(ctx context.Context, cfg *config.Config) (string, error) {
 	log.Log.Info("--- Running Default Mode ---")
 
 	if err := installer.EnsureDependencies(ctx, cfg); err != nil {
-		log.Log.Fatal("Failed to satisfy dependencies: %v", err)
+		return "Satisfying dependencies", err
 	}
 
 	if err := launcher.LaunchGame(ctx, cfg); err != nil {
-		log.Log.Fatal("Game launch failed: %v", err)
+		return "Game launch", err
 	}
 
 	log.Log.Info("--- Script finished ---")
+	return "", nil
+}
+
+// kindOf classifies err. An unclassified failure after an interrupt failed
+// because of it; one that was classified keeps its kind, so that a swap-out
+// that failed on the way out still reports that the saves are behind.
+func kindOf(ctx context.Context, err error) apperr.Kind {
+	kind := apperr.KindOf(err)
+	if kind == apperr.Unknown && ctx.Err() != nil {
+		return apperr.Interrupted
+	}
+	return kind
+}
+
+func exitCode(ctx context.Context, err error) int {
+	if err == nil {
+		return 0
+	}
+	return kindOf(ctx, err).ExitCode()
 }
@@ -0,0 +1,34 @@
+// /cmd/pirated-hollow-knight/main_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"pirated-hollow-knight/internal/apperr"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want int
+	}{
+		{"success", context.Background(), nil, 0},
+		{"unclassified", context.Background(), errors.New("boom"), 1},
+		{"unclassified after an interrupt", cancelled, errors.New("boom"), 130},
+		{"classified after an interrupt", cancelled, apperr.New(apperr.SyncFailed, "saves are behind"), 6},
+		{"success after an interrupt", cancelled, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("exitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// /internal/apperr/apperr.go
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Kind classifies a failure. Each kind ends the process with its own exit
+// code, so that scripts and front-ends can tell failures apart.
+type Kind int
+
+const (
+	// Unknown is any failure that has not been classified.
+	Unknown Kind = iota
+	// ConfigInvalid is a bad flag, target string or command line.
+	ConfigInvalid
+	// LockHeld means another instance of the launcher is running.
+	LockHeld
+	// DependencyMissing means the game or rclone is missing and could not be installed.
+	DependencyMissing
+	// SourceUnavailable means no target could provide the saves to play with.
+	SourceUnavailable
+	// SyncFailed means saves could not be copied back to where they came from.
+	SyncFailed
+	// GameCrashed means the game failed to start or exited with an error.
+	GameCrashed
+	// WrongKey means an encrypted target was opened with the wrong secret.
+	WrongKey
+	// VerifyFailed means a target did not match its integrity manifest.
+	VerifyFailed
+	// Interrupted means the user cancelled the run.
+	Interrupted
+)
+
+// exitCodes are the documented exit codes of each kind. 0 means success.
+var exitCodes = map[Kind]int{
+	Unknown:           1,
+	ConfigInvalid:     2,
+	LockHeld:          3,
+	DependencyMissing: 4,
+	SourceUnavailable: 5,
+	SyncFailed:        6,
+	GameCrashed:       7,
+	WrongKey:          8,
+	VerifyFailed:      9,
+	Interrupted:       130,
+}
+
+func (k Kind) String() string {
+	switch k {
+	case ConfigInvalid:
+		return "config invalid"
+	case LockHeld:
+		return "lock held"
+	case DependencyMissing:
+		return "dependency missing"
+	case SourceUnavailable:
+		return "source unavailable"
+	case SyncFailed:
+		return "sync failed"
+	case GameCrashed:
+		return "game crashed"
+	case WrongKey:
+		return "wrong key"
+	case VerifyFailed:
+		return "verify failed"
+	case Interrupted:
+		return "interrupted"
+	}
+	return "unknown"
+}
+
+// ExitCode returns the process exit code for failures of this kind.
+func (k Kind) ExitCode() int {
+	return exitCodes[k]
+}
+
+// Error is an error with a Kind. Its message is that of the wrapped error.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns a new error of the given kind, for use as a sentinel.
+func New(kind Kind, text string) error {
+	return &Error{Kind: kind, Err: errors.New(text)}
+}
+
+// Errorf formats an error of the given kind like fmt.Errorf.
+func Errorf(kind Kind, format string, a ...any) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, a...)}
+}
+
+// Wrap classifies err as kind. An error that already has a kind keeps it,
+// since the place that first classified it knew the cause best.
+func Wrap(kind Kind, err error) error {
+	if err == nil || KindOf(err) != Unknown {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// KindOf returns the kind of err. Unclassified cancellations count as
+// Interrupted and everything else unclassified as Unknown.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	if errors.Is(err, context.Canceled) {
+		return Interrupted
+	}
+	return Unknown
+}
+
+// ExitCode returns the process exit code for err, 0 if it is nil.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return KindOf(err).ExitCode()
+}
@@ -0,0 +1,55 @@
+// /internal/apperr/apperr_test.go
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+		code int
+	}{
+		{"nil", nil, Unknown, 0},
+		{"plain", errors.New("boom"), Unknown, 1},
+		{"classified", New(ConfigInvalid, "bad flag"), ConfigInvalid, 2},
+		{"wrapped", fmt.Errorf("step: %w", Errorf(SyncFailed, "copy failed")), SyncFailed, 6},
+		{"rewrapped keeps the first kind", Wrap(SourceUnavailable, New(WrongKey, "wrong key")), WrongKey, 8},
+		{"wrapped plain", Wrap(VerifyFailed, errors.New("mismatch")), VerifyFailed, 9},
+		{"cancelled", fmt.Errorf("sync: %w", context.Canceled), Interrupted, 130},
+		{"cancelled but classified", Wrap(SyncFailed, fmt.Errorf("%w: %w", New(GameCrashed, "exit 1"), context.Canceled)), GameCrashed, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err != nil {
+				if got := KindOf(tt.err); got != tt.want {
+					t.Errorf("KindOf = %v, want %v", got, tt.want)
+				}
+			}
+			if got := ExitCode(tt.err); got != tt.code {
+				t.Errorf("ExitCode = %d, want %d", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestWrapNil(t *testing.T) {
+	if err := Wrap(SyncFailed, nil); err != nil {
+		t.Errorf("Wrap(nil) = %v, want nil", err)
+	}
+}
+
+func TestExitCodesDistinct(t *testing.T) {
+	seen := make(map[int]Kind)
+	for kind, code := range exitCodes {
+		if other, dup := seen[code]; dup {
+			t.Errorf("%v and %v share exit code %d", kind, other, code)
+		}
+		seen[code] = kind
+	}
+}
@@ -0,0 +1,140 @@
+// /internal/backup/backends.go
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"slices"
+	"sync"
+	"time"
+)
+
+// modTimeNotSupported is the precision rclone reports for backends that
+// cannot store modification times.
+const modTimeNotSupported = 100 * 365 * 24 * time.Hour
+
+// eventuallyConsistentTypes are backends whose listings may briefly lag
+// behind writes, in addition to every bucket-based backend.
+var eventuallyConsistentTypes = []string{"drive", "onedrive", "swift"}
+
+// RemoteInfo describes the backend behind an rclone remote and what it can do.
+type RemoteInfo struct {
+	Name string
+	// Type is the backend type from the rclone config, e.g. "drive" or "crypt".
+	Type string
+	// Precision is how exactly the backend stores modification times.
+	Precision time.Duration
+	// Hashes are the hash types the backend provides without downloading.
+	Hashes      []string
+	BucketBased bool
+}
+
+// HasModTime reports whether the backend keeps the modification times of
+// uploaded files, rather than replacing them with the upload time.
+func (r RemoteInfo) HasModTime() bool {
+	return r.Precision < modTimeNotSupported
+}
+
+// HasHash reports whether the backend provides the given hash type.
+func (r RemoteInfo) HasHash(name string) bool {
+	return slices.Contains(r.Hashes, name)
+}
+
+// EventuallyConsistent reports whether a listing right after a write may not
+// show it yet.
+func (r RemoteInfo) EventuallyConsistent() bool {
+	return r.BucketBased || slices.Contains(eventuallyConsistentTypes, r.Type)
+}
+
+// rcloneFsInfo is the output of `rclone backend features`.
+type rcloneFsInfo struct {
+	Precision time.Duration
+	Hashes    []string
+	Features  struct {
+		BucketBased bool
+	}
+}
+
+var (
+	remoteInfoMu sync.Mutex
+	remoteInfos  = make(map[string]RemoteInfo)
+)
+
+// GetRemoteInfo returns the backend type and capabilities of the named remote.
+// The answer is cached for the rest of the session.
+func GetRemoteInfo(ctx context.Context, cfg *config.Config, name string) (RemoteInfo, error) {
+	remoteInfoMu.Lock()
+	defer remoteInfoMu.Unlock()
+	if info, ok := remoteInfos[name]; ok {
+		return info, nil
+	}
+
+	var dump map[string]map[string]string
+	if d := activeDaemon(); d != nil {
+		if err := d.call(ctx, "config/dump", map[string]any{}, &dump); err != nil {
+			return RemoteInfo{}, fmt.Errorf("could not read rclone config: %w", err)
+		}
+	} else {
+		out, err := runRcloneOutput(ctx, cfg, "config", "dump")
+		if err != nil {
+			return RemoteInfo{}, fmt.Errorf("could not read rclone config: %w", err)
+		}
+		if err := json.Unmarshal(out, &dump); err != nil {
+			return RemoteInfo{}, fmt.Errorf("could not parse rclone config dump: %w", err)
+		}
+	}
+	section, ok := dump[name]
+	if !ok {
+		return RemoteInfo{}, fmt.Errorf("remote '%s' is not in the rclone config", name)
+	}
+
+	var fsInfo rcloneFsInfo
+	if d := activeDaemon(); d != nil {
+		if err := d.call(ctx, "operations/fsinfo", map[string]any{"fs": name + ":"}, &fsInfo); err != nil {
+			return RemoteInfo{}, fmt.Errorf("could not read features of '%s': %w", name, err)
+		}
+	} else {
+		out, err := runRcloneOutput(ctx, cfg, "backend", "features", name+":")
+		if err != nil {
+			return RemoteInfo{}, fmt.Errorf("could not read features of '%s': %w", name, err)
+		}
+		if err := json.Unmarshal(out, &fsInfo); err != nil {
+			return RemoteInfo{}, fmt.Errorf("could not parse features of '%s': %w", name, err)
+		}
+	}
+
+	info := RemoteInfo{
+		Name:        name,
+		Type:        section["type"],
+		Precision:   fsInfo.Precision,
+		Hashes:      fsInfo.Hashes,
+		BucketBased: fsInfo.Features.BucketBased,
+	}
+	remoteInfos[name] = info
+	return info, nil
+}
+
+// restoreModTimes sets files just copied from a remote that doesn't keep
+// modification times back to the times recorded in its manifest. Otherwise
+// they would carry their upload times and look newer than they are.
+func restoreModTimes(ctx context.Context, cfg *config.Config, remote config.SyncTarget, dir string) error {
+	info, err := GetRemoteInfo(ctx, cfg, remote.RemoteName)
+	if err != nil || info.HasModTime() {
+		return nil
+	}
+	manifest, err := readManifest(ctx, cfg, remote)
+	if err != nil || manifest == nil {
+		return err
+	}
+	for name, mod := range manifest.ModTimes {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.Chtimes(p, mod, mod); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
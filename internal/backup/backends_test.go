@@ -0,0 +1,251 @@
+// /internal/backup/backends_test.go
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rcHandler answers one rc method. Returning an *rcError sends it back as the
+// server would.
+type rcHandler func(params map[string]any) (any, error)
+
+// useFakeDaemon makes a server answering with handlers the session's rclone
+// daemon for the rest of the test. Asynchronous calls finish at once. It
+// returns how often each method was called.
+func useFakeDaemon(t *testing.T, handlers map[string]rcHandler) map[string]int {
+	t.Helper()
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	var jobErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[1:]
+		var params map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		mu.Lock()
+		defer mu.Unlock()
+		calls[method]++
+
+		var result any
+		var err error
+		switch method {
+		case "job/status":
+			result = map[string]any{"finished": true, "success": jobErr == nil, "error": errorText(jobErr)}
+		case "core/stats":
+			result = map[string]any{}
+		default:
+			handler, ok := handlers[method]
+			if !ok {
+				err = &rcError{Path: method, Message: `couldn't find method "` + method + `"`, Status: http.StatusNotFound}
+				break
+			}
+			result, err = handler(params)
+			if async, _ := params["_async"].(bool); async {
+				jobErr, result, err = err, map[string]any{"jobid": 1}, nil
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var rcErr *rcError
+		if errors.As(err, &rcErr) {
+			w.WriteHeader(rcErr.Status)
+			result = rcErr
+		} else if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			result = map[string]any{"error": err.Error(), "path": method, "status": http.StatusInternalServerError}
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+
+	daemonMu.Lock()
+	daemon = &rcloneDaemon{url: srv.URL + "/", client: srv.Client()}
+	daemonMu.Unlock()
+	t.Cleanup(func() {
+		daemonMu.Lock()
+		daemon = nil
+		daemonMu.Unlock()
+		srv.Close()
+		forgetRemoteInfos()
+	})
+	forgetRemoteInfos()
+	return calls
+}
+
+func forgetRemoteInfos() {
+	remoteInfoMu.Lock()
+	defer remoteInfoMu.Unlock()
+	clear(remoteInfos)
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// configDump answers config/dump with one section per remote, of the given type.
+func configDump(types map[string]string) rcHandler {
+	return func(map[string]any) (any, error) {
+		dump := make(map[string]map[string]string)
+		for name, typ := range types {
+			dump[name] = map[string]string{"type": typ}
+		}
+		return dump, nil
+	}
+}
+
+func TestGetRemoteInfo(t *testing.T) {
+	features := map[string]string{
+		"gdrive:": `{"Name":"gdrive","Precision":1000000,"Hashes":["md5","sha1","sha256"],"Features":{"BucketBased":false}}`,
+		"bucket:": `{"Name":"bucket","Precision":1,"Hashes":["md5"],"Features":{"BucketBased":true}}`,
+		"webdav:": `{"Name":"webdav","Precision":3153600000000000000,"Hashes":[],"Features":{"BucketBased":false}}`,
+		"sftp:":   `{"Name":"sftp","Precision":1000000000,"Hashes":["md5","sha1"],"Features":{"BucketBased":false}}`,
+	}
+	calls := useFakeDaemon(t, map[string]rcHandler{
+		"config/dump": configDump(map[string]string{"gdrive": "drive", "bucket": "s3", "webdav": "webdav", "sftp": "sftp"}),
+		"operations/fsinfo": func(params map[string]any) (any, error) {
+			return json.RawMessage(features[params["fs"].(string)]), nil
+		},
+	})
+
+	tests := []struct {
+		name                 string
+		wantType             string
+		wantModTime          bool
+		wantSHA256           bool
+		eventuallyConsistent bool
+	}{
+		{"gdrive", "drive", true, true, true},
+		{"bucket", "s3", true, false, true},
+		{"webdav", "webdav", false, false, false},
+		{"sftp", "sftp", true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := GetRemoteInfo(context.Background(), &config.Config{}, tt.name)
+			if err != nil {
+				t.Fatalf("GetRemoteInfo: %v", err)
+			}
+			if info.Name != tt.name || info.Type != tt.wantType {
+				t.Errorf("remote %q of type %q, want %q of type %q", info.Name, info.Type, tt.name, tt.wantType)
+			}
+			if got := info.HasModTime(); got != tt.wantModTime {
+				t.Errorf("HasModTime = %v, want %v", got, tt.wantModTime)
+			}
+			if got := info.HasHash("sha256"); got != tt.wantSHA256 {
+				t.Errorf("HasHash(sha256) = %v, want %v", got, tt.wantSHA256)
+			}
+			if got := info.EventuallyConsistent(); got != tt.eventuallyConsistent {
+				t.Errorf("EventuallyConsistent = %v, want %v", got, tt.eventuallyConsistent)
+			}
+		})
+	}
+
+	// Answers are cached for the session.
+	if _, err := GetRemoteInfo(context.Background(), &config.Config{}, "gdrive"); err != nil {
+		t.Fatal(err)
+	}
+	if calls["operations/fsinfo"] != len(tests) {
+		t.Errorf("features were read %d times for %d remotes", calls["operations/fsinfo"], len(tests))
+	}
+	if _, err := GetRemoteInfo(context.Background(), &config.Config{}, "missing"); err == nil {
+		t.Error("GetRemoteInfo succeeded for a remote that is not in the config")
+	}
+}
+
+func TestCloudLastModTimeWithoutBackendModTimes(t *testing.T) {
+	uploaded := time.Now().Truncate(time.Second)
+	saved := map[string]time.Time{
+		"user1.dat": uploaded.Add(-72 * time.Hour),
+		"user2.dat": uploaded.Add(-48 * time.Hour),
+	}
+	listing := []map[string]any{
+		{"Path": "user1.dat", "Size": 10, "ModTime": uploaded},
+		{"Path": "user2.dat", "Size": 10, "ModTime": uploaded.Add(-time.Minute)},
+	}
+	withManifest := append(listing, map[string]any{"Path": manifestFile, "Size": 100, "ModTime": uploaded})
+
+	tests := []struct {
+		name    string
+		remote  string
+		listing []map[string]any
+		include func(name string) bool
+		want    time.Time
+	}{
+		{"manifest save times", "webdav", withManifest, nil, saved["user2.dat"]},
+		{"manifest save times of included files", "webdav", withManifest, func(name string) bool { return name == "user1.dat" }, saved["user1.dat"]},
+		{"upload times without a manifest", "webdav", listing, nil, uploaded},
+		{"backend mod times win over the manifest", "gdrive", withManifest, nil, uploaded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeDaemon(t, map[string]rcHandler{
+				"config/dump": configDump(map[string]string{"gdrive": "drive", "webdav": "webdav"}),
+				"operations/fsinfo": func(params map[string]any) (any, error) {
+					if params["fs"] == "gdrive:" {
+						return map[string]any{"Precision": time.Millisecond}, nil
+					}
+					return map[string]any{"Precision": modTimeNotSupported}, nil
+				},
+				"operations/list": func(map[string]any) (any, error) {
+					return map[string]any{"list": tt.listing}, nil
+				},
+				"sync/copy": func(params map[string]any) (any, error) {
+					data, err := json.Marshal(Manifest{ModTimes: saved})
+					if err != nil {
+						return nil, err
+					}
+					return nil, os.WriteFile(filepath.Join(params["dstFs"].(string), manifestFile), data, 0644)
+				},
+			})
+			target := config.ParseTarget(tt.remote + ":HKSaves")
+			got, err := cloudLastModTime(context.Background(), &config.Config{}, target, tt.include)
+			if err != nil {
+				t.Fatalf("cloudLastModTime: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("cloudLastModTime = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreModTimes(t *testing.T) {
+	saved := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	useFakeDaemon(t, map[string]rcHandler{
+		"config/dump": configDump(map[string]string{"webdav": "webdav"}),
+		"operations/fsinfo": func(map[string]any) (any, error) {
+			return map[string]any{"Precision": modTimeNotSupported}, nil
+		},
+		"sync/copy": func(params map[string]any) (any, error) {
+			data, err := json.Marshal(Manifest{ModTimes: map[string]time.Time{"user1.dat": saved, "gone.dat": saved}})
+			if err != nil {
+				return nil, err
+			}
+			return nil, os.WriteFile(filepath.Join(params["dstFs"].(string), manifestFile), data, 0644)
+		},
+	})
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"user1.dat": "downloaded"})
+
+	if err := restoreModTimes(context.Background(), &config.Config{}, config.ParseTarget("webdav:HKSaves"), dir); err != nil {
+		t.Fatalf("restoreModTimes: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "user1.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(saved) {
+		t.Errorf("user1.dat modified %s, want the save time %s from the manifest", info.ModTime(), saved)
+	}
+}
@@ -0,0 +1,212 @@
+// /internal/backup/cache.go
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"pirated-hollow-knight/internal/util"
+	"sync"
+	"time"
+)
+
+const (
+	cacheDirName     = "cache"
+	conflictsDirName = "conflicts"
+	mirrorInfoFile   = "mirror.json"
+	mirrorSavesDir   = "saves"
+)
+
+// mirrorMu guards the mirror metadata files.
+var mirrorMu sync.Mutex
+
+// MirrorInfo describes the local mirror cache kept for a remote target.
+type MirrorInfo struct {
+	Target    string    `json:"target"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Baseline is the newest remote modification time the mirror is known to match.
+	Baseline time.Time `json:"baseline"`
+	// Provisional is set while the mirror holds progress the remote has not seen yet.
+	Provisional bool `json:"provisional"`
+}
+
+// LoadMirror returns the mirror cache metadata for a remote target, if a cache exists.
+func LoadMirror(cfg *config.Config, target config.SyncTarget) (MirrorInfo, bool) {
+	mirrorMu.Lock()
+	defer mirrorMu.Unlock()
+	return loadMirrorLocked(cfg, target)
+}
+
+// CachedTarget returns a local target pointing at the mirror cache of a remote target.
+func CachedTarget(cfg *config.Config, target config.SyncTarget) (config.SyncTarget, bool) {
+	if _, ok := LoadMirror(cfg, target); !ok || !util.PathExists(mirrorDir(cfg, target)) {
+		return config.SyncTarget{}, false
+	}
+	return config.SyncTarget{
+		Type:     config.Local,
+		Path:     mirrorDir(cfg, target),
+		Slots:    target.Slots,
+		SlotSpec: target.SlotSpec,
+		Original: fmt.Sprintf("%s (offline cache)", target.Original),
+	}, true
+}
+
+// MarkProvisional flags the mirror of target as holding progress that must
+// still be reconciled with the remote.
+func MarkProvisional(cfg *config.Config, target config.SyncTarget) error {
+	mirrorMu.Lock()
+	defer mirrorMu.Unlock()
+	info, ok := loadMirrorLocked(cfg, target)
+	if !ok {
+		return fmt.Errorf("no offline cache exists for '%s'", target.Original)
+	}
+	info.Provisional = true
+	return saveMirrorLocked(cfg, target, info)
+}
+
+// ReconcileMirrors uploads provisional offline progress for every configured
+// remote target that is reachable again. Targets that are still offline are
+// left untouched and stay provisional.
+func ReconcileMirrors(ctx context.Context, cfg *config.Config) {
+	for _, t := range cfg.SyncTargets {
+		if t.Type != config.Remote {
+			continue
+		}
+		if info, ok := LoadMirror(cfg, t); !ok || !info.Provisional {
+			continue
+		}
+		cached, ok := CachedTarget(cfg, t)
+		if !ok {
+			continue
+		}
+		logger.Info("Reconciling offline progress for '%s'...", t.Original)
+		if err := Sync(ctx, cfg, cached, t); err != nil {
+			logger.Warn("'%s' is still unreachable, keeping offline progress in the local cache: %v", t.Original, err)
+		}
+	}
+}
+
+// prepareRemotePush protects remote changes made while the mirror was
+// provisional. If the remote moved on since the mirror's baseline, its current
+// contents are downloaded into the conflicts directory before being overwritten.
+// Only the slots this machine stores there count, so other players uploading
+// their own slots to a shared remote are no conflict.
+func prepareRemotePush(ctx context.Context, cfg *config.Config, dest config.SyncTarget) error {
+	info, ok := LoadMirror(cfg, dest)
+	if !ok || !info.Provisional || dest.Format == config.FormatSnapshot {
+		// Snapshot repositories only ever add snapshots, so nothing can be overwritten.
+		return nil
+	}
+	remoteModTime, err := cloudLastModTime(ctx, cfg, dest, conflictScope(cfg, dest))
+	if err != nil {
+		return fmt.Errorf("could not check '%s' for changes made while offline: %w", dest.Original, err)
+	}
+	if !remoteModTime.Truncate(time.Second).After(info.Baseline.Truncate(time.Second)) {
+		return nil
+	}
+
+	conflictDir := filepath.Join(cfg.DataDir, conflictsDirName,
+		fmt.Sprintf("%s-%s", spoolName(targetKey(dest)), time.Now().Format("20060102-150405")))
+	if err := rcloneCopy(ctx, cfg, remoteSpec(dest), conflictDir, copyOptions{rclone: dest.Rclone}); err != nil {
+		return fmt.Errorf("could not preserve conflicting remote saves of '%s': %w", dest.Original, err)
+	}
+	// Shown even in quiet mode: the player must know to look at the conflict.
+	logger.Prompt("⚠️ '%s' changed while this machine was offline. Its previous contents were saved to '%s'.", dest.Original, conflictDir)
+	return nil
+}
+
+// conflictScope returns which files of dest a change made elsewhere conflicts
+// with: those of the slots dest and the configured targets at its location map.
+// It returns nil, for every file, if one of them stores the location as a whole.
+func conflictScope(cfg *config.Config, dest config.SyncTarget) func(name string) bool {
+	stored := make(map[int]bool)
+	for _, to := range dest.Slots {
+		stored[to] = true
+	}
+	for _, t := range cfg.SyncTargets {
+		if targetKey(t) != targetKey(dest) {
+			continue
+		}
+		if t.Slots == nil {
+			return nil
+		}
+		for _, to := range t.Slots {
+			stored[to] = true
+		}
+	}
+	if len(stored) == 0 {
+		return nil
+	}
+	return func(name string) bool {
+		slot, ok := saves.SlotOf(path.Base(name))
+		return ok && stored[slot]
+	}
+}
+
+// updateMirror refreshes the mirror of remote after a successful transfer
+// between remote and localDir.
+func updateMirror(cfg *config.Config, remote config.SyncTarget, localDir string, pushed bool) {
+	mirrorMu.Lock()
+	defer mirrorMu.Unlock()
+
+	info, _ := loadMirrorLocked(cfg, remote)
+	if !pushed && info.Provisional {
+		// Never replace offline progress with what was just downloaded.
+		return
+	}
+
+	dir := mirrorDir(cfg, remote)
+	baseline, err := util.GetDirLastModTime(localDir)
+	if err == nil && filepath.Clean(localDir) != filepath.Clean(dir) {
+		err = refreshSpool(localDir, dir)
+	}
+	if err != nil {
+		logger.Warn("Could not update offline cache for '%s': %v", remote.Original, err)
+		return
+	}
+
+	info.Target = remote.Original
+	info.UpdatedAt = time.Now()
+	info.Baseline = baseline
+	info.Provisional = false
+	if err := saveMirrorLocked(cfg, remote, info); err != nil {
+		logger.Warn("Could not save offline cache state for '%s': %v", remote.Original, err)
+	}
+}
+
+func mirrorRoot(cfg *config.Config, target config.SyncTarget) string {
+	return filepath.Join(cfg.DataDir, cacheDirName, spoolName(targetKey(target)))
+}
+
+func mirrorDir(cfg *config.Config, target config.SyncTarget) string {
+	return filepath.Join(mirrorRoot(cfg, target), mirrorSavesDir)
+}
+
+func loadMirrorLocked(cfg *config.Config, target config.SyncTarget) (MirrorInfo, bool) {
+	var info MirrorInfo
+	data, err := os.ReadFile(filepath.Join(mirrorRoot(cfg, target), mirrorInfoFile))
+	if err != nil {
+		return info, false
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		logger.Warn("Ignoring unreadable offline cache state for '%s': %v", target.Original, err)
+		return MirrorInfo{}, false
+	}
+	return info, true
+}
+
+func saveMirrorLocked(cfg *config.Config, target config.SyncTarget, info MirrorInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(mirrorRoot(cfg, target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(mirrorRoot(cfg, target), mirrorInfoFile), data, 0644)
+}
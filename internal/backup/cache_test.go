@@ -0,0 +1,136 @@
+// /internal/backup/cache_test.go
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMirrorCache(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DataDir: filepath.Join(dir, "data")}
+	remote := config.ParseTarget("gdrive:HKSaves")
+
+	if _, ok := CachedTarget(cfg, remote); ok {
+		t.Fatal("a target that was never synced has an offline cache")
+	}
+	if err := MarkProvisional(cfg, remote); err == nil {
+		t.Fatal("MarkProvisional succeeded without an offline cache")
+	}
+
+	uploaded := filepath.Join(dir, "uploaded")
+	writeFiles(t, uploaded, map[string]string{"user1.dat": "a1"})
+	baseline := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(uploaded, "user1.dat"), baseline, baseline); err != nil {
+		t.Fatal(err)
+	}
+	updateMirror(cfg, remote, uploaded, true)
+	info, ok := LoadMirror(cfg, remote)
+	if !ok || info.Provisional || !info.Baseline.Equal(baseline) || info.Target != remote.Original {
+		t.Fatalf("LoadMirror after a push = %+v, %v", info, ok)
+	}
+	cached, ok := CachedTarget(cfg, remote)
+	if !ok {
+		t.Fatal("no offline cache after a push")
+	}
+	if got := readFiles(t, cached.Path); !reflect.DeepEqual(got, map[string]string{"user1.dat": "a1"}) {
+		t.Errorf("offline cache holds %v", got)
+	}
+
+	// Offline progress is never replaced by a download, only by an upload.
+	writeFiles(t, cached.Path, map[string]string{"user1.dat": "offline"})
+	if err := MarkProvisional(cfg, remote); err != nil {
+		t.Fatal(err)
+	}
+	downloaded := filepath.Join(dir, "downloaded")
+	writeFiles(t, downloaded, map[string]string{"user1.dat": "remote"})
+	updateMirror(cfg, remote, downloaded, false)
+	if info, _ := LoadMirror(cfg, remote); !info.Provisional {
+		t.Error("a download cleared the provisional flag")
+	}
+	if got := readFiles(t, cached.Path); got["user1.dat"] != "offline" {
+		t.Errorf("a download replaced offline progress with %v", got)
+	}
+	updateMirror(cfg, remote, cached.Path, true)
+	if info, _ := LoadMirror(cfg, remote); info.Provisional {
+		t.Error("uploading the offline progress left the cache provisional")
+	}
+	if got := readFiles(t, cached.Path); got["user1.dat"] != "offline" {
+		t.Errorf("offline cache holds %v after uploading it", got)
+	}
+
+	// Only provisional mirrors are checked for remote changes, so nothing
+	// reaches the unreachable remote here.
+	if err := prepareRemotePush(context.Background(), cfg, remote); err != nil {
+		t.Errorf("prepareRemotePush without offline progress: %v", err)
+	}
+}
+
+func TestConflictScope(t *testing.T) {
+	names := []string{"user1.dat", "user2.dat", "user3.dat", "user3.dat.bak1", "sub/user4.dat", "shared.dat", manifestFile}
+	tests := []struct {
+		name    string
+		targets []string
+		mapped  bool     // dest keeps its own slot map
+		want    []string // nil for every file
+	}{
+		{"plain target", []string{"gdrive:HK"}, false, nil},
+		{"not configured", []string{"gdrive:Other|||slots=1"}, false, nil},
+		{"slot map", []string{"gdrive:HK|||slots=1:3"}, false, []string{"user3.dat", "user3.dat.bak1"}},
+		{"several slot maps", []string{"gdrive:HK|||slots=1:3", "gdrive:HK|||slots=2:4,format=mirror"}, false, []string{"user3.dat", "user3.dat.bak1", "sub/user4.dat"}},
+		{"slot map and plain target", []string{"gdrive:HK|||slots=1:3", "gdrive:HK"}, false, nil},
+		{"own slot map", nil, true, []string{"user3.dat", "user3.dat.bak1"}},
+		{"own and configured slot maps", []string{"gdrive:HK|||slots=2:4"}, true, []string{"user3.dat", "user3.dat.bak1", "sub/user4.dat"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			for _, spec := range tt.targets {
+				cfg.SyncTargets = append(cfg.SyncTargets, config.ParseTarget(spec))
+			}
+			dest := config.ParseTarget("gdrive:HK|||slots=1:3")
+			if !tt.mapped {
+				dest = unmapped(dest)
+			}
+			include := conflictScope(cfg, dest)
+			if tt.want == nil {
+				if include != nil {
+					t.Error("conflictScope limits the check, want every file")
+				}
+				return
+			}
+			if include == nil {
+				t.Fatalf("conflictScope checks every file, want %v", tt.want)
+			}
+			var got []string
+			for _, name := range names {
+				if include(name) {
+					got = append(got, name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("conflictScope accepts %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestLatest(t *testing.T) {
+	early := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	m := &Manifest{ModTimes: map[string]time.Time{"user1.dat": early, "user2.dat": late}}
+	if got := m.latest(nil); !got.Equal(late) {
+		t.Errorf("latest(nil) = %s, want %s", got, late)
+	}
+	if got := m.latest(func(name string) bool { return name == "user1.dat" }); !got.Equal(early) {
+		t.Errorf("latest of slot 1 = %s, want %s", got, early)
+	}
+	if got := m.latest(func(string) bool { return false }); !got.IsZero() {
+		t.Errorf("latest of no file = %s, want zero", got)
+	}
+}
@@ -0,0 +1,526 @@
+// /internal/backup/crypt.go
+package backup
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/config"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// cryptHeaderFile is stored unencrypted at the root of every encrypted target.
+// It holds the key derivation salt and a check value for detecting wrong keys.
+const cryptHeaderFile = "hkcrypt.json"
+
+// ErrWrongKey is returned when a target was encrypted with a different secret.
+var ErrWrongKey = apperr.New(apperr.WrongKey, "wrong passphrase or key file")
+
+// checkPlaintext is sealed into the header so a wrong key is recognised
+// before any save data is touched.
+var checkPlaintext = []byte("pirated-hollow-knight")
+
+var nameEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type cryptHeader struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Check   []byte `json:"check"`
+}
+
+// cipherKeys encrypts file contents with random nonces and file names
+// deterministically, so the same name always maps to the same stored name.
+type cipherKeys struct {
+	content cipher.AEAD
+	names   cipher.AEAD
+	nameMAC []byte
+}
+
+var (
+	keyCacheMu sync.Mutex
+	keyCache   = make(map[string]*cipherKeys)
+)
+
+// deriveKeys turns a secret and salt into content and name keys using scrypt.
+func deriveKeys(secret, salt []byte) (*cipherKeys, error) {
+	cacheKey := string(secret) + "\x00" + string(salt)
+	keyCacheMu.Lock()
+	defer keyCacheMu.Unlock()
+	if k, ok := keyCache[cacheKey]; ok {
+		return k, nil
+	}
+
+	material, err := scrypt.Key(secret, salt, 1<<15, 8, 1, 96)
+	if err != nil {
+		return nil, err
+	}
+	content, err := newGCM(material[:32])
+	if err != nil {
+		return nil, err
+	}
+	names, err := newGCM(material[32:64])
+	if err != nil {
+		return nil, err
+	}
+	k := &cipherKeys{content: content, names: names, nameMAC: material[64:]}
+	keyCache[cacheKey] = k
+	return k, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a file's contents, binding them to the file's plaintext path.
+func (k *cipherKeys) seal(plain []byte, name string) ([]byte, error) {
+	nonce := make([]byte, k.content.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.sealWith(nonce, plain, name), nil
+}
+
+// sealWith encrypts a file's contents with the given nonce. A nonce must never
+// be used for different contents, so the result may only be kept if it is
+// known to equal the ciphertext the nonce produced before.
+func (k *cipherKeys) sealWith(nonce, plain []byte, name string) []byte {
+	return k.content.Seal(slices.Clip(nonce), nonce, plain, []byte(name))
+}
+
+func (k *cipherKeys) open(data []byte, name string) ([]byte, error) {
+	n := k.content.NonceSize()
+	if len(data) < n {
+		return nil, fmt.Errorf("encrypted file %s is truncated", name)
+	}
+	plain, err := k.content.Open(nil, data[:n], data[n:], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("encrypted file %s is corrupted or was tampered with", name)
+	}
+	return plain, nil
+}
+
+// encryptPath encrypts each segment of a slash-separated path.
+func (k *cipherKeys) encryptPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		mac := hmac.New(sha256.New, k.nameMAC)
+		mac.Write([]byte(seg))
+		nonce := mac.Sum(nil)[:k.names.NonceSize()]
+		sealed := k.names.Seal(nonce, nonce, []byte(seg), nil)
+		segments[i] = strings.ToLower(nameEncoding.EncodeToString(sealed))
+	}
+	return strings.Join(segments, "/")
+}
+
+func (k *cipherKeys) decryptPath(p string) (string, error) {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		sealed, err := nameEncoding.DecodeString(strings.ToUpper(seg))
+		n := k.names.NonceSize()
+		if err != nil || len(sealed) < n {
+			return "", fmt.Errorf("'%s' is not an encrypted name", p)
+		}
+		plain, err := k.names.Open(nil, sealed[:n], sealed[n:], nil)
+		if err != nil {
+			return "", fmt.Errorf("'%s' is not an encrypted name", p)
+		}
+		segments[i] = string(plain)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// loadSecret reads the passphrase or key file configured for target.
+func loadSecret(target config.SyncTarget) ([]byte, error) {
+	enc := target.Encryption
+	if enc.KeyFile != "" {
+		data, err := os.ReadFile(enc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read key file for '%s': %w", target.Original, err)
+		}
+		return data, nil
+	}
+	return []byte(enc.Passphrase), nil
+}
+
+// targetKeys returns the keys for an encrypted target, checking the secret
+// against the target's header. If the target has no header yet, a new one is
+// written when create is set; otherwise nil keys are returned.
+func targetKeys(ctx context.Context, cfg *config.Config, target config.SyncTarget, create bool) (*cipherKeys, error) {
+	secret, err := loadSecret(target)
+	if err != nil {
+		return nil, err
+	}
+	raw := rawFileStore(cfg, target)
+
+	tmp, err := os.MkdirTemp("", "hk-crypt-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	files, err := raw.list(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var header cryptHeader
+	hasHeader := false
+	for _, f := range files {
+		if f == cryptHeaderFile {
+			hasHeader = true
+			break
+		}
+	}
+
+	if hasHeader {
+		if err := raw.get(ctx, []string{cryptHeaderFile}, tmp); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(tmp, cryptHeaderFile))
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, fmt.Errorf("invalid encryption header in '%s': %w", target.Original, err)
+		}
+		keys, err := deriveKeys(secret, header.Salt)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := keys.open(header.Check, cryptHeaderFile); err != nil {
+			return nil, fmt.Errorf("could not decrypt '%s': %w", target.Original, ErrWrongKey)
+		}
+		return keys, nil
+	}
+
+	if !create {
+		return nil, nil
+	}
+	header = cryptHeader{Version: 1, Salt: make([]byte, 32)}
+	if _, err := rand.Read(header.Salt); err != nil {
+		return nil, err
+	}
+	keys, err := deriveKeys(secret, header.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if header.Check, err = keys.seal(checkPlaintext, cryptHeaderFile); err != nil {
+		return nil, err
+	}
+	if err := writeCryptHeader(tmp, header); err != nil {
+		return nil, err
+	}
+	if err := raw.put(ctx, tmp); err != nil {
+		return nil, fmt.Errorf("could not initialise encryption for '%s': %w", target.Original, err)
+	}
+	logger.Info("Initialised encryption for '%s'.", target.Original)
+	return keys, nil
+}
+
+func writeCryptHeader(dir string, header cryptHeader) error {
+	data, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(dir, cryptHeaderFile)
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		return err
+	}
+	// An old timestamp keeps the header from ever looking like the newest save.
+	old := time.Unix(0, 0)
+	return os.Chtimes(p, old, old)
+}
+
+// encryptTree writes an encrypted copy of every file below src into dst,
+// keeping modification times, and returns the nonce each file was sealed with
+// by its encrypted path. A file whose contents are unchanged since previous
+// was recorded is sealed with its previous nonce, so its ciphertext stays the
+// same and is not uploaded again. previous may be nil.
+func encryptTree(keys *cipherKeys, src, dst string, previous *Manifest) (map[string]string, error) {
+	files, err := listLocalFiles(src, "")
+	if err != nil {
+		return nil, err
+	}
+	nonces := make(map[string]string, len(files))
+	for _, f := range files {
+		name := keys.encryptPath(f)
+		if err := transformFile(filepath.Join(src, filepath.FromSlash(f)), filepath.Join(dst, filepath.FromSlash(name)), func(data []byte) ([]byte, error) {
+			sealed := previous.resealed(keys, name, data, f)
+			if sealed == nil {
+				var err error
+				if sealed, err = keys.seal(data, f); err != nil {
+					return nil, err
+				}
+			}
+			nonces[name] = hex.EncodeToString(sealed[:keys.content.NonceSize()])
+			return sealed, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return nonces, nil
+}
+
+// resealed seals the contents of file, stored under name, with the nonce m
+// recorded for it and returns the result if it is exactly the ciphertext m
+// recorded, which means the contents are unchanged. Otherwise it returns nil
+// and the result is discarded without ever being stored.
+func (m *Manifest) resealed(keys *cipherKeys, name string, plain []byte, file string) []byte {
+	if m == nil {
+		return nil
+	}
+	nonce, err := hex.DecodeString(m.Nonces[name])
+	if err != nil || len(nonce) != keys.content.NonceSize() {
+		return nil
+	}
+	sealed := keys.sealWith(nonce, plain, file)
+	if sum := sha256.Sum256(sealed); hex.EncodeToString(sum[:]) != m.Files[name] {
+		return nil
+	}
+	return sealed
+}
+
+// decryptTree writes a decrypted copy of every encrypted file below src into dst.
+func decryptTree(keys *cipherKeys, src, dst string) error {
+	files, err := listLocalFiles(src, "")
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f == cryptHeaderFile || f == manifestFile {
+			continue
+		}
+		name, err := keys.decryptPath(f)
+		if err != nil {
+			return err
+		}
+		if err := transformFile(filepath.Join(src, filepath.FromSlash(f)), filepath.Join(dst, filepath.FromSlash(name)), func(data []byte) ([]byte, error) {
+			return keys.open(data, name)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func transformFile(src, dst string, transform func([]byte) ([]byte, error)) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	out, err := transform(data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, out, 0644); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// syncEncrypted handles plain mirror syncs where either side is encrypted.
+// Data is encrypted into, or decrypted out of, a local staging directory that
+// is then transferred like any unencrypted mirror.
+func syncEncrypted(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget) error {
+	staging, err := os.MkdirTemp("", "hk-crypt-staging-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if source.Encryption != nil {
+		keys, err := targetKeys(ctx, cfg, source, false)
+		if err != nil {
+			return err
+		}
+		encrypted := filepath.Join(staging, "encrypted")
+		plain := filepath.Join(staging, "plain")
+		if err := os.MkdirAll(plain, 0755); err != nil {
+			return err
+		}
+		if keys != nil {
+			raw := source
+			raw.Encryption = nil
+			if err := transfer(ctx, cfg, raw, config.SyncTarget{Type: config.Local, Path: encrypted}, transferOptions{}); err != nil {
+				return err
+			}
+			if err := decryptTree(keys, encrypted, plain); err != nil {
+				return fmt.Errorf("could not decrypt '%s': %w", source.Original, err)
+			}
+		}
+		if source.Type == config.Remote {
+			updateMirror(cfg, source, plain, false)
+		}
+		return Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: plain, Original: source.Original}, destination)
+	}
+
+	keys, err := targetKeys(ctx, cfg, destination, true)
+	if err != nil {
+		return err
+	}
+	sourceDir := source.Path
+	if !source.IsPlainLocal() {
+		sourceDir = filepath.Join(staging, "plain")
+		if err := Sync(ctx, cfg, source, config.SyncTarget{Type: config.Local, Path: sourceDir}); err != nil {
+			return err
+		}
+	}
+	raw := destination
+	raw.Encryption = nil
+	previous, err := readManifest(ctx, cfg, raw)
+	if err != nil {
+		return err
+	}
+	encrypted := filepath.Join(staging, "encrypted")
+	nonces, err := encryptTree(keys, sourceDir, encrypted, previous)
+	if err != nil {
+		return err
+	}
+	// The header travels with every upload so a mirror replacing the target keeps it.
+	if err := copyCryptHeader(ctx, cfg, destination, encrypted); err != nil {
+		return err
+	}
+	if err := transfer(ctx, cfg, config.SyncTarget{Type: config.Local, Path: encrypted}, raw, transferOptions{nonces: nonces}); err != nil {
+		return err
+	}
+	if destination.Type == config.Remote {
+		updateMirror(cfg, destination, sourceDir, true)
+	}
+	return nil
+}
+
+func copyCryptHeader(ctx context.Context, cfg *config.Config, target config.SyncTarget, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := rawFileStore(cfg, target).get(ctx, []string{cryptHeaderFile}, dir); err != nil {
+		return err
+	}
+	old := time.Unix(0, 0)
+	return os.Chtimes(filepath.Join(dir, cryptHeaderFile), old, old)
+}
+
+// CheckEncryption verifies that the configured secret opens target. A target
+// that has not been written to yet is reported as usable.
+func CheckEncryption(ctx context.Context, cfg *config.Config, target config.SyncTarget) error {
+	_, err := targetKeys(ctx, cfg, target, false)
+	return err
+}
+
+// cryptStore encrypts names and contents on top of another fileStore.
+type cryptStore struct {
+	cfg    *config.Config
+	target config.SyncTarget
+	inner  fileStore
+	keys   *cipherKeys
+}
+
+func (s *cryptStore) loadKeys(ctx context.Context, create bool) (*cipherKeys, error) {
+	if s.keys != nil {
+		return s.keys, nil
+	}
+	keys, err := targetKeys(ctx, s.cfg, s.target, create)
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+	return keys, nil
+}
+
+func (s *cryptStore) list(ctx context.Context, dir string) ([]string, error) {
+	keys, err := s.loadKeys(ctx, false)
+	if err != nil || keys == nil {
+		return nil, err
+	}
+	encrypted, err := s.inner.list(ctx, keys.encryptPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(encrypted))
+	for _, f := range encrypted {
+		name, err := keys.decryptPath(f)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+func (s *cryptStore) get(ctx context.Context, files []string, localDir string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	keys, err := s.loadKeys(ctx, false)
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("'%s' has not been written to yet", s.target.Original)
+	}
+	tmp, err := os.MkdirTemp("", "hk-crypt-get-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	encrypted := make([]string, len(files))
+	for i, f := range files {
+		encrypted[i] = keys.encryptPath(f)
+	}
+	if err := s.inner.get(ctx, encrypted, tmp); err != nil {
+		return err
+	}
+	for i, f := range files {
+		if err := transformFile(filepath.Join(tmp, filepath.FromSlash(encrypted[i])), filepath.Join(localDir, filepath.FromSlash(f)), func(data []byte) ([]byte, error) {
+			return keys.open(data, f)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *cryptStore) put(ctx context.Context, localDir string) error {
+	keys, err := s.loadKeys(ctx, true)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "hk-crypt-put-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if _, err := encryptTree(keys, localDir, tmp, nil); err != nil {
+		return err
+	}
+	return s.inner.put(ctx, tmp)
+}
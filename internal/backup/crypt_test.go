@@ -0,0 +1,124 @@
+// /internal/backup/crypt_test.go
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCipherKeys(t *testing.T) {
+	keys, err := deriveKeys([]byte("secret"), []byte("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("save data")
+	sealed, err := keys.seal(plain, "user1.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := keys.seal(plain, "user1.dat"); bytes.Equal(sealed, again) {
+		t.Error("sealing the same file twice reused the nonce")
+	}
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name    string
+		data    []byte
+		file    string
+		wantErr bool
+	}{
+		{"round trip", sealed, "user1.dat", false},
+		{"moved to another name", sealed, "user2.dat", true},
+		{"tampered", tampered, "user1.dat", true},
+		{"truncated", sealed[:4], "user1.dat", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keys.open(tt.data, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("open error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, plain) {
+				t.Errorf("open = %q, want %q", got, plain)
+			}
+		})
+	}
+
+	for _, p := range []string{"user1.dat", "sub/dir/user1.dat.bak1"} {
+		encrypted := keys.encryptPath(p)
+		if strings.Contains(encrypted, "user1") || strings.Count(encrypted, "/") != strings.Count(p, "/") {
+			t.Errorf("encryptPath(%q) = %q", p, encrypted)
+		}
+		if got, err := keys.decryptPath(encrypted); err != nil || got != p {
+			t.Errorf("decryptPath(encryptPath(%q)) = %q, %v", p, got, err)
+		}
+	}
+	if _, err := keys.decryptPath("user1.dat"); err == nil {
+		t.Error("decryptPath accepted a plain name")
+	}
+}
+
+func TestSyncEncrypted(t *testing.T) {
+	ctx, cfg := context.Background(), &config.Config{}
+	dir := t.TempDir()
+	liveDir := filepath.Join(dir, "live")
+	files := map[string]string{"user1.dat": "plain save", "user1.dat.bak1": "plain save backup"}
+	writeFiles(t, liveDir, files)
+	vault := filepath.Join(dir, "vault")
+	t.Setenv("HK_TEST_PASSPHRASE", "correct horse")
+	t.Setenv("HK_TEST_WRONG_PASSPHRASE", "battery staple")
+	target := config.ParseTarget(vault + "|||passphrase-env=HK_TEST_PASSPHRASE")
+
+	if err := Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: liveDir}, target); err != nil {
+		t.Fatalf("Sync to the encrypted target: %v", err)
+	}
+	err := filepath.WalkDir(vault, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if strings.Contains(path, "user1") || bytes.Contains(data, []byte("plain save")) {
+			t.Errorf("%s is stored in the clear", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		target  config.SyncTarget
+		wantErr error
+	}{
+		{"right passphrase", target, nil},
+		{"wrong passphrase", config.ParseTarget(vault + "|||passphrase-env=HK_TEST_WRONG_PASSPHRASE"), ErrWrongKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restored := filepath.Join(t.TempDir(), "restored")
+			err := Sync(ctx, cfg, tt.target, config.SyncTarget{Type: config.Local, Path: restored})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || apperr.KindOf(err) != apperr.WrongKey {
+					t.Errorf("Sync error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Sync from the encrypted target: %v", err)
+			}
+			if got := readFiles(t, restored); !reflect.DeepEqual(got, files) {
+				t.Errorf("restored %v, want %v", got, files)
+			}
+		})
+	}
+}
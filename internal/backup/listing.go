@@ -0,0 +1,102 @@
+// /internal/backup/listing.go
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"pirated-hollow-knight/internal/config"
+	"strings"
+	"time"
+)
+
+// Exit codes rclone documents for missing paths.
+const (
+	rcloneExitDirNotFound  = 3
+	rcloneExitFileNotFound = 4
+)
+
+// ErrRemoteNotFound is returned when a remote directory or file does not exist.
+var ErrRemoteNotFound = errors.New("not found on remote")
+
+// RemoteFile describes one file in a remote listing.
+type RemoteFile struct {
+	// Path is slash-separated and relative to the listed directory.
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// Hashes maps rclone hash names (e.g. "sha256", "md5") to lowercase hex
+	// values. It only holds the hashes the backend can provide without downloading.
+	Hashes map[string]string
+}
+
+// Hash returns the hash of the given type, if the backend provided it.
+func (f RemoteFile) Hash(name string) (string, bool) {
+	h, ok := f.Hashes[name]
+	return h, ok && h != ""
+}
+
+// rcloneLsjsonItem represents a single item in the output of `rclone lsjson`.
+type rcloneLsjsonItem struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+	Hashes  map[string]string
+}
+
+// ListRemote returns every file below a remote target, recursively, with its
+// size, modification time and hashes. A missing directory is reported as
+// ErrRemoteNotFound, so it can be told apart from other failures.
+func ListRemote(ctx context.Context, cfg *config.Config, target config.SyncTarget) ([]RemoteFile, error) {
+	return listRemoteDir(ctx, cfg, remoteSpec(target), "", target.Rclone)
+}
+
+// listRemoteDir lists the files below dir of an rclone root, returning paths
+// relative to the root.
+func listRemoteDir(ctx context.Context, cfg *config.Config, root, dir string, opts config.RcloneOptions) ([]RemoteFile, error) {
+	spec := root
+	if dir != "" {
+		spec = root + "/" + dir
+	}
+	var items []rcloneLsjsonItem
+	if d := daemonFor(opts); d != nil {
+		var result struct {
+			List []rcloneLsjsonItem `json:"list"`
+		}
+		opt := map[string]any{"recurse": true, "filesOnly": true, "showHash": true}
+		params := map[string]any{"fs": spec, "remote": "", "opt": opt, "_config": rcConfig(opts)}
+		if err := d.call(ctx, "operations/list", params, &result); err != nil {
+			return nil, err
+		}
+		items = result.List
+	} else {
+		args := append([]string{"lsjson", "--recursive", "--files-only", "--hash", spec}, rcloneFlags(opts)...)
+		out, err := runRcloneOutput(ctx, cfg, args...)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(out, &items); err != nil {
+			return nil, fmt.Errorf("failed to parse rclone lsjson output for %s: %w", spec, err)
+		}
+	}
+	files := make([]RemoteFile, 0, len(items))
+	for _, item := range items {
+		if item.IsDir {
+			continue
+		}
+		hashes := make(map[string]string, len(item.Hashes))
+		for name, value := range item.Hashes {
+			hashes[name] = strings.ToLower(value)
+		}
+		files = append(files, RemoteFile{
+			Path:    path.Join(dir, item.Path),
+			Size:    item.Size,
+			ModTime: item.ModTime,
+			Hashes:  hashes,
+		})
+	}
+	return files, nil
+}
@@ -0,0 +1,153 @@
+// /internal/backup/listing_test.go
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFakeRclone puts an rclone in PATH that runs script, for the rest of the
+// test. It returns the file the fake appends the arguments of each call to,
+// one call per line.
+func useFakeRclone(t *testing.T, script string) (argsFile string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake rclone is a shell script")
+	}
+	dir := t.TempDir()
+	argsFile = filepath.Join(dir, "args")
+	body := "#!/bin/sh\necho \"$@\" >> '" + argsFile + "'\n" + script + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "rclone"), []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+// lsjsonOutput is a recursive listing as `rclone lsjson --hash` prints it,
+// with the upper case hashes some backends report.
+const lsjsonOutput = `[
+{"Path":"user1.dat","Name":"user1.dat","Size":10,"ModTime":"2026-10-01T12:00:00.5Z","IsDir":false,"Hashes":{"sha256":"ABCDEF01","md5":"0A1B"}},
+{"Path":"sub","Name":"sub","Size":-1,"ModTime":"2026-10-01T11:00:00Z","IsDir":true},
+{"Path":"sub/user2.dat","Name":"user2.dat","Size":20,"ModTime":"2026-10-02T08:30:00Z","IsDir":false,"Hashes":{"sha256":"","md5":"ff"}}
+]`
+
+func wantListing(dir string) []RemoteFile {
+	return []RemoteFile{
+		{Path: path.Join(dir, "user1.dat"), Size: 10, ModTime: time.Date(2026, 10, 1, 12, 0, 0, 5e8, time.UTC), Hashes: map[string]string{"sha256": "abcdef01", "md5": "0a1b"}},
+		{Path: path.Join(dir, "sub/user2.dat"), Size: 20, ModTime: time.Date(2026, 10, 2, 8, 30, 0, 0, time.UTC), Hashes: map[string]string{"sha256": "", "md5": "ff"}},
+	}
+}
+
+func TestListRemote(t *testing.T) {
+	argsFile := useFakeRclone(t, "cat <<'EOF'\n"+lsjsonOutput+"\nEOF")
+	files, err := ListRemote(context.Background(), &config.Config{}, config.ParseTarget("gdrive:HKSaves"))
+	if err != nil {
+		t.Fatalf("ListRemote: %v", err)
+	}
+	if want := wantListing(""); !reflect.DeepEqual(files, want) {
+		t.Errorf("ListRemote = %+v, want %+v", files, want)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(args), "lsjson --recursive --files-only --hash gdrive:HKSaves") {
+		t.Errorf("rclone ran with %q, want a recursive lsjson with hashes", args)
+	}
+	if h, ok := files[1].Hash("sha256"); ok {
+		t.Errorf("Hash(sha256) = %q for a file the backend has no sha256 for", h)
+	}
+	if h, ok := files[1].Hash("md5"); !ok || h != "ff" {
+		t.Errorf("Hash(md5) = %q, %v", h, ok)
+	}
+}
+
+func TestListRemoteDirThroughDaemon(t *testing.T) {
+	var listed map[string]any
+	useFakeDaemon(t, map[string]rcHandler{
+		"operations/list": func(params map[string]any) (any, error) {
+			listed = params
+			return map[string]any{"list": jsonValue(t, lsjsonOutput)}, nil
+		},
+	})
+	files, err := listRemoteDir(context.Background(), &config.Config{}, "gdrive:HKSaves", "snapshots", config.RcloneOptions{})
+	if err != nil {
+		t.Fatalf("listRemoteDir: %v", err)
+	}
+	if want := wantListing("snapshots"); !reflect.DeepEqual(files, want) {
+		t.Errorf("listRemoteDir = %+v, want %+v", files, want)
+	}
+	opt, _ := listed["opt"].(map[string]any)
+	if listed["fs"] != "gdrive:HKSaves/snapshots" || opt["recurse"] != true || opt["filesOnly"] != true || opt["showHash"] != true {
+		t.Errorf("operations/list called with %v", listed)
+	}
+}
+
+func TestListRemoteNotFound(t *testing.T) {
+	tests := []struct {
+		name         string
+		script       string
+		daemon       *rcError
+		wantNotFound bool
+	}{
+		{"directory not found", "exit 3", nil, true},
+		{"file not found", "exit 4", nil, true},
+		{"other failure", "echo 'Failed to lsjson: permission denied' >&2; exit 1", nil, false},
+		{"rc directory not found", "", &rcError{Message: "directory not found", Status: http.StatusNotFound}, true},
+		{"rc other failure", "", &rcError{Message: "permission denied", Status: http.StatusInternalServerError}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.daemon != nil {
+				useFakeDaemon(t, map[string]rcHandler{
+					"operations/list": func(map[string]any) (any, error) {
+						tt.daemon.Path = "operations/list"
+						return nil, tt.daemon
+					},
+				})
+			} else {
+				useFakeRclone(t, tt.script)
+			}
+
+			target := config.ParseTarget("gdrive:HKSaves")
+			_, err := ListRemote(context.Background(), &config.Config{}, target)
+			if err == nil {
+				t.Fatal("ListRemote succeeded, want an error")
+			}
+			if got := errors.Is(err, ErrRemoteNotFound); got != tt.wantNotFound {
+				t.Errorf("ListRemote error = %v, is ErrRemoteNotFound = %v, want %v", err, got, tt.wantNotFound)
+			}
+
+			// A target that doesn't exist yet is simply empty.
+			mod, err := GetCloudDirLastModTime(context.Background(), &config.Config{}, target)
+			if tt.wantNotFound && (err != nil || !mod.IsZero()) {
+				t.Errorf("GetCloudDirLastModTime = %s, %v, want zero time", mod, err)
+			}
+			if !tt.wantNotFound && err == nil {
+				t.Error("GetCloudDirLastModTime succeeded, want an error")
+			}
+		})
+	}
+}
+
+// jsonValue decodes a JSON document for a fake rc server to send back.
+func jsonValue(t *testing.T, doc string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
@@ -0,0 +1,63 @@
+// /internal/backup/options.go
+package backup
+
+import (
+	"pirated-hollow-knight/internal/config"
+	"strconv"
+	"time"
+)
+
+// defaultRetries is how often a failed copy is attempted unless the target
+// sets its own number.
+const defaultRetries = 5
+
+// copyAttempts returns how often a copy for a target with options o is attempted.
+func copyAttempts(o config.RcloneOptions) int {
+	if n, err := strconv.Atoi(o.Retries); err == nil && n > 0 {
+		return n
+	}
+	return defaultRetries
+}
+
+// rcloneFlags returns a target's options as rclone command-line flags. The
+// number of retries is left to the commands that copy.
+func rcloneFlags(o config.RcloneOptions) []string {
+	var flags []string
+	for _, f := range []struct{ name, value string }{
+		{"--bwlimit", o.BwLimit},
+		{"--transfers", o.Transfers},
+		{"--timeout", o.Timeout},
+		{"--contimeout", o.ConTimeout},
+	} {
+		if f.value != "" {
+			flags = append(flags, f.name, f.value)
+		}
+	}
+	return append(flags, o.Flags...)
+}
+
+// rcConfig returns a target's options as the "_config" of a remote-control
+// call, which takes durations in nanoseconds.
+func rcConfig(o config.RcloneOptions) map[string]any {
+	options := map[string]any{}
+	if n, err := strconv.Atoi(o.Transfers); err == nil {
+		options["Transfers"] = n
+	}
+	if d, err := time.ParseDuration(o.Timeout); err == nil {
+		options["Timeout"] = d.Nanoseconds()
+	}
+	if d, err := time.ParseDuration(o.ConTimeout); err == nil {
+		options["ConnectTimeout"] = d.Nanoseconds()
+	}
+	return options
+}
+
+// daemonFor returns the session's rclone daemon if it can honour a target's
+// options, or nil if the call must start its own rclone. The bandwidth limit
+// applies to a whole rclone process, and backend flags can't be set per call.
+func daemonFor(o config.RcloneOptions) *rcloneDaemon {
+	if o.BwLimit != "" || len(o.Flags) > 0 {
+		return nil
+	}
+	return activeDaemon()
+}
@@ -0,0 +1,198 @@
+// /internal/backup/queue.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrQueueClosed is returned for sync requests made after the queue was closed.
+var ErrQueueClosed = errors.New("sync queue is closed")
+
+// SyncQueue runs at most one sync per storage location at a time. Requests
+// that arrive while a location is busy wait their turn; those for the same
+// target are merged into a single pending sync, which runs from the most
+// recently requested source. Targets that share a location but differ in slot
+// map, format or encryption get pending syncs of their own. Failed syncs are
+// recorded in the retry queue, if one is attached.
+type SyncQueue struct {
+	ctx     context.Context
+	cfg     *config.Config
+	retries *RetryQueue
+	mu      sync.Mutex
+	workers map[string]*syncWorker
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// syncWorker runs the syncs to one storage location, in the order they were
+// first requested.
+type syncWorker struct {
+	pending []*syncJob
+	active  bool
+}
+
+type syncJob struct {
+	source  config.SyncTarget
+	dest    config.SyncTarget
+	verify  bool
+	waiters []chan error
+}
+
+// NewSyncQueue creates a queue whose syncs run under ctx. retries may be nil.
+func NewSyncQueue(ctx context.Context, cfg *config.Config, retries *RetryQueue) *SyncQueue {
+	return &SyncQueue{ctx: ctx, cfg: cfg, retries: retries, workers: make(map[string]*syncWorker)}
+}
+
+// Enqueue schedules a sync from source to destination and returns a channel
+// that receives the result of the sync that covers this request.
+func (q *SyncQueue) Enqueue(source, destination config.SyncTarget) <-chan error {
+	return q.enqueue(source, destination, false)
+}
+
+func (q *SyncQueue) enqueue(source, destination config.SyncTarget, verify bool) <-chan error {
+	done := make(chan error, 1)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		done <- ErrQueueClosed
+		return done
+	}
+
+	key := targetKey(destination)
+	w, ok := q.workers[key]
+	if !ok {
+		w = &syncWorker{}
+		q.workers[key] = w
+	}
+
+	id := targetIdentity(destination)
+	if i := slices.IndexFunc(w.pending, func(j *syncJob) bool { return targetIdentity(j.dest) == id }); i >= 0 {
+		logger.Info("Sync to '%s' already pending, merging request.", destination.Original)
+		w.pending[i].source = source
+		w.pending[i].verify = w.pending[i].verify || verify
+		w.pending[i].waiters = append(w.pending[i].waiters, done)
+	} else {
+		w.pending = append(w.pending, &syncJob{source: source, dest: destination, verify: verify, waiters: []chan error{done}})
+	}
+
+	if !w.active {
+		w.active = true
+		q.wg.Add(1)
+		go q.run(w)
+	}
+	return done
+}
+
+// Sync schedules a sync and blocks until it has completed.
+func (q *SyncQueue) Sync(source, destination config.SyncTarget) error {
+	return <-q.Enqueue(source, destination)
+}
+
+// SyncVerified schedules a sync, blocks until it has completed and then checks
+// the destination against the manifest the sync recorded. A destination that
+// doesn't match counts as a failed sync.
+func (q *SyncQueue) SyncVerified(source, destination config.SyncTarget) error {
+	return <-q.enqueue(source, destination, true)
+}
+
+// Drain blocks until every queued and running sync has finished.
+func (q *SyncQueue) Drain() {
+	q.wg.Wait()
+}
+
+// Close stops accepting new requests and waits for outstanding syncs to finish.
+func (q *SyncQueue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.Drain()
+}
+
+func (q *SyncQueue) run(w *syncWorker) {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		if len(w.pending) == 0 {
+			w.active = false
+			q.mu.Unlock()
+			return
+		}
+		job := w.pending[0]
+		w.pending = w.pending[1:]
+		q.mu.Unlock()
+
+		err := Sync(q.ctx, q.cfg, job.source, job.dest)
+		if err == nil && job.verify {
+			err = verifySynced(q.ctx, q.cfg, job.dest)
+		}
+		if q.retries != nil {
+			if err == nil {
+				q.retries.Resolve(job.dest)
+			} else if job.source.Type == config.Local {
+				q.recordFailure(job.source, job.dest, err)
+			}
+		}
+		for _, waiter := range job.waiters {
+			waiter <- err
+		}
+	}
+}
+
+// recordFailure marks destination as behind, keeping what the failed sync
+// from source had to send.
+func (q *SyncQueue) recordFailure(source, destination config.SyncTarget, syncErr error) {
+	if e, ok := q.retries.Lookup(destination); ok && filepath.Clean(e.Spool) == filepath.Clean(source.Path) {
+		// A retry from the spool itself.
+		q.retries.Record(destination, source.Path, syncErr)
+		return
+	}
+	dir, cleanup, err := spoolSource(q.ctx, q.cfg, source, destination)
+	if err != nil {
+		logger.Error("Could not stage the saves for retrying '%s': %v", destination.Original, err)
+		dir, cleanup = "", func() {}
+	}
+	defer cleanup()
+	q.retries.Record(destination, dir, syncErr)
+}
+
+// targetKey returns a string identifying the storage location of a target, so
+// that differently spelled targets pointing at the same place share a worker.
+func targetKey(t config.SyncTarget) string {
+	if t.Type == config.Remote {
+		return remoteSpec(t)
+	}
+	if abs, err := filepath.Abs(t.Path); err == nil {
+		return filepath.Clean(abs)
+	}
+	return filepath.Clean(t.Path)
+}
+
+// targetIdentity returns a string identifying a target by its location and
+// everything that decides what is stored there: its format, its encryption
+// and the slots it maps. Targets sharing a location but not an identity are
+// synced and retried separately.
+func targetIdentity(t config.SyncTarget) string {
+	id := targetKey(t) + "|format=" + t.Format
+	if t.Encryption != nil {
+		// The passphrase itself is left out, since the identity names spools.
+		id += "|encrypted=" + t.Encryption.KeyFile
+	}
+	if t.Slots != nil {
+		live := slices.Sorted(maps.Keys(t.Slots))
+		pairs := make([]string, len(live))
+		for i, slot := range live {
+			pairs[i] = fmt.Sprintf("%d:%d", slot, t.Slots[slot])
+		}
+		id += "|slots=" + strings.Join(pairs, ",")
+	}
+	return id
+}
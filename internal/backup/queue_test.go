@@ -0,0 +1,183 @@
+// /internal/backup/queue_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestSyncQueueCoalesces(t *testing.T) {
+	dir := t.TempDir()
+	var sources []config.SyncTarget
+	for _, name := range []string{"a", "b", "c"} {
+		source := filepath.Join(dir, name)
+		writeFiles(t, source, map[string]string{"user1.dat": name})
+		sources = append(sources, config.SyncTarget{Type: config.Local, Path: source})
+	}
+	destination := config.SyncTarget{Type: config.Local, Path: filepath.Join(dir, "dest")}
+
+	tests := []struct {
+		name     string
+		requests []config.SyncTarget
+		want     string
+	}{
+		{"single request", sources[:1], "a"},
+		{"newest source wins", sources, "c"},
+		{"repeated source", []config.SyncTarget{sources[2], sources[0], sources[0]}, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewSyncQueue(context.Background(), &config.Config{}, nil)
+			// Hold the destination busy, so every request waits for the
+			// same pending sync.
+			w := &syncWorker{active: true}
+			q.workers[targetKey(destination)] = w
+			var results []<-chan error
+			for _, source := range tt.requests {
+				results = append(results, q.Enqueue(source, destination))
+			}
+			if len(w.pending) != 1 || len(w.pending[0].waiters) != len(tt.requests) {
+				t.Fatalf("requests were not merged into one pending sync")
+			}
+
+			q.wg.Add(1)
+			go q.run(w)
+			for _, result := range results {
+				if err := <-result; err != nil {
+					t.Fatalf("sync: %v", err)
+				}
+			}
+			q.Close()
+			if got := readFiles(t, destination.Path); !reflect.DeepEqual(got, map[string]string{"user1.dat": tt.want}) {
+				t.Errorf("destination holds %v, want the saves of %s", got, tt.want)
+			}
+			if w.active || len(w.pending) != 0 {
+				t.Error("the worker did not go idle")
+			}
+		})
+	}
+}
+
+func TestSyncQueueSeparatesDestinations(t *testing.T) {
+	dir := t.TempDir()
+	source := config.SyncTarget{Type: config.Local, Path: filepath.Join(dir, "live")}
+	writeFiles(t, source.Path, map[string]string{"user1.dat": "live"})
+
+	q := NewSyncQueue(context.Background(), &config.Config{}, nil)
+	first := config.SyncTarget{Type: config.Local, Path: filepath.Join(dir, "first")}
+	second := config.SyncTarget{Type: config.Local, Path: filepath.Join(dir, "second")}
+	// The same directory spelled differently shares a worker.
+	again := config.SyncTarget{Type: config.Local, Path: filepath.Join(dir, "x", "..", "first")}
+	if targetKey(first) != targetKey(again) || targetKey(first) == targetKey(second) {
+		t.Fatalf("targetKey does not identify destinations by location")
+	}
+	for _, destination := range []config.SyncTarget{first, second, again} {
+		if err := q.Sync(source, destination); err != nil {
+			t.Fatalf("Sync to %s: %v", destination.Path, err)
+		}
+	}
+	q.Close()
+	for _, destination := range []config.SyncTarget{first, second} {
+		if got := readFiles(t, destination.Path); got["user1.dat"] != "live" {
+			t.Errorf("%s holds %v", destination.Path, got)
+		}
+	}
+	if err := <-q.Enqueue(source, first); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Enqueue after Close = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestSyncQueueSharedLocation(t *testing.T) {
+	dir := t.TempDir()
+	shared := filepath.Join(dir, "shared")
+	first := config.ParseTarget(shared + "|||slots=1")
+	second := config.ParseTarget(shared + "|||slots=2:3")
+	if targetKey(first) != targetKey(second) || targetIdentity(first) == targetIdentity(second) {
+		t.Fatalf("targets with different slot maps must share a location but not an identity")
+	}
+	if targetIdentity(first) != targetIdentity(config.ParseTarget(filepath.Join(dir, "x", "..", "shared")+"|||slots=1")) {
+		t.Fatalf("targetIdentity depends on how the location is spelled")
+	}
+
+	liveA, liveB := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeFiles(t, liveA, map[string]string{"user1.dat": "a1", "user2.dat": "a2"})
+	writeFiles(t, liveB, map[string]string{"user1.dat": "b1", "user2.dat": "b2"})
+	retries, err := OpenRetryQueue(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewSyncQueue(context.Background(), &config.Config{}, retries)
+	// Hold the location busy, so both requests are pending at once.
+	w := &syncWorker{active: true}
+	q.workers[targetKey(first)] = w
+	results := []<-chan error{
+		q.Enqueue(config.SyncTarget{Type: config.Local, Path: liveA}, first),
+		q.Enqueue(config.SyncTarget{Type: config.Local, Path: liveB}, second),
+	}
+	if len(w.pending) != 2 {
+		t.Fatalf("%d pending syncs, want one for each slot map", len(w.pending))
+	}
+
+	q.wg.Add(1)
+	go q.run(w)
+	for _, result := range results {
+		if err := <-result; err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+	}
+	q.Close()
+	want := map[string]string{"user1.dat": "a1", "user3.dat": "b2"}
+	if got := readFiles(t, shared); !reflect.DeepEqual(got, want) {
+		t.Errorf("shared location holds %v, want %v", got, want)
+	}
+
+	// Failures are recorded for each target on its own.
+	retries.Record(first, liveA, errors.New("offline"))
+	retries.Record(second, liveB, errors.New("offline"))
+	if got := len(retries.Entries()); got != 2 {
+		t.Fatalf("%d retry entries, want 2", got)
+	}
+	retries.Resolve(first)
+	if _, behind := retries.Lookup(second); !behind {
+		t.Error("resolving one target cleared the retry of another at the same location")
+	}
+}
+
+// writeFiles creates dir with files, named to their contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFiles returns the files in dir by name, leaving out the manifest.
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, e := range entries {
+		if e.Name() == manifestFile {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = string(data)
+	}
+	return files
+}
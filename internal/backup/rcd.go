@@ -0,0 +1,297 @@
+// /internal/backup/rcd.go
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/progress"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rcdStartTimeout = 10 * time.Second
+	rcdPollInterval = 200 * time.Millisecond
+)
+
+// rcloneDaemon is a running `rclone rcd` instance, reachable on loopback with
+// credentials only this process knows.
+type rcloneDaemon struct {
+	cmd    *exec.Cmd
+	url    string
+	user   string
+	pass   string
+	client *http.Client
+	exited chan struct{}
+}
+
+var (
+	daemonMu sync.Mutex
+	daemon   *rcloneDaemon
+)
+
+// rcError is an error reported by the remote-control API.
+type rcError struct {
+	Path    string `json:"path"`
+	Message string `json:"error"`
+	Status  int    `json:"status"`
+}
+
+func (e *rcError) Error() string {
+	return fmt.Sprintf("rclone %s failed: %s", e.Path, e.Message)
+}
+
+// pathNotFound reports whether e is a file operation failing because a remote
+// path doesn't exist. The server also answers 404 for methods it doesn't know,
+// which is a real error.
+func (e *rcError) pathNotFound(method string) bool {
+	if e.Status != http.StatusNotFound || strings.HasPrefix(e.Message, "couldn't find method") {
+		return false
+	}
+	return strings.HasPrefix(method, "operations/") || strings.HasPrefix(method, "sync/")
+}
+
+// TransferStats is the progress of an rclone transfer, as reported by core/stats.
+type TransferStats struct {
+	Bytes          int64   `json:"bytes"`
+	TotalBytes     int64   `json:"totalBytes"`
+	Transfers      int64   `json:"transfers"`
+	TotalTransfers int64   `json:"totalTransfers"`
+	Speed          float64 `json:"speed"`
+	Errors         int64   `json:"errors"`
+}
+
+// StartRcloneDaemon starts one rclone remote-control server for the session.
+// While it runs, every rclone operation is sent to it instead of starting a
+// new rclone process. The returned function shuts the server down.
+func StartRcloneDaemon(ctx context.Context, cfg *config.Config) (stop func(), err error) {
+	rclonePath, err := getRclonePath()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := freeLoopbackAddr()
+	if err != nil {
+		return nil, fmt.Errorf("could not find a free port for rclone: %w", err)
+	}
+	d := &rcloneDaemon{
+		url:    "http://" + addr + "/",
+		user:   randomToken(),
+		pass:   randomToken(),
+		client: &http.Client{},
+		exited: make(chan struct{}),
+	}
+
+	d.cmd = exec.Command(rclonePath, "rcd",
+		"--config", cfg.RcloneConfigPath,
+		"--rc-addr", addr,
+		"--rc-user", d.user,
+		"--log-level", "ERROR")
+	// The password is passed in the environment, where other users can't
+	// read it from the process list.
+	d.cmd.Env = append(os.Environ(), "RCLONE_RC_PASS="+d.pass)
+	// Every failure is also returned through the API, so rclone's own log
+	// would only repeat it (and report expected "not found" listings as errors).
+	d.cmd.Stderr = io.Discard
+	if err := d.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start rclone remote control: %w", err)
+	}
+	go func() {
+		_ = d.cmd.Wait()
+		close(d.exited)
+	}()
+
+	if err := d.waitReady(ctx); err != nil {
+		d.shutdown()
+		return nil, err
+	}
+	logger.Info("rclone remote control listening on %s.", addr)
+
+	daemonMu.Lock()
+	daemon = d
+	daemonMu.Unlock()
+	return func() {
+		daemonMu.Lock()
+		daemon = nil
+		daemonMu.Unlock()
+		d.shutdown()
+	}, nil
+}
+
+// activeDaemon returns the session's rclone daemon, or nil if none is running.
+func activeDaemon() *rcloneDaemon {
+	daemonMu.Lock()
+	defer daemonMu.Unlock()
+	return daemon
+}
+
+func (d *rcloneDaemon) waitReady(ctx context.Context) error {
+	deadline := time.Now().Add(rcdStartTimeout)
+	for {
+		err := d.call(ctx, "rc/noop", map[string]any{}, nil)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-d.exited:
+			return errors.New("rclone remote control exited during startup")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("rclone remote control did not start: %w", err)
+		}
+	}
+}
+
+func (d *rcloneDaemon) shutdown() {
+	_ = d.call(context.Background(), "core/quit", map[string]any{}, nil)
+	select {
+	case <-d.exited:
+	case <-time.After(5 * time.Second):
+		_ = d.cmd.Process.Kill()
+		<-d.exited
+	}
+	logger.Info("rclone remote control stopped.")
+}
+
+// call invokes an RC method with the given parameters and decodes the result into out.
+func (d *rcloneDaemon) call(ctx context.Context, method string, params any, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.user, d.pass)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		rcErr := &rcError{Path: method, Status: resp.StatusCode}
+		if json.Unmarshal(data, rcErr) != nil || rcErr.Message == "" {
+			rcErr.Message = resp.Status
+		}
+		if rcErr.pathNotFound(method) {
+			return fmt.Errorf("%w: %w", ErrRemoteNotFound, rcErr)
+		}
+		return rcErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// runJob starts method as an asynchronous job and waits for it to finish,
+// reporting its progress under label. If ctx is cancelled, the job is stopped.
+func (d *rcloneDaemon) runJob(ctx context.Context, method, label string, params map[string]any) (err error) {
+	params["_async"] = true
+	var started struct {
+		JobID int64 `json:"jobid"`
+	}
+	if err := d.call(ctx, method, params, &started); err != nil {
+		return err
+	}
+	group := fmt.Sprintf("job/%d", started.JobID)
+	tracker := progress.Start(label)
+	defer func() { tracker.Finish(err) }()
+
+	ticker := time.NewTicker(rcdPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = d.call(context.Background(), "job/stop", map[string]any{"jobid": started.JobID}, nil)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		var status struct {
+			Finished bool   `json:"finished"`
+			Success  bool   `json:"success"`
+			Error    string `json:"error"`
+		}
+		if err := d.call(ctx, "job/status", map[string]any{"jobid": started.JobID}, &status); err != nil {
+			return err
+		}
+		var stats TransferStats
+		if err := d.call(ctx, "core/stats", map[string]any{"group": group}, &stats); err == nil {
+			tracker.Update(stats.Bytes, stats.TotalBytes, stats.Transfers, stats.TotalTransfers)
+		}
+		if !status.Finished {
+			continue
+		}
+		if !status.Success {
+			return &rcError{Path: method, Message: status.Error}
+		}
+		return nil
+	}
+}
+
+// copy runs sync/copy, retrying failed attempts like the command line does.
+func (d *rcloneDaemon) copy(ctx context.Context, src, dst string, opts copyOptions, filesFrom string) error {
+	filter := map[string]any{}
+	if len(opts.exclude) > 0 {
+		filter["ExcludeRule"] = opts.exclude
+	}
+	if filesFrom != "" {
+		filter["FilesFromRaw"] = []string{filesFrom}
+	}
+	options := rcConfig(opts.rclone)
+	if opts.ignoreTimes {
+		options["IgnoreTimes"] = true
+	}
+
+	var err error
+	attempts := copyAttempts(opts.rclone)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = d.runJob(ctx, "sync/copy", opts.label, map[string]any{
+			"srcFs":   src,
+			"dstFs":   dst,
+			"_filter": filter,
+			"_config": options,
+		})
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		logger.Warn("Copy from '%s' to '%s' failed (attempt %d/%d): %v", src, dst, attempt, attempts, err)
+	}
+	return err
+}
+
+func freeLoopbackAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,57 @@
+// /internal/backup/rcd_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDaemonCallNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[1:]
+		w.Header().Set("Content-Type", "application/json")
+		switch method {
+		case "operations/list", "sync/copy":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error": "directory not found", "path": %q, "status": 404}`, method)
+		case "rc/noop":
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error": "couldn't find method \"%s\"", "path": %q, "status": 404}`, method, method)
+		}
+	}))
+	defer srv.Close()
+	d := &rcloneDaemon{url: srv.URL + "/", client: srv.Client()}
+
+	tests := []struct {
+		method       string
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{"rc/noop", false, false},
+		{"operations/list", true, true},
+		{"sync/copy", true, true},
+		{"operations/unknown", true, false},
+		{"core/unknown", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			err := d.call(context.Background(), tt.method, map[string]any{}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("call error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrRemoteNotFound); got != tt.wantNotFound {
+				t.Errorf("call error = %v, is ErrRemoteNotFound = %v, want %v", err, got, tt.wantNotFound)
+			}
+			var rcErr *rcError
+			if tt.wantErr && !errors.As(err, &rcErr) {
+				t.Errorf("call error = %v, want an *rcError", err)
+			}
+		})
+	}
+}
@@ -2,74 +2,92 @@
 package backup
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"pirated-hollow-knight/internal/config"
-	"pirated-hollow-knight/internal/log"
+	"pirated-hollow-knight/internal/progress"
 	"pirated-hollow-knight/internal/util"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// rcloneLsjsonItem represents a single item in the output of `rclone lsjson`.
-type rcloneLsjsonItem struct {
-	Path    string
-	Name    string
-	Size    int64
-	ModTime time.Time
+// GetCloudDirLastModTime fetches the most recent modification time of any
+// file below a cloud directory, or zero if the directory does not exist yet.
+// Backends that cannot store modification times only know when files were
+// uploaded, so the save times recorded in the target's manifest are used instead.
+func GetCloudDirLastModTime(ctx context.Context, cfg *config.Config, target config.SyncTarget) (time.Time, error) {
+	return cloudLastModTime(ctx, cfg, target, nil)
 }
 
-// GetCloudDirLastModTime fetches the most recent modification time from a cloud directory.
-func GetCloudDirLastModTime(ctx context.Context, cfg *config.Config, target config.SyncTarget) (time.Time, error) {
-	rclonePath, err := getRclonePath()
+// cloudLastModTime is GetCloudDirLastModTime for only the files include
+// accepts, or for every file if include is nil.
+func cloudLastModTime(ctx context.Context, cfg *config.Config, target config.SyncTarget, include func(name string) bool) (time.Time, error) {
+	files, err := ListRemote(ctx, cfg, target)
+	if errors.Is(err, ErrRemoteNotFound) {
+		return time.Time{}, nil // Return zero time, indicating it doesn't exist yet.
+	}
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	remotePath := fmt.Sprintf("%s:%s", target.RemoteName, target.Path)
-	cmdArgs := []string{"--config", cfg.RcloneConfigPath, "lsjson", remotePath}
-	cmd := exec.CommandContext(ctx, rclonePath, cmdArgs...)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		// Specific check for directory not found, which is not a fatal error.
-		if strings.Contains(stderr.String(), "directory not found") {
-			return time.Time{}, nil // Return zero time, indicating it doesn't exist yet.
+	if info, err := GetRemoteInfo(ctx, cfg, target.RemoteName); err == nil && !info.HasModTime() {
+		if slices.ContainsFunc(files, func(f RemoteFile) bool { return f.Path == manifestFile }) {
+			manifest, err := readManifest(ctx, cfg, target)
+			if err != nil {
+				return time.Time{}, err
+			}
+			if manifest != nil && len(manifest.ModTimes) > 0 {
+				return manifest.latest(include), nil
+			}
 		}
-		return time.Time{}, fmt.Errorf("rclone lsjson failed for %s: %w\nOutput: %s", remotePath, err, stderr.String())
-	}
-
-	var items []rcloneLsjsonItem
-	if err := json.Unmarshal(stdout.Bytes(), &items); err != nil {
-		return time.Time{}, fmt.Errorf("failed to parse rclone lsjson output: %w", err)
+		logger.Warn("'%s' (%s) does not keep modification times and has no manifest with save times; using upload times.", target.Original, info.Type)
 	}
 
 	var latestModTime time.Time
-	for _, item := range items {
-		if item.ModTime.After(latestModTime) {
-			latestModTime = item.ModTime
+	for _, f := range files {
+		if (include == nil || include(f.Path)) && f.ModTime.After(latestModTime) {
+			latestModTime = f.ModTime
 		}
 	}
-
 	return latestModTime, nil
 }
 
+// remoteSpec returns the "remote:path" form rclone uses to address a target.
+func remoteSpec(target config.SyncTarget) string {
+	return fmt.Sprintf("%s:%s", target.RemoteName, target.Path)
+}
+
 // (Rest of file is unchanged)
-func getRclonePath() (string, error) {
+// LocalRclonePath returns where the launcher keeps its own copy of rclone: next
+// to the executable, named as rclone is on this platform.
+func LocalRclonePath() (string, error) {
 	exePath, err := os.Executable()
 	if err != nil {
 		return "", fmt.Errorf("could not determine application directory: %w", err)
 	}
-	localRclonePath := filepath.Join(filepath.Dir(exePath), "rclone.exe")
+	name := "rclone"
+	if runtime.GOOS == "windows" {
+		name = "rclone.exe"
+	}
+	return filepath.Join(filepath.Dir(exePath), name), nil
+}
+
+// getRclonePath prefers the launcher's own copy of rclone over one in PATH.
+func getRclonePath() (string, error) {
+	localRclonePath, err := LocalRclonePath()
+	if err != nil {
+		return "", err
+	}
 	if util.PathExists(localRclonePath) {
 		return localRclonePath, nil
 	}
@@ -79,40 +97,151 @@ func getRclonePath() (string, error) {
 	}
 	return path, nil
 }
+
+// RunRcloneCommand runs an rclone command, reporting its transfer progress.
 func RunRcloneCommand(ctx context.Context, cfg *config.Config, args ...string) error {
+	return runRclone(ctx, cfg, "rclone", config.RcloneOptions{}, args...)
+}
+
+// rcloneLogLine is one line of rclone's --use-json-log output.
+type rcloneLogLine struct {
+	Level string         `json:"level"`
+	Msg   string         `json:"msg"`
+	Stats *TransferStats `json:"stats"`
+}
+
+// runRclone runs an rclone command with the options of the target it is for.
+func runRclone(ctx context.Context, cfg *config.Config, label string, opts config.RcloneOptions, args ...string) error {
 	rclonePath, err := getRclonePath()
 	if err != nil {
 		return err
 	}
-	isQuiet := cfg.LogLevel == "quiet"
-	cmdArgs := []string{"--config", cfg.RcloneConfigPath, "--retries", "5"}
-	if !isQuiet {
-		cmdArgs = append(cmdArgs, "--progress")
-	}
+	cmdArgs := []string{"--config", cfg.RcloneConfigPath, "--retries", strconv.Itoa(copyAttempts(opts)),
+		"--use-json-log", "--stats", "500ms", "--stats-log-level", "NOTICE"}
 	cmdArgs = append(cmdArgs, args...)
+	cmdArgs = append(cmdArgs, rcloneFlags(opts)...)
 	cmd := exec.CommandContext(ctx, rclonePath, cmdArgs...)
-	log.Log.Info("Executing: %s", cmd.String())
-	if isQuiet {
-		cmd.Stdout = io.Discard
-		cmd.Stderr = io.Discard
-	} else {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-	}
-	err = cmd.Run()
+	logger.Debug("Executing: %s", cmd.String())
+	stderr, err := cmd.StderrPipe()
 	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("rclone command failed: %w", err)
 	}
-	log.Log.Info("rclone command completed successfully.")
+
+	tracker := progress.Start(label)
+	var lastError string
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line rcloneLogLine
+		if json.Unmarshal(scanner.Bytes(), &line) != nil {
+			logger.Debug("rclone: %s", scanner.Text())
+			continue
+		}
+		switch {
+		case line.Stats != nil:
+			tracker.Update(line.Stats.Bytes, line.Stats.TotalBytes, line.Stats.Transfers, line.Stats.TotalTransfers)
+		case line.Level == "error" || line.Level == "critical":
+			lastError = strings.TrimSpace(line.Msg)
+			logger.Warn("rclone: %s", lastError)
+		default:
+			logger.Debug("rclone: %s", strings.TrimSpace(line.Msg))
+		}
+	}
+
+	err = cmd.Wait()
+	tracker.Finish(err)
+	if err != nil {
+		if lastError != "" {
+			return fmt.Errorf("rclone command failed: %w: %s", err, lastError)
+		}
+		return fmt.Errorf("rclone command failed: %w", err)
+	}
+	logger.Info("rclone command completed successfully.")
 	return nil
 }
+
+// copyOptions adjusts an rclone copy.
+type copyOptions struct {
+	label       string   // the target the copy is for, as shown in progress reports
+	exclude     []string // filter rules for files to leave out
+	filesFrom   []string // if set, copy only these files, relative to the source
+	ignoreTimes bool     // transfer files even if size and time match
+	// rclone holds the options of the target the copy is for.
+	rclone config.RcloneOptions
+}
+
+// rcloneCopy copies src to dst, through the session's rclone daemon if one is
+// running and by starting rclone otherwise.
+func rcloneCopy(ctx context.Context, cfg *config.Config, src, dst string, opts copyOptions) error {
+	var filesFrom string
+	if len(opts.filesFrom) > 0 {
+		list, err := os.CreateTemp("", "hk-files-from-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(list.Name())
+		_, err = list.WriteString(strings.Join(opts.filesFrom, "\n") + "\n")
+		if closeErr := list.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		filesFrom = list.Name()
+	}
+
+	if opts.label == "" {
+		opts.label = dst
+	}
+	if d := daemonFor(opts.rclone); d != nil {
+		return d.copy(ctx, src, dst, opts, filesFrom)
+	}
+	args := []string{"copy"}
+	if opts.ignoreTimes {
+		args = append(args, "--ignore-times")
+	}
+	for _, rule := range opts.exclude {
+		args = append(args, "--exclude", rule)
+	}
+	if filesFrom != "" {
+		args = append(args, "--files-from-raw", filesFrom)
+	}
+	return runRclone(ctx, cfg, opts.label, opts.rclone, append(args, src, dst)...)
+}
+
+// runRcloneOutput runs an rclone command and returns its standard output.
+// Missing files and directories are reported as ErrRemoteNotFound, based on
+// rclone's exit code rather than its (possibly localized) error text.
+func runRcloneOutput(ctx context.Context, cfg *config.Config, args ...string) ([]byte, error) {
+	rclonePath, err := getRclonePath()
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := append([]string{"--config", cfg.RcloneConfigPath}, args...)
+	cmd := exec.CommandContext(ctx, rclonePath, cmdArgs...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && (exitErr.ExitCode() == rcloneExitDirNotFound || exitErr.ExitCode() == rcloneExitFileNotFound) {
+			return nil, ErrRemoteNotFound
+		}
+		return nil, fmt.Errorf("rclone %s failed: %w\nOutput: %s", args[0], err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
 func RunRcloneConfigWizard(cfg *config.Config) error {
 	rclonePath, err := getRclonePath()
 	if err != nil {
-		return fmt.Errorf("could not find rclone.exe to run setup: %w", err)
+		return fmt.Errorf("could not find rclone to run setup: %w", err)
 	}
-	log.Log.Prompt("The official rclone configuration wizard will now start.")
-	log.Log.Prompt("Please follow the on-screen instructions.")
+	logger.Prompt("The official rclone configuration wizard will now start.")
+	logger.Prompt("Please follow the on-screen instructions.")
 	cmd := exec.Command(rclonePath, "config", "--config", cfg.RcloneConfigPath)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
@@ -120,6 +249,20 @@ func RunRcloneConfigWizard(cfg *config.Config) error {
 	return cmd.Run()
 }
 func GetConfiguredRemotes(cfg *config.Config) (map[string]bool, error) {
+	remotes := make(map[string]bool)
+	if d := activeDaemon(); d != nil {
+		var result struct {
+			Remotes []string `json:"remotes"`
+		}
+		if err := d.call(context.Background(), "config/listremotes", map[string]any{}, &result); err != nil {
+			return nil, fmt.Errorf("failed to list remotes: %w", err)
+		}
+		for _, name := range result.Remotes {
+			remotes[name] = true
+		}
+		return remotes, nil
+	}
+
 	rclonePath, err := getRclonePath()
 	if err != nil {
 		return nil, err
@@ -129,7 +272,6 @@ func GetConfiguredRemotes(cfg *config.Config) (map[string]bool, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to list remotes: %w\nOutput: %s", err, string(output))
 	}
-	remotes := make(map[string]bool)
 	lines := strings.Split(strings.ReplaceAll(string(output), "\r\n", "\n"), "\n")
 	for _, line := range lines {
 		if strings.HasSuffix(line, ":") {
@@ -0,0 +1,130 @@
+// /internal/backup/remotes.go
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"pirated-hollow-knight/internal/config"
+	"sort"
+	"strings"
+)
+
+// maxConfigSteps bounds the questions answered while creating a remote.
+const maxConfigSteps = 20
+
+// rcloneConfigStep is the state rclone returns from a non-interactive
+// `config create`: either a further question in Option, or nothing when done.
+type rcloneConfigStep struct {
+	State  string
+	Option *struct {
+		Name       string
+		Help       string
+		DefaultStr string
+	}
+	Error string
+}
+
+// CreateRemote adds (or replaces) a remote in the launcher's rclone.conf
+// without prompting. Questions rclone still has once the given options are
+// applied are answered with their defaults, except that a supplied token is
+// never refreshed through a browser. Remotes that would need a browser login
+// are rejected with a hint to pass a token or service account instead. A new
+// remote that could not be completed is removed again.
+func CreateRemote(ctx context.Context, cfg *config.Config, name, backend string, options map[string]string) (err error) {
+	existing, err := GetConfiguredRemotes(cfg)
+	if err != nil {
+		return err
+	}
+	if !existing[name] {
+		// Don't leave a half-configured remote behind.
+		defer func() {
+			if err != nil {
+				_, _ = runRcloneOutput(ctx, cfg, "config", "delete", name)
+			}
+		}()
+	}
+
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := []string{"config", "create", name, backend}
+	for _, k := range keys {
+		args = append(args, k, options[k])
+	}
+	args = append(args, "--non-interactive")
+
+	for step := 0; step < maxConfigSteps; step++ {
+		out, err := runRcloneOutput(ctx, cfg, args...)
+		if err != nil {
+			return fmt.Errorf("could not create remote '%s': %w", name, err)
+		}
+		var next rcloneConfigStep
+		if err := json.Unmarshal(out, &next); err != nil {
+			return fmt.Errorf("could not read rclone's reply while creating remote '%s': %w", name, err)
+		}
+		if next.Error != "" {
+			return fmt.Errorf("could not create remote '%s': %s", name, next.Error)
+		}
+		if next.Option == nil {
+			logger.Info("Remote '%s' (%s) saved to '%s'.", name, backend, cfg.RcloneConfigPath)
+			return nil
+		}
+
+		answer := next.Option.DefaultStr
+		switch next.Option.Name {
+		case "config_is_local":
+			return fmt.Errorf("remote '%s' needs a browser login; pass --token (from 'rclone authorize \"%s\"' on a machine with a browser) or --service-account-file", name, backend)
+		case "config_refresh_token":
+			answer = "false"
+		}
+		logger.Info("Answering rclone's '%s' question with '%s'.", next.Option.Name, answer)
+		args = []string{"config", "create", name, backend, "--non-interactive", "--continue", "--state", next.State, "--result", answer}
+	}
+	return fmt.Errorf("could not create remote '%s': rclone kept asking questions", name)
+}
+
+// CheckRemote runs a test listing of a remote's root to make sure it is
+// configured and its credentials work, using the options of the target it is for.
+func CheckRemote(ctx context.Context, cfg *config.Config, name string, opts config.RcloneOptions) error {
+	args := append([]string{"lsjson", "--max-depth", "1", "--dirs-only", name + ":"}, rcloneFlags(opts)...)
+	_, err := runRcloneOutput(ctx, cfg, args...)
+	if err != nil && !errors.Is(err, ErrRemoteNotFound) {
+		return fmt.Errorf("test listing of remote '%s' failed: %w", name, err)
+	}
+	return nil
+}
+
+// RemoteChecks returns the first target of each distinct remote and set of
+// rclone options among targets: the test listings that cover them all.
+func RemoteChecks(targets []config.SyncTarget) []config.SyncTarget {
+	var checks []config.SyncTarget
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		if t.Type != config.Remote {
+			continue
+		}
+		key := t.RemoteName + "\x00" + strings.Join(rcloneFlags(t.Rclone), "\x00")
+		if !seen[key] {
+			seen[key] = true
+			checks = append(checks, t)
+		}
+	}
+	return checks
+}
+
+// ParseRemoteOptions turns "key=value" arguments into backend options.
+func ParseRemoteOptions(args []string) (map[string]string, error) {
+	options := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid backend option %q, expected key=value", arg)
+		}
+		options[key] = value
+	}
+	return options, nil
+}
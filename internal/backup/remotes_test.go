@@ -0,0 +1,141 @@
+// /internal/backup/remotes_test.go
+package backup
+
+import (
+	"context"
+	"os"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRemoteOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"none", nil, map[string]string{}, false},
+		{"options", []string{"scope=drive", "root_folder_id="}, map[string]string{"scope": "drive", "root_folder_id": ""}, false},
+		{"value containing =", []string{"token={\"a\":\"b=c\"}"}, map[string]string{"token": "{\"a\":\"b=c\"}"}, false},
+		{"missing =", []string{"scope"}, nil, true},
+		{"empty key", []string{"=drive"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRemoteOptions(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRemoteOptions error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRemoteOptions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteChecks(t *testing.T) {
+	targets := []config.SyncTarget{
+		config.ParseTarget("gdrive:HKSaves"),
+		config.ParseTarget("C:\\Saves"),
+		config.ParseTarget("gdrive:Backups|300"),
+		// Retries are not a flag of the test listing.
+		config.ParseTarget("gdrive:Snapshots|||format=snapshot,retries=3"),
+		config.ParseTarget("gdrive:Slow|||bwlimit=1M"),
+		config.ParseTarget("gdrive:Slow2|||bwlimit=1M"),
+		config.ParseTarget("gdrive:Flags|||--drive-chunk-size=64M"),
+		config.ParseTarget("box:HKSaves"),
+	}
+	var got []string
+	for _, t := range RemoteChecks(targets) {
+		got = append(got, t.Original)
+	}
+	want := []string{"gdrive:HKSaves", "gdrive:Slow|||bwlimit=1M", "gdrive:Flags|||--drive-chunk-size=64M", "box:HKSaves"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RemoteChecks = %v, want %v", got, want)
+	}
+}
+
+func TestCreateRemote(t *testing.T) {
+	const done = `echo '{"State":"","Option":null,"Error":""}'`
+	question := func(name, def string) string {
+		return `echo '{"State":"step1","Option":{"Name":"` + name + `","DefaultStr":"` + def + `"},"Error":""}'`
+	}
+
+	tests := []struct {
+		name string
+		// remote is created; "existing" is already in the rclone config.
+		remote string
+		// first and next answer the initial and the continued `config create`.
+		first, next string
+		wantErr     string
+		wantCalls   []string
+		wantDeleted bool
+	}{
+		{
+			name: "created at once", remote: "new", first: done,
+			wantCalls: []string{"config create new drive scope drive --non-interactive"},
+		},
+		{
+			name: "questions take their defaults", remote: "new", first: question("region", "eu"), next: done,
+			wantCalls: []string{"config create new drive --non-interactive --continue --state step1 --result eu"},
+		},
+		{
+			name: "a token is never refreshed", remote: "new", first: question("config_refresh_token", "true"), next: done,
+			wantCalls: []string{"--state step1 --result false"},
+		},
+		{
+			name: "browser login is rejected", remote: "new", first: question("config_is_local", "true"),
+			wantErr: "--token", wantDeleted: true,
+		},
+		{
+			name: "an existing remote is kept on failure", remote: "existing", first: question("config_is_local", "true"),
+			wantErr: "--token",
+		},
+		{
+			name: "rclone reports an error", remote: "new", first: `echo '{"Error":"invalid scope"}'`,
+			wantErr: "invalid scope", wantDeleted: true,
+		},
+		{
+			name: "rclone fails", remote: "new", first: "exit 1",
+			wantErr: "could not create remote", wantDeleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argsFile := useFakeRclone(t, `[ "$1" = "--config" ] && shift 2
+case "$1 $2" in
+"listremotes "*) echo 'existing:' ;;
+"config create")
+	case "$*" in
+	*--continue*) `+tt.next+` ;;
+	*) `+tt.first+` ;;
+	esac ;;
+esac`)
+			err := CreateRemote(context.Background(), &config.Config{}, tt.remote, "drive", map[string]string{"scope": "drive"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CreateRemote error = %v, want one containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("CreateRemote: %v", err)
+			}
+
+			data, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls := string(data)
+			for _, call := range tt.wantCalls {
+				if !strings.Contains(calls, call) {
+					t.Errorf("rclone was not called with %q; calls:\n%s", call, calls)
+				}
+			}
+			if deleted := strings.Contains(calls, "config delete "+tt.remote); deleted != tt.wantDeleted {
+				t.Errorf("remote deleted = %v, want %v; calls:\n%s", deleted, tt.wantDeleted, calls)
+			}
+		})
+	}
+}
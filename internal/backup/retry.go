@@ -0,0 +1,313 @@
+// /internal/backup/retry.go
+package backup
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/util"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	retryQueueFile   = "retry-queue.json"
+	retrySpoolDir    = "retry"
+	retryBaseBackoff = 30 * time.Second
+	retryMaxBackoff  = 30 * time.Minute
+)
+
+// RetryEntry records a target that is behind because a sync to it failed.
+type RetryEntry struct {
+	Target      string    `json:"target"`
+	Spool       string    `json:"spool"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	Since       time.Time `json:"since"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+// RetryQueue is a persistent record of failed syncs. A copy of the saves that
+// failed to sync is kept in a spool directory, so retries still have something
+// to send after the session's save directory has been restored.
+type RetryQueue struct {
+	path     string
+	spoolDir string
+	mu       sync.Mutex
+	entries  map[string]*RetryEntry
+}
+
+// OpenRetryQueue loads the retry queue stored in dataDir, creating an empty one if needed.
+func OpenRetryQueue(dataDir string) (*RetryQueue, error) {
+	r := &RetryQueue{
+		path:     filepath.Join(dataDir, retryQueueFile),
+		spoolDir: filepath.Join(dataDir, retrySpoolDir),
+		entries:  make(map[string]*RetryEntry),
+	}
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read retry queue: %w", err)
+	}
+	var entries []*RetryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse retry queue %s: %w", r.path, err)
+	}
+	for _, e := range entries {
+		r.entries[targetIdentity(config.ParseTarget(e.Target))] = e
+	}
+	return r, nil
+}
+
+// Record marks target as behind, keeping a copy of sourceDir to retry with
+// later. An empty sourceDir keeps the copy made by an earlier failure.
+func (r *RetryQueue) Record(target config.SyncTarget, sourceDir string, syncErr error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := targetIdentity(target)
+	e, ok := r.entries[key]
+	if !ok {
+		e = &RetryEntry{Target: target.Original, Since: time.Now(), Spool: filepath.Join(r.spoolDir, spoolName(key))}
+		r.entries[key] = e
+	}
+	if sourceDir != "" && filepath.Clean(sourceDir) != filepath.Clean(e.Spool) {
+		if err := refreshSpool(sourceDir, e.Spool); err != nil {
+			logger.Error("Could not keep a copy of the saves for retrying '%s': %v", target.Original, err)
+		}
+	}
+	e.Attempts++
+	e.LastError = syncErr.Error()
+	e.NextAttempt = time.Now().Add(retryBackoff(e.Attempts))
+	logger.Warn("Target '%s' is behind. Next retry at %s.", target.Original, e.NextAttempt.Format(time.TimeOnly))
+	r.save()
+}
+
+// Resolve clears any pending retry for target after a successful sync.
+func (r *RetryQueue) Resolve(target config.SyncTarget) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := targetIdentity(target)
+	e, ok := r.entries[key]
+	if !ok {
+		return
+	}
+	delete(r.entries, key)
+	_ = os.RemoveAll(e.Spool)
+	logger.Info("Target '%s' is up to date again.", target.Original)
+	r.save()
+}
+
+// Entries returns a snapshot of all pending retries, oldest first.
+func (r *RetryQueue) Entries() []RetryEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]RetryEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Since.Before(entries[j].Since) })
+	return entries
+}
+
+// Lookup returns the pending retry for target, if any.
+func (r *RetryQueue) Lookup(target config.SyncTarget) (RetryEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[targetIdentity(target)]
+	if !ok {
+		return RetryEntry{}, false
+	}
+	return *e, true
+}
+
+// SpoolTarget returns the copy of the saves kept for retrying target, as a
+// local target, if target is behind.
+func (r *RetryQueue) SpoolTarget(target config.SyncTarget) (config.SyncTarget, bool) {
+	e, ok := r.Lookup(target)
+	if !ok || !util.PathExists(e.Spool) {
+		return config.SyncTarget{}, false
+	}
+	return config.SyncTarget{
+		Type:     config.Local,
+		Path:     e.Spool,
+		Original: fmt.Sprintf("%s (retry copy)", target.Original),
+	}, true
+}
+
+// SettleStale checks every entry against its target before a session picks
+// its sources. A target that was written to since its backup failed, e.g. by
+// another machine, is not behind any more: its copy is moved to the conflicts
+// directory instead of being pushed over the newer saves. Copies that are
+// still newer stay queued, so that the session can pick them as a source.
+func (r *RetryQueue) SettleStale(ctx context.Context, cfg *config.Config) {
+	for _, e := range r.Entries() {
+		r.settle(ctx, cfg, e)
+	}
+}
+
+// Start retries due entries through queue in the background until ctx is
+// cancelled. The returned function waits for the retrying to stop, including
+// a retry that was running when ctx was cancelled.
+func (r *RetryQueue) Start(ctx context.Context, queue *SyncQueue) (wait func()) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.Run(ctx, queue)
+	}()
+	return wg.Wait
+}
+
+// Run retries due entries through queue until ctx is cancelled.
+func (r *RetryQueue) Run(ctx context.Context, queue *SyncQueue) {
+	for {
+		wait := r.retryDue(queue)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// RetryPending keeps retrying until every entry succeeds or timeout elapses.
+// Entries left over stay in the queue for the next launcher start.
+func (r *RetryQueue) RetryPending(ctx context.Context, queue *SyncQueue, timeout time.Duration) {
+	if len(r.Entries()) == 0 {
+		return
+	}
+	logger.Info("--- Retrying Failed Backups (up to %s) ---", timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		wait := r.retryDue(queue)
+		if len(r.Entries()) == 0 {
+			logger.Info("✅ All failed backups have been retried successfully.")
+			return
+		}
+		select {
+		case <-ctx.Done():
+			logger.Warn("%d target(s) are still behind. They will be retried on the next start.", len(r.Entries()))
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryDue syncs every entry whose backoff has elapsed and returns how long
+// to wait until the next entry becomes due.
+func (r *RetryQueue) retryDue(queue *SyncQueue) time.Duration {
+	now := time.Now()
+	wait := retryMaxBackoff
+	for _, e := range r.Entries() {
+		if until := e.NextAttempt.Sub(now); until > 0 {
+			wait = min(wait, until)
+			continue
+		}
+		r.retry(queue, e)
+	}
+	for _, e := range r.Entries() {
+		wait = min(wait, max(time.Until(e.NextAttempt), time.Second))
+	}
+	return wait
+}
+
+func (r *RetryQueue) retry(queue *SyncQueue, e RetryEntry) {
+	if r.settle(queue.ctx, queue.cfg, e) {
+		return
+	}
+	logger.Info("Retrying backup to '%s' (attempt %d)...", e.Target, e.Attempts+1)
+	spool := config.SyncTarget{Type: config.Local, Path: e.Spool}
+	if err := queue.Sync(spool, config.ParseTarget(e.Target)); err != nil {
+		logger.Error("Retry for '%s' failed: %v", e.Target, err)
+	}
+}
+
+// settle reports whether the slots the target of e stores are newer than the
+// copy kept for retrying it, in which case the copy is moved to the conflicts directory and
+// the entry dropped. A target that cannot be checked is left to the retry.
+func (r *RetryQueue) settle(ctx context.Context, cfg *config.Config, e RetryEntry) bool {
+	target := config.ParseTarget(e.Target)
+	spoolModTime, err := util.GetDirLastModTime(e.Spool)
+	if err != nil {
+		return false
+	}
+	targetModTime, err := SlotsLastModTime(ctx, cfg, target)
+	if err != nil || !targetModTime.Truncate(time.Second).After(spoolModTime.Truncate(time.Second)) {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := targetIdentity(target)
+	if _, ok := r.entries[key]; !ok {
+		// Resolved in the meantime.
+		return true
+	}
+	conflictDir := filepath.Join(cfg.DataDir, conflictsDirName,
+		fmt.Sprintf("%s-%s", spoolName(targetKey(target)), time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(filepath.Dir(conflictDir), 0755); err != nil {
+		logger.Error("Could not keep the stale retry copy of '%s': %v", target.Original, err)
+		return true
+	}
+	if err := os.Rename(e.Spool, conflictDir); err != nil {
+		logger.Error("Could not keep the stale retry copy of '%s': %v", target.Original, err)
+		return true
+	}
+	delete(r.entries, key)
+	// Shown even in quiet mode: the player must know to look at the conflict.
+	logger.Prompt("⚠️ '%s' changed after a backup to it failed, so the backup was not retried. The saves kept for it were moved to '%s'.", target.Original, conflictDir)
+	r.save()
+	return true
+}
+
+// save writes the queue to disk. The caller must hold r.mu.
+func (r *RetryQueue) save() {
+	entries := make([]*RetryEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(r.path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(r.path, data, 0644)
+	}
+	if err != nil {
+		logger.Error("Could not save retry queue to '%s': %v", r.path, err)
+	}
+}
+
+func refreshSpool(sourceDir, spool string) error {
+	if err := os.RemoveAll(spool); err != nil {
+		return err
+	}
+	if !util.PathExists(sourceDir) {
+		return fmt.Errorf("source '%s' does not exist", sourceDir)
+	}
+	return util.CopyDir(sourceDir, spool)
+}
+
+func retryBackoff(attempts int) time.Duration {
+	backoff := retryBaseBackoff
+	for i := 1; i < attempts && backoff < retryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, retryMaxBackoff)
+}
+
+func spoolName(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])[:12]
+}
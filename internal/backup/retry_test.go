@@ -0,0 +1,223 @@
+// /internal/backup/retry_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, retryBaseBackoff},
+		{2, 2 * retryBaseBackoff},
+		{3, 4 * retryBaseBackoff},
+		{6, 32 * retryBaseBackoff},
+		{7, retryMaxBackoff},
+		{100, retryMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRetryQueueRecordResolve(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	live := filepath.Join(dir, "live")
+	target := config.ParseTarget(filepath.Join(dir, "target"))
+	other := config.ParseTarget(filepath.Join(dir, "other"))
+
+	r, err := OpenRetryQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, live, map[string]string{"user1.dat": "first"})
+	r.Record(target, live, errors.New("offline"))
+	e, ok := r.Lookup(target)
+	if !ok || e.Attempts != 1 || e.LastError != "offline" {
+		t.Fatalf("Lookup after Record = %+v, %v", e, ok)
+	}
+	if _, ok := r.Lookup(other); ok {
+		t.Fatal("an unrelated target is behind")
+	}
+
+	steps := []struct {
+		name   string
+		live   map[string]string
+		source string
+		want   map[string]string
+	}{
+		{"newer saves refresh the spool", map[string]string{"user1.dat": "second", "user2.dat": "new"}, live, map[string]string{"user1.dat": "second", "user2.dat": "new"}},
+		{"no source keeps the spool", map[string]string{"user1.dat": "third"}, "", map[string]string{"user1.dat": "second", "user2.dat": "new"}},
+		{"a retry from the spool keeps it", map[string]string{"user1.dat": "third"}, e.Spool, map[string]string{"user1.dat": "second", "user2.dat": "new"}},
+	}
+	for i, step := range steps {
+		os.RemoveAll(live)
+		writeFiles(t, live, step.live)
+		r.Record(target, step.source, errors.New("still offline"))
+		if got := readFiles(t, e.Spool); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: spool holds %v, want %v", step.name, got, step.want)
+		}
+		e, _ := r.Lookup(target)
+		if e.Attempts != i+2 {
+			t.Errorf("%s: %d attempts, want %d", step.name, e.Attempts, i+2)
+		}
+		if until := time.Until(e.NextAttempt); until <= 0 || until > retryBackoff(e.Attempts) {
+			t.Errorf("%s: next attempt in %s, want within %s", step.name, until, retryBackoff(e.Attempts))
+		}
+	}
+
+	// The queue outlives the session.
+	reopened, err := OpenRetryQueue(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reopened.Lookup(target); !ok || got.Attempts != 4 || got.Spool != e.Spool {
+		t.Fatalf("Lookup after reopening = %+v, %v", got, ok)
+	}
+	spool, ok := reopened.SpoolTarget(target)
+	if !ok || spool.Path != e.Spool {
+		t.Errorf("SpoolTarget = %+v, %v", spool, ok)
+	}
+
+	reopened.Resolve(target)
+	if _, ok := reopened.Lookup(target); ok {
+		t.Error("target is still behind after Resolve")
+	}
+	if _, err := os.Stat(e.Spool); !os.IsNotExist(err) {
+		t.Error("Resolve left the spool behind")
+	}
+	if again, err := OpenRetryQueue(dataDir); err != nil || len(again.Entries()) != 0 {
+		t.Errorf("reopened queue after Resolve = %v, %v", again.Entries(), err)
+	}
+}
+
+func TestRetryQueueStart(t *testing.T) {
+	dir := t.TempDir()
+	target := config.ParseTarget(filepath.Join(dir, "target"))
+	live := filepath.Join(dir, "live")
+	writeFiles(t, live, map[string]string{"user1.dat": "saved"})
+
+	r, err := OpenRetryQueue(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Record(target, live, errors.New("offline"))
+	// Make the entry due at once.
+	r.entries[targetIdentity(target)].NextAttempt = time.Now()
+
+	q := NewSyncQueue(context.Background(), &config.Config{}, r)
+	ctx, cancel := context.WithCancel(context.Background())
+	wait := r.Start(ctx, q)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, behind := r.Lookup(target); !behind {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the due retry did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	wait()
+	// Once wait returns, nothing is left to race with draining the queue.
+	q.Close()
+	if got := readFiles(t, target.Path); got["user1.dat"] != "saved" {
+		t.Errorf("target holds %v after the retry", got)
+	}
+}
+
+func TestRetryQueueSettleStale(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DataDir: filepath.Join(dir, "data")}
+	stale := config.ParseTarget(filepath.Join(dir, "stale"))
+	ahead := config.ParseTarget(filepath.Join(dir, "ahead"))
+	live := filepath.Join(dir, "live")
+	old := time.Now().Add(-72 * time.Hour)
+
+	r, err := OpenRetryQueue(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, live, map[string]string{"user1.dat": "spooled"})
+	setModTime(t, filepath.Join(live, "user1.dat"), old)
+	r.Record(stale, live, errors.New("offline"))
+	r.Record(ahead, live, errors.New("offline"))
+
+	// Another machine uploaded newer saves to one target since.
+	writeFiles(t, stale.Path, map[string]string{"user1.dat": "newer elsewhere"})
+	writeFiles(t, ahead.Path, map[string]string{"user1.dat": "older"})
+	setModTime(t, filepath.Join(ahead.Path, "user1.dat"), old.Add(-time.Hour))
+	staleEntry, _ := r.Lookup(stale)
+
+	r.SettleStale(context.Background(), cfg)
+
+	if got := readFiles(t, stale.Path); got["user1.dat"] != "newer elsewhere" {
+		t.Errorf("newer target holds %v after settling", got)
+	}
+	if _, behind := r.Lookup(stale); behind {
+		t.Error("a target newer than its retry copy is still behind")
+	}
+	if _, err := os.Stat(staleEntry.Spool); !os.IsNotExist(err) {
+		t.Error("the stale retry copy was left in the spool")
+	}
+	conflicts, err := os.ReadDir(filepath.Join(cfg.DataDir, conflictsDirName))
+	if err != nil || len(conflicts) != 1 {
+		t.Fatalf("conflicts directory holds %v, %v; want the stale retry copy", conflicts, err)
+	}
+	if got := readFiles(t, filepath.Join(cfg.DataDir, conflictsDirName, conflicts[0].Name())); got["user1.dat"] != "spooled" {
+		t.Errorf("conflict copy holds %v", got)
+	}
+
+	// A copy that is still newer is left for the session to pick, not pushed.
+	if _, behind := r.Lookup(ahead); !behind {
+		t.Error("a target older than its retry copy is no longer behind")
+	}
+	if got := readFiles(t, ahead.Path); got["user1.dat"] != "older" {
+		t.Errorf("older target holds %v after settling, want it untouched", got)
+	}
+
+	// Due retries check the target the same way before pushing.
+	writeFiles(t, live, map[string]string{"user1.dat": "spooled again"})
+	setModTime(t, filepath.Join(live, "user1.dat"), old)
+	r.Record(stale, live, errors.New("offline"))
+	q := NewSyncQueue(context.Background(), cfg, r)
+	defer q.Close()
+	e, _ := r.Lookup(stale)
+	r.retry(q, e)
+	if got := readFiles(t, stale.Path); got["user1.dat"] != "newer elsewhere" {
+		t.Errorf("retry overwrote a newer target with %v", got)
+	}
+
+	// Another player saving a slot this machine doesn't map is no conflict.
+	shared := config.ParseTarget(filepath.Join(dir, "shared") + "|||slots=1")
+	writeFiles(t, shared.Path, map[string]string{"user1.dat": "older", "user2.dat": "another player"})
+	setModTime(t, filepath.Join(shared.Path, "user1.dat"), old.Add(-time.Hour))
+	r.Record(shared, live, errors.New("offline"))
+	r.SettleStale(context.Background(), cfg)
+	if _, behind := r.Lookup(shared); !behind {
+		t.Error("a change to an unmapped slot settled the retry of a slot-mapped target")
+	}
+	if got := readFiles(t, shared.Path); got["user1.dat"] != "older" || got["user2.dat"] != "another player" {
+		t.Errorf("slot-mapped target holds %v after settling, want it untouched", got)
+	}
+}
+
+func setModTime(t *testing.T, path string, mod time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
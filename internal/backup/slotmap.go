@@ -0,0 +1,201 @@
+// /internal/backup/slotmap.go
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"pirated-hollow-knight/internal/util"
+)
+
+// syncFromSlotMap copies a target with a slot map to destination, with its
+// slots renumbered to the live ones. Slots outside the map are left out.
+func syncFromSlotMap(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget) error {
+	staging, err := os.MkdirTemp("", "hk-slots-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	stored := unmapped(source)
+	storedDir := filepath.Join(staging, "stored")
+	if err := fetchStored(ctx, cfg, stored, storedDir); err != nil {
+		return err
+	}
+	liveDir := filepath.Join(staging, "live")
+	if err := copyMappedSlots(storedDir, liveDir, source.Slots.Inverse()); err != nil {
+		return err
+	}
+	logger.Info("Read slots %s of '%s'.", source.SlotSpec, source.Original)
+	return Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: liveDir}, destination)
+}
+
+// syncToSlotMap copies the mapped slots of source into a target with a slot
+// map, renumbered to the target's slots. Every other slot the target stores,
+// and every mapped slot source doesn't have, is kept as it is.
+func syncToSlotMap(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget) error {
+	staging, err := os.MkdirTemp("", "hk-slots-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	sourceDir := source.Path
+	if !source.IsPlainLocal() {
+		sourceDir = filepath.Join(staging, "source")
+		if err := Sync(ctx, cfg, source, config.SyncTarget{Type: config.Local, Path: sourceDir}); err != nil {
+			return err
+		}
+	}
+
+	// The target is rewritten as a whole, so it is read first.
+	stored := unmapped(destination)
+	storedDir := filepath.Join(staging, "stored")
+	if err := fetchStored(ctx, cfg, stored, storedDir); err != nil {
+		return err
+	}
+	live, err := saves.Slots(sourceDir)
+	if err != nil {
+		return err
+	}
+	replaced := make(map[int]bool)
+	for _, slot := range live {
+		if to, mapped := destination.Slots[slot]; mapped {
+			replaced[to] = true
+		}
+	}
+	entries, err := os.ReadDir(storedDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		slot, isSlot := saves.SlotOf(e.Name())
+		if (isSlot && replaced[slot]) || e.Name() == manifestFile {
+			if err := os.RemoveAll(filepath.Join(storedDir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	if err := copyMappedSlots(sourceDir, storedDir, destination.Slots); err != nil {
+		return err
+	}
+	logger.Info("Writing slots %s to '%s'.", destination.SlotSpec, destination.Original)
+	if stored.IsPlainLocal() {
+		return replaceLocalDir(ctx, cfg, storedDir, stored)
+	}
+	return Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: storedDir}, stored)
+}
+
+// replaceLocalDir replaces a plain local target with the contents of dir. The
+// copy is made next to the target and renamed into place, so a copy that
+// fails half way leaves the slots of the target's other users as they were.
+func replaceLocalDir(ctx context.Context, cfg *config.Config, dir string, target config.SyncTarget) error {
+	next := target
+	next.Path = filepath.Clean(target.Path) + ".hk-new"
+	previous := filepath.Clean(target.Path) + ".hk-old"
+	for _, p := range []string{next.Path, previous} {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	if err := Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: dir}, next); err != nil {
+		_ = os.RemoveAll(next.Path)
+		return err
+	}
+	if util.PathExists(target.Path) {
+		if err := os.Rename(target.Path, previous); err != nil {
+			_ = os.RemoveAll(next.Path)
+			return err
+		}
+	}
+	if err := os.Rename(next.Path, target.Path); err != nil {
+		_ = os.Rename(previous, target.Path)
+		return err
+	}
+	return os.RemoveAll(previous)
+}
+
+// spoolSource returns a directory with what a failed sync from source to
+// destination has to send on retry: the saves in live slot numbering, limited
+// to the slots destination maps. Retries apply destination's map again, so
+// spools never hold a target's own numbering. cleanup removes the directory
+// if it is a staged copy.
+func spoolSource(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget) (dir string, cleanup func(), err error) {
+	if source.Slots == nil && destination.Slots == nil {
+		return source.Path, func() {}, nil
+	}
+	staging, err := os.MkdirTemp("", "hk-spool-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { _ = os.RemoveAll(staging) }
+	dir = source.Path
+	if source.Slots != nil {
+		dir = filepath.Join(staging, "live")
+		if err := Sync(ctx, cfg, source, config.SyncTarget{Type: config.Local, Path: dir}); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+	if destination.Slots != nil {
+		mapped := make(config.SlotMap, len(destination.Slots))
+		for slot := range destination.Slots {
+			mapped[slot] = slot
+		}
+		limited := filepath.Join(staging, "spool")
+		if err := copyMappedSlots(dir, limited, mapped); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		dir = limited
+	}
+	return dir, cleanup, nil
+}
+
+// unmapped returns target without its slot map, to read or write what it
+// stores as it is.
+func unmapped(target config.SyncTarget) config.SyncTarget {
+	target.Slots, target.SlotSpec = nil, ""
+	return target
+}
+
+// fetchStored copies what target stores into dir. A target nothing has been
+// saved to yet leaves dir empty.
+func fetchStored(ctx context.Context, cfg *config.Config, target config.SyncTarget, dir string) error {
+	if modTime, err := LastModTime(ctx, cfg, target); err == nil && modTime.IsZero() {
+		return os.MkdirAll(dir, 0755)
+	}
+	return Sync(ctx, cfg, target, config.SyncTarget{Type: config.Local, Path: dir})
+}
+
+// copyMappedSlots copies the files of every slot in m from src to dst,
+// renamed to the slot m maps it to, along with the files that belong to no
+// slot. The integrity manifest is left behind, since it describes src.
+func copyMappedSlots(src, dst string, m config.SlotMap) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || name == manifestFile {
+			continue
+		}
+		if slot, ok := saves.SlotOf(name); ok {
+			to, mapped := m[slot]
+			if !mapped {
+				continue
+			}
+			name = saves.RenumberFile(name, slot, to)
+		}
+		if err := util.CopyFile(filepath.Join(src, e.Name()), filepath.Join(dst, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// /internal/backup/slotmap_test.go
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestSyncToSlotMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		slots  string
+		live   map[string]string
+		stored map[string]string
+		want   map[string]string
+	}{
+		{
+			name:  "into an empty target",
+			slots: "1:3",
+			live:  map[string]string{"user1.dat": "a1", "user1.dat.bak1": "a1 backup", "user2.dat": "a2"},
+			want:  map[string]string{"user3.dat": "a1", "user3.dat.bak1": "a1 backup"},
+		},
+		{
+			name:   "keeps the slots of other users",
+			slots:  "1:3,2:4",
+			live:   map[string]string{"user1.dat": "a1", "user2.dat": "a2", "shared.dat": "settings"},
+			stored: map[string]string{"user3.dat": "old3", "user3.dat.bak1": "old3 backup", "user4.dat": "old4", "user5.dat": "b5"},
+			want:   map[string]string{"user3.dat": "a1", "user4.dat": "a2", "user5.dat": "b5", "shared.dat": "settings"},
+		},
+		{
+			name:   "keeps mapped slots the source doesn't have",
+			slots:  "1:3,2:4",
+			live:   map[string]string{"user1.dat": "a1"},
+			stored: map[string]string{"user3.dat": "old3", "user4.dat": "old4"},
+			want:   map[string]string{"user3.dat": "a1", "user4.dat": "old4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			liveDir, targetDir := filepath.Join(dir, "live"), filepath.Join(dir, "target")
+			writeFiles(t, liveDir, tt.live)
+			if tt.stored != nil {
+				writeFiles(t, targetDir, tt.stored)
+			}
+			destination := config.ParseTarget(targetDir + "|||slots=" + tt.slots)
+			if err := Sync(context.Background(), &config.Config{}, config.SyncTarget{Type: config.Local, Path: liveDir}, destination); err != nil {
+				t.Fatalf("Sync: %v", err)
+			}
+			if got := readFiles(t, targetDir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("target holds %v, want %v", got, tt.want)
+			}
+			for _, leftover := range []string{targetDir + ".hk-new", targetDir + ".hk-old"} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Errorf("%s was left behind", leftover)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncFromSlotMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		slots  string
+		stored map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "renumbers to the live slots",
+			slots:  "1:3,2:4",
+			stored: map[string]string{"user3.dat": "a1", "user3.dat.bak1": "a1 backup", "user4.dat": "a2", "user5.dat": "b5"},
+			want:   map[string]string{"user1.dat": "a1", "user1.dat.bak1": "a1 backup", "user2.dat": "a2"},
+		},
+		{
+			name:   "leaves out missing slots",
+			slots:  "1:3,2:4",
+			stored: map[string]string{"user4.dat": "a2", "shared.dat": "settings"},
+			want:   map[string]string{"user2.dat": "a2", "shared.dat": "settings"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			liveDir, targetDir := filepath.Join(dir, "live"), filepath.Join(dir, "target")
+			writeFiles(t, targetDir, tt.stored)
+			source := config.ParseTarget(targetDir + "|||slots=" + tt.slots)
+			if err := Sync(context.Background(), &config.Config{}, source, config.SyncTarget{Type: config.Local, Path: liveDir}); err != nil {
+				t.Fatalf("Sync: %v", err)
+			}
+			if got := readFiles(t, liveDir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("live directory holds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpoolSource(t *testing.T) {
+	dir := t.TempDir()
+	liveDir, mappedDir := filepath.Join(dir, "live"), filepath.Join(dir, "mapped")
+	writeFiles(t, liveDir, map[string]string{"user1.dat": "a1", "user2.dat": "a2"})
+	writeFiles(t, mappedDir, map[string]string{"user3.dat": "a1", "user4.dat": "a2"})
+	live := config.SyncTarget{Type: config.Local, Path: liveDir}
+
+	tests := []struct {
+		name                string
+		source, destination config.SyncTarget
+		want                map[string]string
+	}{
+		{
+			name:        "to a mapped target",
+			source:      live,
+			destination: config.ParseTarget("bad:hk|||slots=1:4"),
+			want:        map[string]string{"user1.dat": "a1"},
+		},
+		{
+			name:        "from a mapped target",
+			source:      config.ParseTarget(mappedDir + "|||slots=1:3,2:4"),
+			destination: config.ParseTarget("bad:hk"),
+			want:        map[string]string{"user1.dat": "a1", "user2.dat": "a2"},
+		},
+		{
+			name:        "between mapped targets",
+			source:      config.ParseTarget(mappedDir + "|||slots=1:3,2:4"),
+			destination: config.ParseTarget("bad:hk|||slots=2:1"),
+			want:        map[string]string{"user2.dat": "a2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spool, cleanup, err := spoolSource(context.Background(), &config.Config{}, tt.source, tt.destination)
+			if err != nil {
+				t.Fatalf("spoolSource: %v", err)
+			}
+			defer cleanup()
+			if got := readFiles(t, spool); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("spool holds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,430 @@
+// /internal/backup/snapshot.go
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/config"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	snapshotsDir = "snapshots"
+	blobsDir     = "blobs"
+	// snapshotIDFormat is the time layout snapshot IDs are written in.
+	snapshotIDFormat = "20060102T150405.000Z"
+)
+
+// Snapshot is the manifest of one snapshot in a snapshot repository. File
+// contents are stored once per distinct SHA-256 as zstd-compressed blobs.
+type Snapshot struct {
+	ID    string         `json:"id"`
+	Time  time.Time      `json:"time"`
+	Files []SnapshotFile `json:"files"`
+}
+
+// SnapshotFile describes one file of a snapshot.
+type SnapshotFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"sha256"`
+}
+
+// LastModTime returns the newest modification time of the files in the snapshot.
+func (s *Snapshot) LastModTime() time.Time {
+	var latest time.Time
+	for _, f := range s.Files {
+		if f.ModTime.After(latest) {
+			latest = f.ModTime
+		}
+	}
+	return latest
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func zstdCodecs() (*zstd.Encoder, *zstd.Decoder) {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+		zstdDecoder, _ = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder
+}
+
+// ListSnapshots returns every snapshot stored in target, oldest first.
+func ListSnapshots(ctx context.Context, cfg *config.Config, target config.SyncTarget) ([]*Snapshot, error) {
+	store := newFileStore(cfg, target)
+	names, err := store.list(ctx, snapshotsDir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return loadSnapshots(ctx, store, names)
+}
+
+// RestoreSnapshot writes the snapshot with the given ID (or the latest one if
+// id is empty) into destDir, replacing its contents.
+func RestoreSnapshot(ctx context.Context, cfg *config.Config, target config.SyncTarget, id, destDir string) (*Snapshot, error) {
+	store := newFileStore(cfg, target)
+	var snap *Snapshot
+	var err error
+	if id == "" {
+		snap, err = latestSnapshot(ctx, store)
+		if err == nil && snap == nil {
+			err = fmt.Errorf("'%s' does not contain any snapshots", target.Original)
+		}
+	} else if !validSnapshotID(id) {
+		err = apperr.Errorf(apperr.ConfigInvalid, "%q is not a snapshot ID", id)
+	} else {
+		var snaps []*Snapshot
+		snaps, err = loadSnapshots(ctx, store, []string{path.Join(snapshotsDir, id+".json")})
+		if err == nil {
+			snap = snaps[0]
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snap, restoreSnapshot(ctx, store, snap, destDir)
+}
+
+// CreateSnapshot stores the contents of sourceDir as a new snapshot in target.
+// Only blobs the repository does not have yet are written. If the files are
+// identical to the latest snapshot, no new snapshot is created.
+func CreateSnapshot(ctx context.Context, cfg *config.Config, sourceDir string, target config.SyncTarget) error {
+	store := newFileStore(cfg, target)
+
+	files, err := hashTree(sourceDir)
+	if err != nil {
+		return fmt.Errorf("could not read '%s': %w", sourceDir, err)
+	}
+	latest, err := latestSnapshot(ctx, store)
+	if err != nil {
+		return err
+	}
+	if latest != nil && sameFiles(latest.Files, files) {
+		logger.Info("No changes since snapshot %s of '%s'.", latest.ID, target.Original)
+		return nil
+	}
+
+	existing, err := store.list(ctx, blobsDir)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		have[name] = true
+	}
+
+	staging, err := os.MkdirTemp("", "hk-snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	encoder, _ := zstdCodecs()
+	newBlobs := 0
+	for _, f := range files {
+		blob := blobPath(f.Hash)
+		if have[blob] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(sourceDir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(staging, filepath.FromSlash(blob))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, encoder.EncodeAll(data, nil), 0644); err != nil {
+			return err
+		}
+		have[blob] = true
+		newBlobs++
+	}
+
+	// Blobs go up before the manifest, so a manifest never points at missing data.
+	if newBlobs > 0 {
+		logger.Info("Uploading %d new blob(s) to '%s'...", newBlobs, target.Original)
+		if err := store.put(ctx, staging); err != nil {
+			return fmt.Errorf("could not store blobs: %w", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	snap := &Snapshot{ID: now.Format(snapshotIDFormat), Time: now, Files: files}
+	if err := checkSnapshotBlobs(ctx, store, snap); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestRoot, err := os.MkdirTemp("", "hk-manifest-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(manifestRoot)
+	if err := os.MkdirAll(filepath.Join(manifestRoot, snapshotsDir), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(manifestRoot, snapshotsDir, snap.ID+".json"), data, 0644); err != nil {
+		return err
+	}
+	if err := store.put(ctx, manifestRoot); err != nil {
+		return fmt.Errorf("could not store snapshot manifest: %w", err)
+	}
+	logger.Info("Created snapshot %s in '%s' (%d file(s), %d new blob(s)).", snap.ID, target.Original, len(files), newBlobs)
+	return nil
+}
+
+// latestSnapshotModTime returns the newest file modification time in the
+// latest snapshot of target, or zero if it has none.
+func latestSnapshotModTime(ctx context.Context, cfg *config.Config, target config.SyncTarget) (time.Time, error) {
+	snap, err := latestSnapshot(ctx, newFileStore(cfg, target))
+	if err != nil || snap == nil {
+		return time.Time{}, err
+	}
+	return snap.LastModTime(), nil
+}
+
+// syncSnapshot handles syncs where either side is a snapshot repository.
+// Reading from a repository restores its latest snapshot; writing to one
+// creates a new snapshot.
+func syncSnapshot(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget) error {
+	if source.Format == config.FormatSnapshot {
+		localDest := destination.Path
+		if !destination.IsPlainLocal() {
+			tmp, err := os.MkdirTemp("", "hk-restore-*")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tmp)
+			localDest = tmp
+		}
+
+		store := newFileStore(cfg, source)
+		snap, err := latestSnapshot(ctx, store)
+		if err != nil {
+			return err
+		}
+		if snap == nil {
+			snap = &Snapshot{}
+		}
+		if err := restoreSnapshot(ctx, store, snap, localDest); err != nil {
+			return err
+		}
+		if source.Type == config.Remote {
+			updateMirror(cfg, source, localDest, false)
+		}
+		if localDest == destination.Path {
+			return nil
+		}
+		return Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: localDest, Format: config.FormatMirror}, destination)
+	}
+
+	sourceDir := source.Path
+	if !source.IsPlainLocal() {
+		tmp, err := os.MkdirTemp("", "hk-snapshot-source-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		if err := Sync(ctx, cfg, source, config.SyncTarget{Type: config.Local, Path: tmp, Format: config.FormatMirror}); err != nil {
+			return err
+		}
+		sourceDir = tmp
+	}
+	if err := CreateSnapshot(ctx, cfg, sourceDir, destination); err != nil {
+		return fmt.Errorf("snapshot of '%s' to '%s' failed: %w", source.Original, destination.Original, err)
+	}
+	if destination.Type == config.Remote {
+		updateMirror(cfg, destination, sourceDir, true)
+	}
+	return nil
+}
+
+func latestSnapshot(ctx context.Context, store fileStore) (*Snapshot, error) {
+	names, err := store.list(ctx, snapshotsDir)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	sort.Strings(names)
+	snaps, err := loadSnapshots(ctx, store, names[len(names)-1:])
+	if err != nil {
+		return nil, err
+	}
+	return snaps[0], nil
+}
+
+func loadSnapshots(ctx context.Context, store fileStore, names []string) ([]*Snapshot, error) {
+	tmp, err := os.MkdirTemp("", "hk-manifests-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := store.get(ctx, names, tmp); err != nil {
+		return nil, err
+	}
+
+	snaps := make([]*Snapshot, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(tmp, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("could not read snapshot %s: %w", name, err)
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return nil, fmt.Errorf("invalid snapshot manifest %s: %w", name, err)
+		}
+		if err := checkSnapshot(&snap); err != nil {
+			return nil, fmt.Errorf("invalid snapshot manifest %s: %w", name, err)
+		}
+		snaps = append(snaps, &snap)
+	}
+	return snaps, nil
+}
+
+func restoreSnapshot(ctx context.Context, store fileStore, snap *Snapshot, destDir string) error {
+	tmp, err := os.MkdirTemp("", "hk-blobs-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	var blobs []string
+	for _, f := range snap.Files {
+		if blob := blobPath(f.Hash); !slices.Contains(blobs, blob) {
+			blobs = append(blobs, blob)
+		}
+	}
+	if err := store.get(ctx, blobs, tmp); err != nil {
+		return fmt.Errorf("could not fetch snapshot data: %w", err)
+	}
+
+	if err := os.RemoveAll(destDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	_, decoder := zstdCodecs()
+	for _, f := range snap.Files {
+		compressed, err := os.ReadFile(filepath.Join(tmp, filepath.FromSlash(blobPath(f.Hash))))
+		if err != nil {
+			return fmt.Errorf("snapshot %s is missing data for %s: %w", snap.ID, f.Path, err)
+		}
+		data, err := decoder.DecodeAll(compressed, nil)
+		if err != nil {
+			return fmt.Errorf("corrupt data for %s in snapshot %s: %w", f.Path, snap.ID, err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != f.Hash {
+			return fmt.Errorf("data for %s in snapshot %s does not match its hash", f.Path, snap.ID)
+		}
+		dst := filepath.Join(destDir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dst, f.ModTime, f.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashTree describes every file below dir, in path order.
+func hashTree(dir string) ([]SnapshotFile, error) {
+	names, err := listLocalFiles(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	files := make([]SnapshotFile, 0, len(names))
+	for _, name := range names {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		files = append(files, SnapshotFile{
+			Path:    name,
+			Size:    int64(len(data)),
+			ModTime: info.ModTime().UTC(),
+			Hash:    hex.EncodeToString(sum[:]),
+		})
+	}
+	return files, nil
+}
+
+func sameFiles(a, b []SnapshotFile) bool {
+	return slices.EqualFunc(a, b, func(x, y SnapshotFile) bool {
+		return x.Path == y.Path && x.Hash == y.Hash
+	})
+}
+
+// checkSnapshot rejects a manifest whose ID, file paths or hashes could point
+// outside the repository or the directory it is restored into.
+func checkSnapshot(snap *Snapshot) error {
+	if !validSnapshotID(snap.ID) {
+		return fmt.Errorf("%q is not a snapshot ID", snap.ID)
+	}
+	for _, f := range snap.Files {
+		if !filepath.IsLocal(filepath.FromSlash(f.Path)) {
+			return fmt.Errorf("file path %q leaves the snapshot", f.Path)
+		}
+		if !validHash(f.Hash) {
+			return fmt.Errorf("%q is not a SHA-256 hash", f.Hash)
+		}
+	}
+	return nil
+}
+
+func validSnapshotID(id string) bool {
+	t, err := time.Parse(snapshotIDFormat, id)
+	return err == nil && t.Format(snapshotIDFormat) == id
+}
+
+// validHash reports whether hash is a SHA-256 in lowercase hex, as blobs are named.
+func validHash(hash string) bool {
+	if len(hash) != 2*sha256.Size {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// blobPath returns where the blob of a file with hash is stored. hash must
+// have passed validHash.
+func blobPath(hash string) string {
+	return path.Join(blobsDir, hash[:2], hash+".zst")
+}
@@ -0,0 +1,153 @@
+// /internal/backup/snapshot_test.go
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotCommitRestore(t *testing.T) {
+	ctx, cfg := context.Background(), &config.Config{}
+	dir := t.TempDir()
+	liveDir := filepath.Join(dir, "live")
+	target := config.ParseTarget(filepath.Join(dir, "repo") + "|||format=snapshot")
+
+	steps := []struct {
+		name      string
+		files     map[string]string
+		snapshots int
+		blobs     int
+	}{
+		{"first snapshot", map[string]string{"user1.dat": "a1", "user2.dat": "a2"}, 1, 2},
+		{"unchanged files add nothing", map[string]string{"user1.dat": "a1", "user2.dat": "a2"}, 1, 2},
+		{"changed file adds one blob", map[string]string{"user1.dat": "b1", "user2.dat": "a2"}, 2, 3},
+		{"known contents are deduplicated", map[string]string{"user1.dat": "a1", "user2.dat": "a2", "user3.dat": "b1"}, 3, 3},
+	}
+	for _, step := range steps {
+		os.RemoveAll(liveDir)
+		writeFiles(t, liveDir, step.files)
+		// Snapshot IDs have millisecond resolution.
+		time.Sleep(2 * time.Millisecond)
+		if err := CreateSnapshot(ctx, cfg, liveDir, target); err != nil {
+			t.Fatalf("%s: CreateSnapshot: %v", step.name, err)
+		}
+		snaps, err := ListSnapshots(ctx, cfg, target)
+		if err != nil {
+			t.Fatalf("%s: ListSnapshots: %v", step.name, err)
+		}
+		blobs, err := listLocalFiles(filepath.Join(target.Path, blobsDir), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snaps) != step.snapshots || len(blobs) != step.blobs {
+			t.Errorf("%s: %d snapshot(s) and %d blob(s), want %d and %d", step.name, len(snaps), len(blobs), step.snapshots, step.blobs)
+		}
+	}
+
+	snaps, err := ListSnapshots(ctx, cfg, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, snap := range snaps {
+		restored := filepath.Join(dir, "restored", snap.ID)
+		// Restoring replaces what the directory held.
+		writeFiles(t, restored, map[string]string{"stale.dat": "x"})
+		if _, err := RestoreSnapshot(ctx, cfg, target, snap.ID, restored); err != nil {
+			t.Fatalf("RestoreSnapshot %s: %v", snap.ID, err)
+		}
+		want := steps[0].files
+		if i > 0 {
+			want = steps[i+1].files
+		}
+		if got := readFiles(t, restored); !reflect.DeepEqual(got, want) {
+			t.Errorf("snapshot %d restored %v, want %v", i, got, want)
+		}
+	}
+
+	// Syncing from the repository restores the latest snapshot.
+	latest := filepath.Join(dir, "latest")
+	if err := Sync(ctx, cfg, target, config.SyncTarget{Type: config.Local, Path: latest}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := readFiles(t, latest); !reflect.DeepEqual(got, steps[3].files) {
+		t.Errorf("latest snapshot restored %v, want %v", got, steps[3].files)
+	}
+}
+
+func TestRestoreSnapshotErrors(t *testing.T) {
+	ctx, cfg := context.Background(), &config.Config{}
+	dir := t.TempDir()
+	target := config.ParseTarget(filepath.Join(dir, "repo") + "|||format=snapshot")
+	if _, err := RestoreSnapshot(ctx, cfg, target, "", filepath.Join(dir, "out")); err == nil {
+		t.Error("restoring from an empty repository succeeded")
+	}
+
+	liveDir := filepath.Join(dir, "live")
+	writeFiles(t, liveDir, map[string]string{"user1.dat": "a1"})
+	if err := CreateSnapshot(ctx, cfg, liveDir, target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreSnapshot(ctx, cfg, target, "20000101T000000.000Z", filepath.Join(dir, "out")); err == nil {
+		t.Error("restoring a snapshot that doesn't exist succeeded")
+	}
+
+	// A blob that no longer matches its hash is refused.
+	blobs, err := listLocalFiles(filepath.Join(target.Path, blobsDir), "")
+	if err != nil || len(blobs) != 1 {
+		t.Fatalf("blobs = %v, %v", blobs, err)
+	}
+	encoder, _ := zstdCodecs()
+	blob := filepath.Join(target.Path, blobsDir, filepath.FromSlash(blobs[0]))
+	if err := os.WriteFile(blob, encoder.EncodeAll([]byte("tampered"), nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreSnapshot(ctx, cfg, target, "", filepath.Join(dir, "out")); err == nil {
+		t.Error("restoring a tampered blob succeeded")
+	}
+}
+
+func TestRestoreSnapshotRejectsMalformed(t *testing.T) {
+	ctx, cfg := context.Background(), &config.Config{}
+	dir := t.TempDir()
+	target := config.ParseTarget(filepath.Join(dir, "repo") + "|||format=snapshot")
+	hash := "5d41402abc4b2a76b9719d911017c592ae1b2f0e8a5b1f1b3f7a4d4e8ad6b3c1"
+	now := time.Now().UTC()
+	tests := []struct {
+		name string
+		id   string
+		snap *Snapshot
+	}{
+		{"id escaping the repository", "../../outside", nil},
+		{"id in another format", "2020-01-01", nil},
+		{"short hash", "20200101T000000.000Z", &Snapshot{ID: "20200101T000000.000Z", Time: now, Files: []SnapshotFile{{Path: "user1.dat", Hash: "a"}}}},
+		{"hash escaping the blobs", "20200101T000001.000Z", &Snapshot{ID: "20200101T000001.000Z", Time: now, Files: []SnapshotFile{{Path: "user1.dat", Hash: "../../../../../../etc/passwd"}}}},
+		{"uppercase hash", "20200101T000002.000Z", &Snapshot{ID: "20200101T000002.000Z", Time: now, Files: []SnapshotFile{{Path: "user1.dat", Hash: strings.ToUpper(hash)}}}},
+		{"path escaping the destination", "20200101T000003.000Z", &Snapshot{ID: "20200101T000003.000Z", Time: now, Files: []SnapshotFile{{Path: "../user1.dat", Hash: hash}}}},
+		{"manifest with another id", "20200101T000004.000Z", &Snapshot{ID: "../x", Time: now}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.snap != nil {
+				data, err := json.Marshal(tt.snap)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeFiles(t, filepath.Join(target.Path, snapshotsDir), map[string]string{tt.id + ".json": string(data)})
+			}
+			out := filepath.Join(dir, "out", tt.name)
+			if _, err := RestoreSnapshot(ctx, cfg, target, tt.id, out); err == nil {
+				t.Fatal("RestoreSnapshot succeeded")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "out", "user1.dat")); !os.IsNotExist(err) {
+				t.Error("a file was written outside the destination")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// /internal/backup/store.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/util"
+)
+
+// fileStore is the file-level access to a target that formats other than a
+// plain mirror need. Paths are slash-separated and relative to the target root.
+type fileStore interface {
+	// list returns the files below dir, recursively. A missing dir is empty.
+	list(ctx context.Context, dir string) ([]string, error)
+	// get copies the given files into localDir, keeping their relative paths.
+	get(ctx context.Context, files []string, localDir string) error
+	// put copies every file below localDir into the target, keeping relative paths.
+	// Files already in the target that are not in localDir are left alone.
+	put(ctx context.Context, localDir string) error
+}
+
+// newFileStore returns the store for target, transparently encrypting and
+// decrypting if the target is encrypted.
+func newFileStore(cfg *config.Config, target config.SyncTarget) fileStore {
+	store := rawFileStore(cfg, target)
+	if target.Encryption != nil {
+		return &cryptStore{cfg: cfg, target: target, inner: store}
+	}
+	return store
+}
+
+// rawFileStore returns the store for target that reads and writes files as
+// they are stored.
+func rawFileStore(cfg *config.Config, target config.SyncTarget) fileStore {
+	if target.Type == config.Remote {
+		return &rcloneStore{cfg: cfg, root: remoteSpec(target), opts: target.Rclone}
+	}
+	return &localStore{root: target.Path}
+}
+
+type localStore struct {
+	root string
+}
+
+func (s *localStore) list(ctx context.Context, dir string) ([]string, error) {
+	return listLocalFiles(filepath.Join(s.root, filepath.FromSlash(dir)), dir)
+}
+
+func (s *localStore) get(ctx context.Context, files []string, localDir string) error {
+	for _, f := range files {
+		dst := filepath.Join(localDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := util.CopyFile(filepath.Join(s.root, filepath.FromSlash(f)), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *localStore) put(ctx context.Context, localDir string) error {
+	files, err := listLocalFiles(localDir, "")
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		dst := filepath.Join(s.root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := util.CopyFile(filepath.Join(localDir, filepath.FromSlash(f)), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rcloneStore struct {
+	cfg  *config.Config
+	root string
+	opts config.RcloneOptions
+}
+
+func (s *rcloneStore) list(ctx context.Context, dir string) ([]string, error) {
+	listing, err := listRemoteDir(ctx, s.cfg, s.root, dir, s.opts)
+	if errors.Is(err, ErrRemoteNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(listing))
+	for i, f := range listing {
+		files[i] = f.Path
+	}
+	return files, nil
+}
+
+func (s *rcloneStore) get(ctx context.Context, files []string, localDir string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	return rcloneCopy(ctx, s.cfg, s.root, localDir, copyOptions{filesFrom: files, rclone: s.opts})
+}
+
+func (s *rcloneStore) put(ctx context.Context, localDir string) error {
+	return rcloneCopy(ctx, s.cfg, localDir, s.root, copyOptions{rclone: s.opts})
+}
+
+// listLocalFiles returns the files below dir as slash-separated paths prefixed with prefix.
+func listLocalFiles(dir, prefix string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, path.Join(prefix, filepath.ToSlash(rel)))
+		return nil
+	})
+	if err != nil && os.IsNotExist(err) {
+		return nil, nil
+	}
+	return files, err
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"pirated-hollow-knight/internal/config"
 	"pirated-hollow-knight/internal/log"
+	"pirated-hollow-knight/internal/progress"
 	"pirated-hollow-knight/internal/util"
 	"sync"
 	"time"
@@ -15,10 +16,17 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
+// logger tags the messages of this package with its subsystem.
+var logger = log.For("backup")
+
 // StartBackgroundSync starts all necessary backup goroutines (periodic and/or watcher).
-func StartBackgroundSync(ctx context.Context, cfg *config.Config, liveInstanceSaveDir string) {
+// Syncs are routed through queue so they never overlap with other syncs to the
+// same destination. The returned function blocks until every background
+// goroutine has stopped after ctx is cancelled, including any final flush.
+func StartBackgroundSync(ctx context.Context, cfg *config.Config, queue *SyncQueue, liveInstanceSaveDir string) (wait func()) {
+	var wg sync.WaitGroup
 	if len(cfg.SyncTargets) <= 1 {
-		return // Nothing to do, only primary target exists
+		return wg.Wait // Nothing to do, only primary target exists
 	}
 	backupTargets := cfg.SyncTargets[1:]
 
@@ -32,30 +40,33 @@ func StartBackgroundSync(ctx context.Context, cfg *config.Config, liveInstanceSa
 	}
 
 	if len(periodicTargets) > 0 {
-		startPeriodicBackups(ctx, cfg, liveInstanceSaveDir, periodicTargets)
+		startPeriodicBackups(ctx, queue, &wg, liveInstanceSaveDir, periodicTargets)
 	}
 	if len(watcherTargets) > 0 {
-		startWatcherBackups(ctx, cfg, liveInstanceSaveDir, watcherTargets)
+		startWatcherBackups(ctx, queue, &wg, liveInstanceSaveDir, watcherTargets)
 	}
+	return wg.Wait
 }
 
-func startPeriodicBackups(ctx context.Context, cfg *config.Config, sourceDir string, targets []config.SyncTarget) {
-	log.Log.Info("--- Starting Periodic Background Backups ---")
+func startPeriodicBackups(ctx context.Context, queue *SyncQueue, wg *sync.WaitGroup, sourceDir string, targets []config.SyncTarget) {
+	logger.Info("--- Starting Periodic Background Backups ---")
 	sourceTarget := config.SyncTarget{Type: config.Local, Path: sourceDir}
 	for _, target := range targets {
+		wg.Add(1)
 		go func(t config.SyncTarget) {
-			log.Log.Info("Starting periodic backup for '%s' every %s.", t.Original, t.Interval)
+			defer wg.Done()
+			logger.Info("Starting periodic backup for '%s' every %s.", t.Original, t.Interval)
 			ticker := time.NewTicker(t.Interval)
 			defer ticker.Stop()
 			for {
 				select {
 				case <-ticker.C:
-					log.Log.Info("Periodic backup triggered for '%s'...", t.Original)
-					if err := Sync(ctx, cfg, sourceTarget, t); err != nil {
-						log.Log.Error("During periodic backup for '%s': %v", t.Original, err)
+					logger.Info("Periodic backup triggered for '%s'...", t.Original)
+					if err := queue.Sync(sourceTarget, t); err != nil {
+						logger.Error("During periodic backup for '%s': %v", t.Original, err)
 					}
 				case <-ctx.Done():
-					log.Log.Info("Stopping periodic backup for '%s'.", t.Original)
+					logger.Info("Stopping periodic backup for '%s'.", t.Original)
 					return
 				}
 			}
@@ -63,33 +74,43 @@ func startPeriodicBackups(ctx context.Context, cfg *config.Config, sourceDir str
 	}
 }
 
-func startWatcherBackups(ctx context.Context, cfg *config.Config, sourceDir string, targets []config.SyncTarget) {
-	log.Log.Info("--- Starting Filesystem Watcher for Backups ---")
+func startWatcherBackups(ctx context.Context, queue *SyncQueue, wg *sync.WaitGroup, sourceDir string, targets []config.SyncTarget) {
+	logger.Info("--- Starting Filesystem Watcher for Backups ---")
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Log.Error("Could not create filesystem watcher: %v", err)
+		logger.Error("Could not create filesystem watcher: %v", err)
 		return
 	}
 
-	go func() {
-		<-ctx.Done()
-		log.Log.Info("Closing filesystem watcher.")
-		watcher.Close()
-	}()
-
 	err = watcher.Add(sourceDir)
 	if err != nil {
-		log.Log.Error("Could not watch instance save directory '%s': %v", sourceDir, err)
+		logger.Error("Could not watch instance save directory '%s': %v", sourceDir, err)
+		watcher.Close()
 		return
 	}
-	log.Log.Info("Watching '%s' for changes to backup.", sourceDir)
+	logger.Info("Watching '%s' for changes to backup.", sourceDir)
 
 	var debounceTimer *time.Timer
 	const debounceDuration = 2 * time.Second
 	var mu sync.Mutex
 	sourceTarget := config.SyncTarget{Type: config.Local, Path: sourceDir}
 
+	backupAll := func() {
+		results := make([]<-chan error, len(targets))
+		for i, t := range targets {
+			results[i] = queue.Enqueue(sourceTarget, t)
+		}
+		for i, t := range targets {
+			if err := <-results[i]; err != nil {
+				logger.Error("During watched backup for '%s': %v", t.Original, err)
+			}
+		}
+	}
+
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+		defer watcher.Close()
 		for {
 			select {
 			case event, ok := <-watcher.Events:
@@ -97,18 +118,23 @@ func startWatcherBackups(ctx context.Context, cfg *config.Config, sourceDir stri
 					return
 				}
 				if event.Op&fsnotify.Write == fsnotify.Write {
-					log.Log.Info("File change detected: %s. Debouncing backup for %s...", filepath.Base(event.Name), debounceDuration)
+					logger.Info("File change detected: %s. Debouncing backup for %s...", filepath.Base(event.Name), debounceDuration)
 					mu.Lock()
 					if debounceTimer != nil {
 						debounceTimer.Stop()
 					}
 					debounceTimer = time.AfterFunc(debounceDuration, func() {
-						log.Log.Info("Debounce timer finished. Triggering backup for all watcher targets.")
-						for _, t := range targets {
-							if err := Sync(ctx, cfg, sourceTarget, t); err != nil {
-								log.Log.Error("During watched backup for '%s': %v", t.Original, err)
-							}
+						mu.Lock()
+						if ctx.Err() != nil {
+							// The shutdown path below owns the final backup.
+							mu.Unlock()
+							return
 						}
+						wg.Add(1)
+						mu.Unlock()
+						defer wg.Done()
+						logger.Info("Debounce timer finished. Triggering backup for all watcher targets.")
+						backupAll()
 					})
 					mu.Unlock()
 				}
@@ -116,27 +142,101 @@ func startWatcherBackups(ctx context.Context, cfg *config.Config, sourceDir stri
 				if !ok {
 					return
 				}
-				log.Log.Warn("Watcher error: %v", err)
+				logger.Warn("Watcher error: %v", err)
 			case <-ctx.Done():
+				logger.Info("Closing filesystem watcher.")
+				mu.Lock()
+				flush := debounceTimer != nil && debounceTimer.Stop()
+				mu.Unlock()
+				if flush {
+					logger.Info("Flushing pending watched backup before stopping.")
+					backupAll()
+				}
 				return
 			}
 		}
 	}()
 }
 
+// LastModTime returns the most recent modification time of the saves held by a
+// target, whatever its type and format.
+func LastModTime(ctx context.Context, cfg *config.Config, target config.SyncTarget) (time.Time, error) {
+	switch {
+	case target.Format == config.FormatSnapshot:
+		return latestSnapshotModTime(ctx, cfg, target)
+	case target.Type == config.Remote:
+		return GetCloudDirLastModTime(ctx, cfg, target)
+	default:
+		return util.GetDirLastModTime(target.Path)
+	}
+}
+
+// SlotsLastModTime is LastModTime over the slots this machine stores in target
+// only, so that other players saving their own slots to a shared location don't
+// make it look newer. Snapshots and encrypted file names can't be told apart by
+// slot and count as a whole.
+func SlotsLastModTime(ctx context.Context, cfg *config.Config, target config.SyncTarget) (time.Time, error) {
+	include := conflictScope(cfg, target)
+	switch {
+	case include == nil || target.Format == config.FormatSnapshot || target.Encryption != nil:
+		return LastModTime(ctx, cfg, target)
+	case target.Type == config.Remote:
+		return cloudLastModTime(ctx, cfg, target, include)
+	}
+	files, err := listLocalFiles(target.Path, "")
+	if err != nil {
+		return time.Time{}, err
+	}
+	var latest time.Time
+	for _, f := range files {
+		if !include(f) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(target.Path, filepath.FromSlash(f)))
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
 // Sync is the new centralized data synchronization function.
+// Transfers to or from a remote also keep that remote's offline cache current.
 func Sync(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget) error {
-	sourcePath := source.Path
-	if source.Type == config.Gdrive {
-		sourcePath = fmt.Sprintf("%s:%s", source.RemoteName, source.Path)
+	switch {
+	case source.Slots != nil && destination.Slots != nil:
+		// Both sides store the slots under the target's numbers, e.g. a remote
+		// and its offline cache.
+		source.Slots, destination.Slots = nil, nil
+	case source.Slots != nil:
+		return syncFromSlotMap(ctx, cfg, source, destination)
+	case destination.Slots != nil:
+		return syncToSlotMap(ctx, cfg, source, destination)
 	}
+	logger.Info("Syncing from '%s' to '%s'...", storagePath(source), storagePath(destination))
 
-	destPath := destination.Path
-	if destination.Type == config.Gdrive {
-		destPath = fmt.Sprintf("%s:%s", destination.RemoteName, destPath)
+	if source.Format == config.FormatSnapshot || destination.Format == config.FormatSnapshot {
+		return syncSnapshot(ctx, cfg, source, destination)
 	}
+	if source.Encryption != nil || destination.Encryption != nil {
+		return syncEncrypted(ctx, cfg, source, destination)
+	}
+	return transfer(ctx, cfg, source, destination, transferOptions{updateCache: true})
+}
+
+// transferOptions adjust how transfer copies a mirror.
+type transferOptions struct {
+	updateCache bool              // refresh the offline cache of a remote side
+	nonces      map[string]string // nonces of encrypted files, kept in the manifest
+}
 
-	log.Log.Info("Syncing from '%s' to '%s'...", sourcePath, destPath)
+// transfer copies a plain mirror from source to destination as stored.
+func transfer(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget, topts transferOptions) error {
+	sourcePath := storagePath(source)
+	destPath := storagePath(destination)
 
 	// If both are local, we can use a simple directory copy.
 	if source.Type == config.Local && destination.Type == config.Local {
@@ -145,14 +245,89 @@ func Sync(ctx context.Context, cfg *config.Config, source, destination config.Sy
 				return fmt.Errorf("could not clean local destination %s: %w", destPath, err)
 			}
 		}
-		return util.CopyDir(sourcePath, destPath)
+		tracker := progress.Start(progressLabel(source, destination))
+		err := util.CopyDirWithProgress(sourcePath, destPath, tracker.Update)
+		tracker.Finish(err)
+		if err != nil {
+			return err
+		}
+		return finishTransfer(ctx, cfg, source, destination, topts)
+	}
+
+	if destination.Type == config.Remote {
+		if err := prepareRemotePush(ctx, cfg, destination); err != nil {
+			return err
+		}
 	}
 
 	// Otherwise, at least one is remote, so we must use rclone.
-	err := RunRcloneCommand(ctx, cfg, "copy", sourcePath, destPath)
-	if err != nil {
+	opts := copyOptions{label: progressLabel(source, destination), rclone: source.Rclone}
+	if destination.Type == config.Remote {
+		opts.rclone = destination.Rclone
+	}
+	if !isConfigured(destination) {
+		opts.exclude = []string{"/" + manifestFile}
+	}
+	if err := rcloneCopy(ctx, cfg, sourcePath, destPath, opts); err != nil {
 		return fmt.Errorf("rclone sync from '%s' to '%s' failed: %w", sourcePath, destPath, err)
 	}
-	log.Log.Info("✅ Sync successful.")
+	if source.Type == config.Remote && destination.Type == config.Local {
+		if err := restoreModTimes(ctx, cfg, source, destPath); err != nil {
+			return fmt.Errorf("could not restore modification times from '%s': %w", sourcePath, err)
+		}
+	}
+	if err := finishTransfer(ctx, cfg, source, destination, topts); err != nil {
+		return err
+	}
+
+	switch {
+	case !topts.updateCache:
+	case source.Type == config.Local && destination.Type == config.Remote:
+		updateMirror(cfg, destination, source.Path, true)
+	case source.Type == config.Remote && destination.Type == config.Local:
+		updateMirror(cfg, source, destination.Path, false)
+	}
+	logger.Info("✅ Sync successful.")
 	return nil
 }
+
+// storagePath returns the local path or rclone remote spec of a target.
+func storagePath(t config.SyncTarget) string {
+	if t.Type == config.Remote {
+		return remoteSpec(t)
+	}
+	return t.Path
+}
+
+// isConfigured reports whether t is a target the user configured, as opposed
+// to the game's save directory or a temporary staging directory.
+func isConfigured(t config.SyncTarget) bool {
+	return t.Original != ""
+}
+
+// finishTransfer records the manifest of a configured destination. Other
+// destinations never keep a manifest that was copied along.
+func finishTransfer(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget, topts transferOptions) error {
+	if isConfigured(destination) {
+		return recordManifest(ctx, cfg, source, destination, topts.nonces)
+	}
+	if destination.Type == config.Local {
+		if err := os.Remove(filepath.Join(destination.Path, manifestFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// progressLabel names a transfer in progress reports after the configured
+// target it involves.
+func progressLabel(source, destination config.SyncTarget) string {
+	switch {
+	case isConfigured(destination):
+		return destination.Original
+	case isConfigured(source):
+		return source.Original
+	default:
+		return storagePath(destination)
+	}
+}
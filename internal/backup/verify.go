@@ -0,0 +1,409 @@
+// /internal/backup/verify.go
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// manifestFile is stored at the root of every configured mirror target. It
+// lists the SHA-256 of each file as it was written, so the target can be
+// checked later.
+const manifestFile = "hkmanifest.json"
+
+// How often, and how far apart, eventually consistent remotes are checked
+// again before a mismatch is believed.
+const (
+	consistencyAttempts = 3
+	consistencyDelay    = 5 * time.Second
+)
+
+// ErrNoManifest is returned when verifying a target that has no manifest yet.
+var ErrNoManifest = errors.New("no integrity manifest has been recorded for this target yet")
+
+// Manifest records the files written to a mirror target by a sync.
+type Manifest struct {
+	Created time.Time         `json:"created"`
+	Files   map[string]string `json:"sha256"`
+	// Nonces holds the nonce each file of an encrypted target was sealed
+	// with, so unchanged files can be sealed to the same ciphertext again.
+	Nonces map[string]string `json:"nonces,omitempty"`
+	// ModTimes holds the modification times of the files written, for
+	// backends that don't keep modification times themselves.
+	ModTimes map[string]time.Time `json:"mod_times,omitempty"`
+}
+
+// latest returns the newest recorded modification time of the files include
+// accepts, or of every file if include is nil.
+func (m *Manifest) latest(include func(name string) bool) time.Time {
+	var t time.Time
+	for name, mod := range m.ModTimes {
+		if (include == nil || include(name)) && mod.After(t) {
+			t = mod
+		}
+	}
+	return t
+}
+
+// VerifyReport lists the differences between a target and its manifest.
+type VerifyReport struct {
+	Checked    int
+	Missing    []string
+	Extra      []string
+	Mismatched []string
+}
+
+// Damaged reports whether files recorded in the manifest are missing or altered.
+// Extra files are not damage: copies to remotes never delete old files.
+func (r VerifyReport) Damaged() bool {
+	return len(r.Missing) > 0 || len(r.Mismatched) > 0
+}
+
+func (r VerifyReport) summary() string {
+	return fmt.Sprintf("%d missing, %d mismatched", len(r.Missing), len(r.Mismatched))
+}
+
+// VerifyTarget checks the files stored in target against its manifest. For
+// snapshot targets, every snapshot's data is checked against its recorded hashes.
+func VerifyTarget(ctx context.Context, cfg *config.Config, target config.SyncTarget) (VerifyReport, error) {
+	if target.Format == config.FormatSnapshot {
+		return verifySnapshots(ctx, cfg, target)
+	}
+	manifest, err := readManifest(ctx, cfg, target)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	if manifest == nil {
+		return VerifyReport{}, ErrNoManifest
+	}
+	return verifyMirror(ctx, cfg, target, manifest)
+}
+
+// recordManifest writes the manifest for a sync from source to destination.
+// A remote source carries its manifest along, so nothing is written for it.
+// nonces are recorded for encrypted destinations and nil otherwise.
+func recordManifest(ctx context.Context, cfg *config.Config, source, destination config.SyncTarget, nonces map[string]string) error {
+	if source.Type != config.Local {
+		return nil
+	}
+	files, err := hashTree(source.Path)
+	if err != nil {
+		return err
+	}
+	manifest := &Manifest{Created: time.Now().UTC(), Files: make(map[string]string), ModTimes: make(map[string]time.Time), Nonces: nonces}
+	for _, f := range files {
+		if f.Path != manifestFile {
+			manifest.Files[f.Path] = f.Hash
+			manifest.ModTimes[f.Path] = f.ModTime
+		}
+	}
+	if err := writeManifest(ctx, cfg, destination, manifest); err != nil {
+		return fmt.Errorf("could not record manifest in '%s': %w", destination.Original, err)
+	}
+	return nil
+}
+
+// verifySynced checks a mirror target against the manifest its last sync
+// recorded. Snapshot targets check their data on every snapshot instead.
+func verifySynced(ctx context.Context, cfg *config.Config, target config.SyncTarget) error {
+	if target.Format == config.FormatSnapshot {
+		return nil
+	}
+	manifest, err := readManifest(ctx, cfg, target)
+	if err != nil || manifest == nil {
+		return err
+	}
+	report, err := verifyMirror(ctx, cfg, target, manifest)
+	if err != nil {
+		return fmt.Errorf("could not verify '%s': %w", target.Original, err)
+	}
+	if report.Damaged() && target.Type == config.Remote {
+		if info, err := GetRemoteInfo(ctx, cfg, target.RemoteName); err == nil && info.EventuallyConsistent() {
+			report, err = reverifyLagging(ctx, cfg, target, manifest, info)
+			if err != nil {
+				return fmt.Errorf("could not verify '%s': %w", target.Original, err)
+			}
+		}
+	}
+	if report.Damaged() {
+		return fmt.Errorf("'%s' does not match what was written (%s)", target.Original, report.summary())
+	}
+	logger.Info("Verified %d file(s) in '%s'.", report.Checked, target.Original)
+	return nil
+}
+
+// reverifyLagging checks a remote again after a delay, since eventually
+// consistent backends may list what was just written only after a while.
+func reverifyLagging(ctx context.Context, cfg *config.Config, target config.SyncTarget, manifest *Manifest, info RemoteInfo) (VerifyReport, error) {
+	var report VerifyReport
+	for attempt := 1; attempt <= consistencyAttempts; attempt++ {
+		logger.Info("'%s' (%s) may list recent writes late; checking again in %s (%d/%d).", target.Original, info.Type, consistencyDelay, attempt, consistencyAttempts)
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case <-time.After(consistencyDelay):
+		}
+		var err error
+		if report, err = verifyMirror(ctx, cfg, target, manifest); err != nil || !report.Damaged() {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func writeManifest(ctx context.Context, cfg *config.Config, target config.SyncTarget, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "hk-manifest-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	p := filepath.Join(tmp, manifestFile)
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		return err
+	}
+	// Like the encryption header, the manifest must never look like the newest save.
+	old := time.Unix(0, 0)
+	if err := os.Chtimes(p, old, old); err != nil {
+		return err
+	}
+	if target.Type == config.Remote {
+		// Every manifest has the same timestamp and often the same size, so
+		// rclone must not skip it as unchanged.
+		return rcloneCopy(ctx, cfg, tmp, remoteSpec(target), copyOptions{label: target.Original, ignoreTimes: true, rclone: target.Rclone})
+	}
+	return rawFileStore(cfg, target).put(ctx, tmp)
+}
+
+// readManifest returns the manifest stored in target, or nil if it has none.
+func readManifest(ctx context.Context, cfg *config.Config, target config.SyncTarget) (*Manifest, error) {
+	tmp, err := os.MkdirTemp("", "hk-manifest-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := rawFileStore(cfg, target).get(ctx, []string{manifestFile}, tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(tmp, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest in '%s': %w", target.Original, err)
+	}
+	return &manifest, nil
+}
+
+func verifyMirror(ctx context.Context, cfg *config.Config, target config.SyncTarget, manifest *Manifest) (VerifyReport, error) {
+	stored, err := storedHashes(ctx, cfg, target)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	delete(stored, manifestFile)
+
+	var report VerifyReport
+	for name, hash := range manifest.Files {
+		report.Checked++
+		actual, ok := stored[name]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, name)
+		case actual != hash:
+			report.Mismatched = append(report.Mismatched, name)
+		}
+	}
+	for name := range stored {
+		if _, ok := manifest.Files[name]; !ok {
+			report.Extra = append(report.Extra, name)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Mismatched)
+	sort.Strings(report.Extra)
+	return report, nil
+}
+
+// storedHashes returns the SHA-256 of every file in target as stored. Remote
+// hashes come from the remote listing; files are downloaded and hashed with
+// rclone hashsum only if the backend cannot provide SHA-256 itself.
+func storedHashes(ctx context.Context, cfg *config.Config, target config.SyncTarget) (map[string]string, error) {
+	hashes := make(map[string]string)
+	if target.Type != config.Remote {
+		files, err := hashTree(target.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			hashes[f.Path] = f.Hash
+		}
+		return hashes, nil
+	}
+
+	// Skip listing backends known not to have SHA-256, e.g. Drive (MD5) or
+	// OneDrive (QuickXorHash), and crypt remotes, which have no hashes at all.
+	complete := false
+	if info, err := GetRemoteInfo(ctx, cfg, target.RemoteName); err != nil || info.HasHash("sha256") {
+		listing, err := ListRemote(ctx, cfg, target)
+		if errors.Is(err, ErrRemoteNotFound) {
+			return hashes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		complete = true
+		for _, f := range listing {
+			if hash, ok := f.Hash("sha256"); ok {
+				hashes[f.Path] = hash
+			} else {
+				complete = false
+			}
+		}
+	}
+	if !complete {
+		logger.Info("'%s' does not provide SHA-256 hashes, downloading files to check them.", target.Original)
+		clear(hashes)
+		lines, err := downloadHashes(ctx, cfg, remoteSpec(target), target.Rclone)
+		if errors.Is(err, ErrRemoteNotFound) {
+			return hashes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		parseHashsum(lines, hashes)
+	}
+	return hashes, nil
+}
+
+// downloadHashes downloads every file below spec and returns its SHA-256 in
+// rclone hashsum's "hash  path" format.
+func downloadHashes(ctx context.Context, cfg *config.Config, spec string, opts config.RcloneOptions) ([]string, error) {
+	if d := daemonFor(opts); d != nil {
+		var result struct {
+			Hashsum []string `json:"hashsum"`
+		}
+		err := d.call(ctx, "operations/hashsum", map[string]any{"fs": spec, "hashType": "sha256", "download": true, "_config": rcConfig(opts)}, &result)
+		return result.Hashsum, err
+	}
+	args := append([]string{"hashsum", "sha256", "--download", spec}, rcloneFlags(opts)...)
+	out, err := runRcloneOutput(ctx, cfg, args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.ReplaceAll(string(out), "\r\n", "\n"), "\n"), nil
+}
+
+// parseHashsum reads rclone hashsum output lines into hashes.
+func parseHashsum(lines []string, hashes map[string]string) {
+	for _, line := range lines {
+		hash, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			continue
+		}
+		if _, err := hex.DecodeString(hash); err != nil || hash == "" {
+			continue
+		}
+		hashes[name] = strings.ToLower(hash)
+	}
+}
+
+// verifySnapshots checks that every blob referenced by a snapshot exists and
+// still matches its hash, and reports blobs no snapshot refers to as extra.
+func verifySnapshots(ctx context.Context, cfg *config.Config, target config.SyncTarget) (VerifyReport, error) {
+	snaps, err := ListSnapshots(ctx, cfg, target)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	store := newFileStore(cfg, target)
+	stored, err := store.list(ctx, blobsDir)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+
+	var report VerifyReport
+	var referenced []string
+	for _, s := range snaps {
+		for _, f := range s.Files {
+			if blob := blobPath(f.Hash); !slices.Contains(referenced, blob) {
+				referenced = append(referenced, blob)
+			}
+		}
+	}
+	var present []string
+	for _, blob := range referenced {
+		report.Checked++
+		if slices.Contains(stored, blob) {
+			present = append(present, blob)
+		} else {
+			report.Missing = append(report.Missing, blob)
+		}
+	}
+	for _, blob := range stored {
+		if !slices.Contains(referenced, blob) {
+			report.Extra = append(report.Extra, blob)
+		}
+	}
+
+	tmp, err := os.MkdirTemp("", "hk-verify-*")
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	defer os.RemoveAll(tmp)
+	_, decoder := zstdCodecs()
+	for _, blob := range present {
+		// Blobs are fetched one at a time, so a blob that fails to decrypt is
+		// reported on its own instead of failing the whole check.
+		if err := store.get(ctx, []string{blob}, tmp); err != nil {
+			logger.Warn("Could not read %s: %v", blob, err)
+			report.Mismatched = append(report.Mismatched, blob)
+			continue
+		}
+		want := strings.TrimSuffix(path.Base(blob), ".zst")
+		compressed, err := os.ReadFile(filepath.Join(tmp, filepath.FromSlash(blob)))
+		if err != nil {
+			report.Missing = append(report.Missing, blob)
+			continue
+		}
+		data, err := decoder.DecodeAll(compressed, nil)
+		if sum := sha256.Sum256(data); err != nil || hex.EncodeToString(sum[:]) != want {
+			report.Mismatched = append(report.Mismatched, blob)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Mismatched)
+	sort.Strings(report.Extra)
+	return report, nil
+}
+
+// checkSnapshotBlobs confirms that every blob snap refers to is present in store.
+func checkSnapshotBlobs(ctx context.Context, store fileStore, snap *Snapshot) error {
+	stored, err := store.list(ctx, blobsDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range snap.Files {
+		if !slices.Contains(stored, blobPath(f.Hash)) {
+			return fmt.Errorf("snapshot %s is missing data for %s after upload", snap.ID, f.Path)
+		}
+	}
+	return nil
+}
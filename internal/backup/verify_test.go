@@ -0,0 +1,103 @@
+// /internal/backup/verify_test.go
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyTarget(t *testing.T) {
+	ctx, cfg := context.Background(), &config.Config{}
+	dir := t.TempDir()
+	liveDir := filepath.Join(dir, "live")
+	writeFiles(t, liveDir, map[string]string{"user1.dat": "one", "user2.dat": "two", "user3.dat": "three"})
+	mirror := filepath.Join(dir, "mirror")
+	target := config.ParseTarget(mirror)
+
+	if _, err := VerifyTarget(ctx, cfg, target); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("VerifyTarget before any sync = %v, want %v", err, ErrNoManifest)
+	}
+	if err := Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: liveDir}, target); err != nil {
+		t.Fatal(err)
+	}
+	report, err := VerifyTarget(ctx, cfg, target)
+	if err != nil || report.Damaged() || report.Checked != 3 || len(report.Extra) != 0 {
+		t.Fatalf("VerifyTarget after sync = %+v, %v, want 3 files checked and no differences", report, err)
+	}
+	if err := verifySynced(ctx, cfg, target); err != nil {
+		t.Errorf("verifySynced after sync: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(mirror, "user1.dat")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, mirror, map[string]string{"user2.dat": "tampered", "user4.dat": "stray"})
+	report, err = VerifyTarget(ctx, cfg, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VerifyReport{Checked: 3, Missing: []string{"user1.dat"}, Mismatched: []string{"user2.dat"}, Extra: []string{"user4.dat"}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("VerifyTarget after damage = %+v, want %+v", report, want)
+	}
+	if !report.Damaged() {
+		t.Error("a report with missing and mismatched files is not damaged")
+	}
+	if err := verifySynced(ctx, cfg, target); err == nil {
+		t.Error("verifySynced accepted a damaged target")
+	}
+
+	// Extra files alone are no damage.
+	if err := Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: liveDir}, target); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, mirror, map[string]string{"user4.dat": "stray"})
+	if report, err := VerifyTarget(ctx, cfg, target); err != nil || report.Damaged() || !reflect.DeepEqual(report.Extra, []string{"user4.dat"}) {
+		t.Errorf("VerifyTarget with an extra file = %+v, %v, want only user4.dat as extra", report, err)
+	}
+}
+
+func TestSyncEncryptedKeepsCiphertext(t *testing.T) {
+	ctx, cfg := context.Background(), &config.Config{}
+	dir := t.TempDir()
+	liveDir := filepath.Join(dir, "live")
+	writeFiles(t, liveDir, map[string]string{"user1.dat": "one", "user2.dat": "two"})
+	vault := filepath.Join(dir, "vault")
+	t.Setenv("HK_TEST_PASSPHRASE", "correct horse")
+	target := config.ParseTarget(vault + "|||passphrase-env=HK_TEST_PASSPHRASE")
+	live := config.SyncTarget{Type: config.Local, Path: liveDir}
+
+	syncOnce := func() map[string]string {
+		t.Helper()
+		if err := Sync(ctx, cfg, live, target); err != nil {
+			t.Fatal(err)
+		}
+		if report, err := VerifyTarget(ctx, cfg, target); err != nil || report.Damaged() {
+			t.Fatalf("VerifyTarget = %+v, %v", report, err)
+		}
+		stored := readFiles(t, vault)
+		delete(stored, manifestFile)
+		return stored
+	}
+	first := syncOnce()
+	if second := syncOnce(); !reflect.DeepEqual(second, first) {
+		t.Error("syncing unchanged saves changed the stored ciphertext")
+	}
+
+	writeFiles(t, liveDir, map[string]string{"user2.dat": "changed"})
+	third := syncOnce()
+	changed := 0
+	for name, data := range third {
+		if first[name] != data {
+			changed++
+		}
+	}
+	if changed != 1 {
+		t.Errorf("changing one save changed %d stored files, want 1", changed)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/notify"
 	"strconv"
 	"strings"
 	"time"
@@ -44,14 +46,62 @@ type Config struct {
 	RcloneConfigPath        string
 	ForceRcloneAuth         bool
 	LogLevel                string
-	RunClean                bool
+	LogFormat               string
+	LogFile                 string
+	DataDir                 string
+	RetryTimeout            time.Duration
+	SourceStrategy          string
+	Progress                string
+	ProgressFile            string
+	RcloneVersion           string
+	RcloneDownloadURL       string
+	Profile                 string
+	NotifySinks             []string
+	PracticeDir             string
+	Command                 string
+	Args                    []string
+	RestoreTo               string
+	// Options of the "launch" command: the practice save to load and its
+	// slot, and whether to throw the session's changes away.
+	PracticeName string
+	PracticeSlot int
+	Sandbox      bool
+	SandboxKeep  string
+	// StatsDays is how many days the "stats" command lists one by one.
+	StatsDays int
+	// Options of the "saves" commands.
+	SaveSlot  int
+	SaveJSON  bool
+	SaveSet   []string
+	SavePatch string
+	SaveOut   string
+	// Options of the "remote add" command.
+	RemoteServiceAccountFile string
+	RemoteToken              string
 }
 
+// Strategies for picking the save source at launch.
+const (
+	StrategyMtime    = "mtime"
+	StrategyPriority = "priority"
+	StrategyPlaytime = "playtime"
+	StrategyAsk      = "ask"
+)
+
 type SyncType int
 
 const (
 	Local SyncType = iota
-	Gdrive
+	// Remote is a target on any rclone remote, written as "name:path".
+	Remote
+)
+
+// Storage formats for a target.
+const (
+	// FormatMirror stores a plain copy of the save directory.
+	FormatMirror = "mirror"
+	// FormatSnapshot stores a deduplicated, compressed snapshot repository.
+	FormatSnapshot = "snapshot"
 )
 
 type SyncTarget struct {
@@ -60,7 +110,157 @@ type SyncTarget struct {
 	RemoteName string
 	Interval   time.Duration
 	SyncOnQuit *bool
-	Original   string
+	Format     string
+	Encryption *Encryption
+	Rclone     RcloneOptions
+	// Slots, if set, limits the target to these slots and maps them to its
+	// own slot numbers. SlotSpec is the map as written.
+	Slots    SlotMap
+	SlotSpec string
+	Original string
+
+	// unknownOptions are the options ParseTarget didn't recognise, reported
+	// by Validate.
+	unknownOptions []string
+	// secretOptions are the encryption secret options given, of which
+	// Validate allows only one.
+	secretOptions []string
+}
+
+// SlotMap maps live save slots to the slots a target stores them in.
+type SlotMap map[int]int
+
+// ParseSlotMap parses a comma-separated slot map such as "1:3,2" or "1-2":
+// each entry is a live slot, a range of live slots, or "live:target".
+func ParseSlotMap(spec string) (SlotMap, error) {
+	m := make(SlotMap)
+	stored := make(map[int]bool)
+	add := func(live, target int) error {
+		if live < 1 || target < 1 {
+			return fmt.Errorf("slot numbers start at 1")
+		}
+		if _, dup := m[live]; dup {
+			return fmt.Errorf("slot %d is mapped twice", live)
+		}
+		if stored[target] {
+			return fmt.Errorf("two slots are mapped to slot %d", target)
+		}
+		m[live], stored[target] = target, true
+		return nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		var err error
+		if from, to, ok := strings.Cut(entry, "-"); ok {
+			var first, last int
+			first, err = strconv.Atoi(from)
+			if err == nil {
+				last, err = strconv.Atoi(to)
+			}
+			if err == nil && first > last {
+				err = fmt.Errorf("the range is empty")
+			}
+			for slot := first; err == nil && slot <= last; slot++ {
+				err = add(slot, slot)
+			}
+		} else if live, target, ok := strings.Cut(entry, ":"); ok {
+			var l, t int
+			l, err = strconv.Atoi(live)
+			if err == nil {
+				t, err = strconv.Atoi(target)
+			}
+			if err == nil {
+				err = add(l, t)
+			}
+		} else {
+			var slot int
+			if slot, err = strconv.Atoi(entry); err == nil {
+				err = add(slot, slot)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid slot map entry %q: %w", entry, err)
+		}
+	}
+	return m, nil
+}
+
+// Inverse returns the map from the target's slots back to the live ones.
+func (m SlotMap) Inverse() SlotMap {
+	inverse := make(SlotMap, len(m))
+	for live, target := range m {
+		inverse[target] = live
+	}
+	return inverse
+}
+
+// RcloneOptions are rclone settings for one target. Values are kept as
+// written, in rclone's own syntax, e.g. "1M" or "30s".
+type RcloneOptions struct {
+	BwLimit    string
+	Transfers  string
+	Timeout    string
+	ConTimeout string
+	Retries    string
+	// Flags are backend flags such as "--drive-chunk-size=64M".
+	Flags []string
+}
+
+// Validate checks the options that rclone would otherwise reject on every call.
+func (o RcloneOptions) Validate() error {
+	for name, value := range map[string]string{"transfers": o.Transfers, "retries": o.Retries} {
+		if n, err := strconv.Atoi(value); value != "" && (err != nil || n < 1) {
+			return fmt.Errorf("%s must be a positive number, not %q", name, value)
+		}
+	}
+	for name, value := range map[string]string{"timeout": o.Timeout, "contimeout": o.ConTimeout} {
+		if _, err := time.ParseDuration(value); value != "" && err != nil {
+			return fmt.Errorf("%s must be a duration such as '30s', not %q", name, value)
+		}
+	}
+	for _, flag := range o.Flags {
+		if !strings.HasPrefix(flag, "--") || !strings.Contains(flag, "=") {
+			return fmt.Errorf("rclone flag %q must be written as --name=value", flag)
+		}
+	}
+	return nil
+}
+
+// Encryption holds the secret a target's backups are encrypted with. Exactly
+// one of Passphrase and KeyFile is set. Passphrases are only ever read from the
+// environment, so they never appear in target strings or logs.
+type Encryption struct {
+	Passphrase string
+	KeyFile    string
+}
+
+// IsPlainLocal reports whether the target's saves can be read directly from
+// its local path, without downloading, restoring or decrypting them first.
+func (t SyncTarget) IsPlainLocal() bool {
+	return t.Type == Local && t.Format != FormatSnapshot && t.Encryption == nil && t.Slots == nil
+}
+
+// Validate checks the options of a target that can't be used as written.
+func (t SyncTarget) Validate() error {
+	if len(t.unknownOptions) > 0 {
+		return fmt.Errorf("unknown option %q", t.unknownOptions[0])
+	}
+	if t.Format != FormatMirror && t.Format != FormatSnapshot {
+		return fmt.Errorf("unknown format %q", t.Format)
+	}
+	if len(t.secretOptions) > 1 {
+		// Only the last one would be used, which may not be the intended key.
+		return fmt.Errorf("only one encryption secret may be set, not %s", strings.Join(t.secretOptions, " and "))
+	}
+	if t.Encryption != nil && t.Encryption.Passphrase == "" && t.Encryption.KeyFile == "" {
+		return fmt.Errorf("encryption passphrase is empty")
+	}
+	if t.SlotSpec != "" {
+		if _, err := ParseSlotMap(t.SlotSpec); err != nil {
+			return err
+		}
+	}
+	return t.Rclone.Validate()
 }
 
 type stringSlice []string
@@ -75,15 +275,38 @@ func Load() (*Config, error) {
 	var installPath string
 	cfg.DownloadRetries = 1
 
-	fs.Var(&targets, "target", "Master/backup save location. Repeatable. Format: \"path|interval|quit_sync\"")
+	fs.Var(&targets, "target", "Master/backup save location. Repeatable. Format: \"path|interval|quit_sync|options\"")
 	fs.BoolVar(&cfg.SyncOnQuit, "sync-on-quit", false, "Globally enable sync on game exit for targets without a 'quit' option.")
 	fs.StringVar(&installPath, "install-path", "", "Path to the Hollow Knight game installation directory. Defaults to user's Documents/Hollow Knight.")
 	fs.Var(&cfg.DownloadRetries, "download-retries", "Number of times to retry download. If flag is present without a value, retries are infinite.")
 	fs.StringVar(&cfg.RcloneConfigPath, "config-path", "", "Path to the rclone.conf file. Defaults to 'rclone.conf' in the executable's directory.")
 	fs.BoolVar(&cfg.ForceRcloneAuth, "auth", false, "Force the rclone authentication wizard to run for online targets.")
-	fs.StringVar(&cfg.LogLevel, "log-level", "quiet", "Set logging verbosity. Options: info, warn, error, quiet.")
+	fs.StringVar(&cfg.LogLevel, "log-level", "quiet", "Set console logging verbosity. Options: debug, info, warn, error, quiet.")
+	fs.StringVar(&cfg.LogFormat, "log-format", "text", "Format of the console output and the log file. Options: text, json.")
+	fs.StringVar(&cfg.LogFile, "log-file", "", "Log file, always written at info level and rotated at 5 MiB. Defaults to 'logs/pirated-hollow-knight.log' in the data directory.")
+	fs.StringVar(&cfg.DataDir, "data-dir", "", "Directory for the launcher's own state (retry queue, caches). Defaults to 'data' in the executable's directory.")
+	fs.DurationVar(&cfg.RetryTimeout, "retry-timeout", 2*time.Minute, "How long to keep retrying failed backups after the game exits before leaving them for the next start.")
+	fs.StringVar(&cfg.SourceStrategy, "source-strategy", StrategyMtime, "How to pick the save source at launch. Options: mtime, priority, playtime, ask.")
+	fs.StringVar(&cfg.Progress, "progress", "auto", "How to show transfer progress. Options: auto, bar, json, none. 'auto' shows bars unless the log level is quiet.")
+	fs.StringVar(&cfg.ProgressFile, "progress-file", "", "File to append JSON progress events to. Defaults to stderr.")
+	fs.StringVar(&cfg.RcloneVersion, "rclone-version", "", "rclone version to install and keep, e.g. 'v1.68.2'. Defaults to the latest release.")
+	fs.StringVar(&cfg.RcloneDownloadURL, "rclone-download-url", "https://downloads.rclone.org", "Base URL rclone releases are downloaded from.")
+	fs.StringVar(&cfg.Profile, "profile", "default", "Name the sessions of this run are recorded under in the session history, e.g. one per player.")
+	fs.Var((*stringSlice)(&cfg.NotifySinks), "notify", "Send the report of every session to this webhook URL, or to the stdin of 'exec:command'. Repeatable.")
+	fs.StringVar(&cfg.PracticeDir, "practice-dir", "", "Directory of the practice save library. Defaults to 'practice' in the data directory.")
 	fs.Parse(os.Args[1:])
 
+	switch cfg.SourceStrategy {
+	case StrategyMtime, StrategyPriority, StrategyPlaytime, StrategyAsk:
+	default:
+		return nil, invalid("unknown source strategy %q", cfg.SourceStrategy)
+	}
+	switch cfg.Progress {
+	case "auto", "bar", "json", "none":
+	default:
+		return nil, invalid("unknown progress mode %q", cfg.Progress)
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("could not determine user home directory: %w", err)
@@ -96,16 +319,27 @@ func Load() (*Config, error) {
 		cfg.HollowKnightInstallPath = installPath
 	}
 
-	if cfg.RcloneConfigPath == "" {
+	if cfg.RcloneConfigPath == "" || cfg.DataDir == "" {
 		exePath, err := os.Executable()
 		if err != nil {
 			return nil, fmt.Errorf("could not determine application directory: %w", err)
 		}
-		cfg.RcloneConfigPath = filepath.Join(filepath.Dir(exePath), "rclone.conf")
+		if cfg.RcloneConfigPath == "" {
+			cfg.RcloneConfigPath = filepath.Join(filepath.Dir(exePath), "rclone.conf")
+		}
+		if cfg.DataDir == "" {
+			cfg.DataDir = filepath.Join(filepath.Dir(exePath), "data")
+		}
+	}
+	if cfg.PracticeDir == "" {
+		cfg.PracticeDir = filepath.Join(cfg.DataDir, "practice")
+	}
+	if cfg.LogFile == "" {
+		cfg.LogFile = filepath.Join(cfg.DataDir, "logs", "pirated-hollow-knight.log")
 	}
 
 	for i, t := range targets {
-		target := parseTargetString(t)
+		target := ParseTarget(t)
 		// The first target is no longer special and is treated like any other.
 		// We retain the logic to set sync on quit to true by default for it, as a convenience.
 		if i == 0 {
@@ -115,14 +349,120 @@ func Load() (*Config, error) {
 		cfg.SyncTargets = append(cfg.SyncTargets, target)
 	}
 
-	if fs.NArg() > 0 && fs.Arg(0) == "clean" {
-		cfg.RunClean = true
+	if fs.NArg() > 0 {
+		cfg.Command = fs.Arg(0)
+		cfg.Args = fs.Args()[1:]
+	}
+	switch cfg.Command {
+	case "", "clean", "status", "snapshots", "verify", "rclone", "practice":
+	case "launch":
+		cmdFlags := flag.NewFlagSet("launch", flag.ContinueOnError)
+		cmdFlags.StringVar(&cfg.PracticeName, "load-practice", "", "Name of a practice save to load for this session only.")
+		cmdFlags.IntVar(&cfg.PracticeSlot, "slot", 0, "Save slot to load the practice save into.")
+		cmdFlags.BoolVar(&cfg.Sandbox, "sandbox", false, "Discard every change the session makes to the saves.")
+		cmdFlags.StringVar(&cfg.SandboxKeep, "keep-sandbox", "", "Keep the saves of a sandbox session as a snapshot under this name.")
+		if cfg.Args, err = parseInterspersed(cmdFlags, cfg.Args); err != nil {
+			return nil, apperr.Wrap(apperr.ConfigInvalid, err)
+		}
+		if len(cfg.Args) > 0 {
+			return nil, invalid("launch takes no arguments, got %q", cfg.Args[0])
+		}
+		if (cfg.PracticeName == "") != (cfg.PracticeSlot == 0) {
+			return nil, invalid("--load-practice and --slot must be given together")
+		}
+		if cfg.PracticeSlot < 0 {
+			return nil, invalid("--slot must not be negative")
+		}
+		if cfg.SandboxKeep != "" && !cfg.Sandbox {
+			return nil, invalid("--keep-sandbox needs --sandbox")
+		}
+		if strings.ContainsAny(cfg.SandboxKeep, `/\:.`) {
+			return nil, invalid("--keep-sandbox %q must be a plain name", cfg.SandboxKeep)
+		}
+	case "stats":
+		cmdFlags := flag.NewFlagSet("stats", flag.ContinueOnError)
+		cmdFlags.IntVar(&cfg.StatsDays, "days", 14, "Number of most recent days to list play time for.")
+		if cfg.Args, err = parseInterspersed(cmdFlags, cfg.Args); err != nil {
+			return nil, apperr.Wrap(apperr.ConfigInvalid, err)
+		}
+	case "restore":
+		cmdFlags := flag.NewFlagSet("restore", flag.ContinueOnError)
+		cmdFlags.StringVar(&cfg.RestoreTo, "to", "", "Extract the snapshot into this directory instead of making it the target's latest snapshot.")
+		if cfg.Args, err = parseInterspersed(cmdFlags, cfg.Args); err != nil {
+			return nil, apperr.Wrap(apperr.ConfigInvalid, err)
+		}
+	case "saves":
+		cmdFlags := flag.NewFlagSet("saves", flag.ContinueOnError)
+		cmdFlags.IntVar(&cfg.SaveSlot, "slot", 0, "Save slot to work on. 0 means every slot.")
+		cmdFlags.BoolVar(&cfg.SaveJSON, "json", false, "Print the result as JSON.")
+		cmdFlags.Var((*stringSlice)(&cfg.SaveSet), "set", "Field to change, as field=value. Repeatable.")
+		cmdFlags.StringVar(&cfg.SavePatch, "patch", "", "JSON merge patch file to apply to the save.")
+		cmdFlags.StringVar(&cfg.SaveOut, "out", "", "File to write the exported bundle to. Defaults to a name made of the slot and the time.")
+		if cfg.Args, err = parseInterspersed(cmdFlags, cfg.Args); err != nil {
+			return nil, apperr.Wrap(apperr.ConfigInvalid, err)
+		}
+		if cfg.SaveSlot < 0 {
+			return nil, invalid("--slot must not be negative")
+		}
+	case "remote":
+		cmdFlags := flag.NewFlagSet("remote", flag.ContinueOnError)
+		cmdFlags.StringVar(&cfg.RemoteServiceAccountFile, "service-account-file", "", "Authenticate the new remote with this service-account JSON file.")
+		cmdFlags.StringVar(&cfg.RemoteToken, "token", "", "Authenticate the new remote with this OAuth token JSON, e.g. from 'rclone authorize' on another machine.")
+		if cfg.Args, err = parseInterspersed(cmdFlags, cfg.Args); err != nil {
+			return nil, apperr.Wrap(apperr.ConfigInvalid, err)
+		}
+	default:
+		return nil, invalid("unknown command %q", cfg.Command)
+	}
+
+	if cfg.Profile == "" {
+		return nil, invalid("--profile must not be empty")
+	}
+	for _, sink := range cfg.NotifySinks {
+		if err := notify.Validate(sink); err != nil {
+			return nil, apperr.Wrap(apperr.ConfigInvalid, err)
+		}
+	}
+	if cfg.RcloneVersion != "" && !strings.HasPrefix(cfg.RcloneVersion, "v") {
+		cfg.RcloneVersion = "v" + cfg.RcloneVersion
+	}
+	cfg.RcloneDownloadURL = strings.TrimSuffix(cfg.RcloneDownloadURL, "/")
+
+	for _, t := range cfg.SyncTargets {
+		if err := t.Validate(); err != nil {
+			return nil, invalid("target %q: %w", t.Original, err)
+		}
 	}
 
 	return cfg, nil
 }
 
-func parseTargetString(raw string) SyncTarget {
+// invalid returns a ConfigInvalid error for a bad flag or target.
+func invalid(format string, a ...any) error {
+	return apperr.Errorf(apperr.ConfigInvalid, format, a...)
+}
+
+// parseInterspersed parses a command's flags, which may appear before, between
+// or after its positional arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// ParseTarget parses a raw "path|interval|quit_sync|options" target string.
+// Options are comma-separated key=value pairs, e.g. "format=snapshot", or
+// rclone backend flags, e.g. "--drive-chunk-size=64M". The slot map of the
+// "slots" option may itself contain commas, e.g. "slots=1:3,2,format=snapshot".
+func ParseTarget(raw string) SyncTarget {
 	target := SyncTarget{Original: raw}
 	parts := strings.Split(raw, "|")
 	pathPart := parts[0]
@@ -132,7 +472,7 @@ func parseTargetString(raw string) SyncTarget {
 	// This is the updated logic. It now checks that the remote name is longer than one character,
 	// which correctly excludes Windows drive letters like "C:".
 	if len(remoteParts) == 2 && remoteParts[0] != "" && !strings.Contains(remoteParts[0], "\\") && len(remoteParts[0]) > 1 {
-		target.Type = Gdrive
+		target.Type = Remote
 		target.RemoteName = remoteParts[0]
 		target.Path = remoteParts[1]
 	} else {
@@ -158,5 +498,54 @@ func parseTargetString(raw string) SyncTarget {
 		}
 	}
 
+	target.Format = FormatMirror
+	if len(parts) > 3 && parts[3] != "" {
+		var slotSpecs []string
+		inSlots := false
+		for _, opt := range strings.Split(parts[3], ",") {
+			key, value, hasValue := strings.Cut(opt, "=")
+			if inSlots && !hasValue && !strings.HasPrefix(strings.TrimSpace(opt), "--") {
+				// Another entry of the slot map.
+				slotSpecs = append(slotSpecs, strings.TrimSpace(opt))
+				continue
+			}
+			inSlots = false
+			switch strings.TrimSpace(key) {
+			case "slots":
+				slotSpecs = append(slotSpecs, strings.TrimSpace(value))
+				inSlots = true
+			case "format":
+				target.Format = strings.TrimSpace(value)
+			case "passphrase-env":
+				target.Encryption = &Encryption{Passphrase: os.Getenv(strings.TrimSpace(value))}
+				target.secretOptions = append(target.secretOptions, "passphrase-env")
+			case "key-file":
+				target.Encryption = &Encryption{KeyFile: strings.TrimSpace(value)}
+				target.secretOptions = append(target.secretOptions, "key-file")
+			case "bwlimit":
+				target.Rclone.BwLimit = strings.TrimSpace(value)
+			case "transfers":
+				target.Rclone.Transfers = strings.TrimSpace(value)
+			case "timeout":
+				target.Rclone.Timeout = strings.TrimSpace(value)
+			case "contimeout":
+				target.Rclone.ConTimeout = strings.TrimSpace(value)
+			case "retries":
+				target.Rclone.Retries = strings.TrimSpace(value)
+			default:
+				if flag := strings.TrimSpace(opt); strings.HasPrefix(flag, "--") {
+					target.Rclone.Flags = append(target.Rclone.Flags, flag)
+				} else if flag != "" {
+					target.unknownOptions = append(target.unknownOptions, flag)
+				}
+			}
+		}
+		if slotSpecs != nil {
+			target.SlotSpec = strings.Join(slotSpecs, ",")
+			// An invalid map is reported by Validate.
+			target.Slots, _ = ParseSlotMap(target.SlotSpec)
+		}
+	}
+
 	return target
 }
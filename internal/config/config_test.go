@@ -0,0 +1,154 @@
+// /internal/config/config_test.go
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	yes := true
+	tests := []struct {
+		raw  string
+		want SyncTarget
+	}{
+		{
+			raw:  `C:\Saves|60|true`,
+			want: SyncTarget{Type: Local, Path: `C:\Saves`, Interval: time.Minute, SyncOnQuit: &yes, Format: FormatMirror},
+		},
+		{
+			raw:  "gdrive:HollowKnight|||format=snapshot",
+			want: SyncTarget{Type: Remote, RemoteName: "gdrive", Path: "HollowKnight", Format: FormatSnapshot},
+		},
+		{
+			raw: "s3:bucket/hk|300||bwlimit=1M, transfers=2,timeout=30s,contimeout=5s,retries=5,--s3-chunk-size=16M",
+			want: SyncTarget{Type: Remote, RemoteName: "s3", Path: "bucket/hk", Interval: 5 * time.Minute, Format: FormatMirror,
+				Rclone: RcloneOptions{BwLimit: "1M", Transfers: "2", Timeout: "30s", ConTimeout: "5s", Retries: "5", Flags: []string{"--s3-chunk-size=16M"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got := ParseTarget(tt.raw)
+			tt.want.Original = tt.raw
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTarget =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		raw     string
+		wantErr string
+	}{
+		{"gdrive:hk|||bwlimit=1M", ""},
+		{"gdrive:hk|||bwlimit=1M,fromat=snapshot", `unknown option "fromat=snapshot"`},
+		{"gdrive:hk|||snapshot", `unknown option "snapshot"`},
+		{"gdrive:hk|||format=zip", `unknown format "zip"`},
+		{"gdrive:hk|||transfers=0", "transfers must be a positive number"},
+		{"gdrive:hk|||retries=many", "retries must be a positive number"},
+		{"gdrive:hk|||timeout=30", "timeout must be a duration"},
+		{"gdrive:hk|||--drive-chunk-size", "must be written as --name=value"},
+		{"gdrive:hk|||passphrase-env=HK_TEST_UNSET_PASSPHRASE", "passphrase is empty"},
+		{"gdrive:hk|||key-file=hk.key", ""},
+		{"gdrive:hk|||passphrase-env=HK_PASSPHRASE,key-file=hk.key", "not passphrase-env and key-file"},
+		{"gdrive:hk|||key-file=a.key,key-file=b.key", "not key-file and key-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			err := ParseTarget(tt.raw).Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSlotMap(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    SlotMap
+		wantErr string
+	}{
+		{spec: "1", want: SlotMap{1: 1}},
+		{spec: "1:3, 2", want: SlotMap{1: 3, 2: 2}},
+		{spec: "1-3,4:1", wantErr: "two slots are mapped to slot 1"},
+		{spec: "2-4", want: SlotMap{2: 2, 3: 3, 4: 4}},
+		{spec: "1:2,1:3", wantErr: "slot 1 is mapped twice"},
+		{spec: "0", wantErr: "slot numbers start at 1"},
+		{spec: "3-1", wantErr: "the range is empty"},
+		{spec: "a:1", wantErr: `invalid slot map entry "a:1"`},
+		{spec: "", wantErr: `invalid slot map entry ""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseSlotMap(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseSlotMap error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSlotMap: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSlotMap = %v, want %v", got, tt.want)
+			}
+			if inverse := got.Inverse(); len(inverse) != len(got) {
+				t.Errorf("Inverse = %v", inverse)
+			}
+		})
+	}
+}
+
+func TestParseTargetSlots(t *testing.T) {
+	tests := []struct {
+		raw      string
+		wantSpec string
+		wantMap  SlotMap
+		wantErr  string
+	}{
+		{raw: "/saves|||slots=2", wantSpec: "2", wantMap: SlotMap{2: 2}},
+		{raw: "/saves|||slots=1:3,2,format=snapshot", wantSpec: "1:3,2", wantMap: SlotMap{1: 3, 2: 2}},
+		{raw: "gdrive:hk|||format=snapshot,slots=1-2,--drive-chunk-size=8M", wantSpec: "1-2", wantMap: SlotMap{1: 1, 2: 2}},
+		{raw: "/saves|||slots=1,1:2", wantSpec: "1,1:2", wantErr: "slot 1 is mapped twice"},
+		{raw: "/saves|||slots=1,x", wantSpec: "1,x", wantErr: `invalid slot map entry "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			target := ParseTarget(tt.raw)
+			if target.SlotSpec != tt.wantSpec {
+				t.Errorf("SlotSpec = %q, want %q", target.SlotSpec, tt.wantSpec)
+			}
+			err := target.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Validate error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if !reflect.DeepEqual(target.Slots, tt.wantMap) {
+				t.Errorf("Slots = %v, want %v", target.Slots, tt.wantMap)
+			}
+			if target.IsPlainLocal() {
+				t.Error("a target with a slot map is not plain local")
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// /internal/history/history.go
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/saves"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	historyFile    = "history.db"
+	sessionsBucket = "sessions"
+	// openTimeout bounds the wait for another process holding the database.
+	openTimeout = 5 * time.Second
+)
+
+// Session is one game session started by the launcher.
+type Session struct {
+	ID      uint64        `json:"id"`
+	Profile string        `json:"profile"`
+	Start   time.Time     `json:"start"`
+	End     time.Time     `json:"end"`
+	Elapsed time.Duration `json:"elapsed"`
+	// ExitCode is the game's exit code, -1 if it was killed.
+	ExitCode int `json:"exit_code"`
+	// Source is the target the session's saves were taken from.
+	Source string `json:"source"`
+	// Practice is the practice save loaded for the session, if any. Its
+	// progress is discarded, so it isn't part of Slots.
+	Practice string `json:"practice,omitempty"`
+	// Sandbox is set if the session's changes were thrown away.
+	Sandbox bool         `json:"sandbox,omitempty"`
+	Slots   []SlotResult `json:"slots,omitempty"`
+	Syncs   []SyncResult `json:"syncs,omitempty"`
+}
+
+// SlotResult is what a session did to one save slot.
+type SlotResult struct {
+	Slot    int  `json:"slot"`
+	Changed bool `json:"changed"`
+	saves.Changes
+}
+
+// SyncResult is the outcome of copying the session's saves to one target.
+type SyncResult struct {
+	Target string `json:"target"`
+	// Kind is "swap-out" for the targets the saves came from.
+	Kind  string `json:"kind"`
+	Error string `json:"error,omitempty"`
+	// Retried is set if the sync failed at first but a retry before exit succeeded.
+	Retried bool `json:"retried,omitempty"`
+}
+
+// Store is the session history kept in the data directory.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the history database in dataDir, creating it if needed. It
+// must be closed again, since it can only be open in one process at a time.
+func Open(dataDir string) (*Store, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(dataDir, historyFile), 0644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("could not open session history: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(sessionsBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not initialise session history: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add stores a session and sets its ID.
+func (s *Store) Add(session *Session) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(sessionsBucket))
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+		data, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return b.Put(sessionKey(id), data)
+	})
+}
+
+// Sessions returns every recorded session, oldest first.
+func (s *Store) Sessions() ([]Session, error) {
+	var sessions []Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(sessionsBucket)).ForEach(func(k, v []byte) error {
+			var session Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return fmt.Errorf("could not parse session %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			sessions = append(sessions, session)
+			return nil
+		})
+	})
+	return sessions, err
+}
+
+// sessionKey is big-endian, so that sessions are iterated in the order they were added.
+func sessionKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
@@ -0,0 +1,58 @@
+// /internal/history/history_test.go
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	sessions := []Session{
+		{Profile: "main", Start: start, End: start.Add(time.Hour), Elapsed: time.Hour, Source: "gdrive:HK",
+			Slots: []SlotResult{{Slot: 1, Changed: true}},
+			Syncs: []SyncResult{{Target: "gdrive:HK", Kind: "swap-out"}}},
+		{Profile: "speedrun", Start: start.Add(2 * time.Hour), ExitCode: -1},
+		{Profile: "main", Start: start.Add(4 * time.Hour), Syncs: []SyncResult{{Target: "D:/HK", Kind: "swap-out", Error: "disk full", Retried: true}}},
+	}
+
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range sessions {
+		if err := store.Add(&sessions[i]); err != nil {
+			t.Fatal(err)
+		}
+		if want := uint64(i + 1); sessions[i].ID != want {
+			t.Errorf("session %d got ID %d, want %d", i, sessions[i].ID, want)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sessions are read back from a fresh handle, as the stats command does.
+	store, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	got, err := store.Sessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, sessions) {
+		t.Errorf("Sessions() = %+v\nwant %+v", got, sessions)
+	}
+
+	extra := Session{Profile: "main", Start: start.Add(6 * time.Hour)}
+	if err := store.Add(&extra); err != nil {
+		t.Fatal(err)
+	}
+	if extra.ID != 4 {
+		t.Errorf("session added after reopening got ID %d, want 4", extra.ID)
+	}
+}
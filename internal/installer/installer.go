@@ -2,12 +2,11 @@
 package installer
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"mime"
 	"net/http"
@@ -15,6 +14,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
 	"pirated-hollow-knight/internal/backup"
 	"pirated-hollow-knight/internal/config"
 	"pirated-hollow-knight/internal/log"
@@ -26,10 +26,10 @@ import (
 	"github.com/schollz/progressbar/v3"
 )
 
-const (
-	expectedSHA1      = "edf6dbde9a65a6304e096b61b0b2226a6e8a2416"
-	rcloneDownloadURL = "https://downloads.rclone.org/rclone-current-windows-amd64.zip"
-)
+// logger tags the messages of this package with its subsystem.
+var logger = log.For("installer")
+
+const expectedSHA1 = "edf6dbde9a65a6304e096b61b0b2226a6e8a2416"
 
 type Extractor struct {
 	Path string
@@ -37,27 +37,27 @@ type Extractor struct {
 }
 
 func EnsureDependencies(ctx context.Context, cfg *config.Config) error {
-	log.Log.Info("--- Checking Dependencies ---")
+	logger.Info("--- Checking Dependencies ---")
 	if err := ensureHollowKnightInstalled(ctx, cfg); err != nil {
-		return err
+		return apperr.Wrap(apperr.DependencyMissing, err)
 	}
 	if err := ensureRcloneInstalled(ctx, cfg); err != nil {
-		return err
+		return apperr.Wrap(apperr.DependencyMissing, err)
 	}
-	log.Log.Info("--- All dependencies are satisfied ---")
+	logger.Info("--- All dependencies are satisfied ---")
 	return nil
 }
 
 func ensureHollowKnightInstalled(ctx context.Context, cfg *config.Config) error {
 	if util.PathExists(cfg.HollowKnightInstallPath) {
-		log.Log.Info("✅ Hollow Knight installation found at: %s", cfg.HollowKnightInstallPath)
+		logger.Info("✅ Hollow Knight installation found at: %s", cfg.HollowKnightInstallPath)
 		return nil
 	}
-	log.Log.Warn("Hollow Knight installation not found. Starting download process...")
+	logger.Warn("Hollow Knight installation not found. Starting download process...")
 	if err := downloadAndExtractHollowKnight(ctx, cfg); err != nil {
 		return fmt.Errorf("failed to install Hollow Knight: %w", err)
 	}
-	log.Log.Info("✅ Hollow Knight installed successfully.")
+	logger.Info("✅ Hollow Knight installed successfully.")
 	return nil
 }
 
@@ -85,23 +85,23 @@ func downloadAndExtractHollowKnight(ctx context.Context, cfg *config.Config) err
 	// This loop handles both finite and infinite retries.
 	for i := 1; ; i++ {
 		if isInfinite {
-			log.Log.Info("Download attempt %d (retrying indefinitely)...", i)
+			logger.Info("Download attempt %d (retrying indefinitely)...", i)
 		} else {
 			totalAttempts := int(cfg.DownloadRetries) + 1
 			if i > totalAttempts {
 				break
 			}
-			log.Log.Info("Download attempt %d of %d...", i, totalAttempts)
+			logger.Info("Download attempt %d of %d...", i, totalAttempts)
 		}
 
 		// Perform download and verification
 		if err := downloadFileWithProgress(ctx, finalURL, downloadedFilePath); err != nil {
 			lastErr = err
-			log.Log.Warn("Attempt failed (download): %v", err)
+			logger.Warn("Attempt failed (download): %v", err)
 			_ = os.Remove(downloadedFilePath) // Clean up partial file
-		} else if err := verifySHA1(downloadedFilePath, expectedSHA1); err != nil {
+		} else if err := verifyHash(downloadedFilePath, "SHA-1", sha1.New(), expectedSHA1); err != nil {
 			lastErr = err
-			log.Log.Warn("Attempt failed (verification): %v", err)
+			logger.Warn("Attempt failed (verification): %v", err)
 			_ = os.Remove(downloadedFilePath)
 		} else {
 			// Success!
@@ -137,7 +137,7 @@ func downloadAndExtractHollowKnight(ctx context.Context, cfg *config.Config) err
 			if err := os.Rename(oldPath, cfg.HollowKnightInstallPath); err != nil {
 				return err
 			}
-			log.Log.Info("✅ Game installed to %s", cfg.HollowKnightInstallPath)
+			logger.Info("✅ Game installed to %s", cfg.HollowKnightInstallPath)
 			return nil
 		}
 	}
@@ -146,31 +146,41 @@ func downloadAndExtractHollowKnight(ctx context.Context, cfg *config.Config) err
 
 // --- Rest of installer.go remains unchanged ---
 func ensureRcloneInstalled(ctx context.Context, cfg *config.Config) error {
-	gdriveTargets := getGdriveTargets(cfg)
-	if len(gdriveTargets) == 0 {
-		log.Log.Info("No GDrive targets specified, skipping rclone check.")
+	remoteTargets := getRemoteTargets(cfg)
+	if len(remoteTargets) == 0 {
+		logger.Info("No remote targets specified, skipping rclone check.")
 		return nil
 	}
-	log.Log.Info("GDrive target(s) found, checking rclone setup...")
-	if _, err := exec.LookPath("rclone"); err != nil {
-		exePath, _ := os.Executable()
-		localRclonePath := filepath.Join(filepath.Dir(exePath), "rclone.exe")
-		if !util.PathExists(localRclonePath) {
-			log.Log.Warn("rclone.exe not found. Starting automatic download...")
-			if err := downloadAndExtractRclone(ctx, localRclonePath); err != nil {
-				return fmt.Errorf("failed to automatically install rclone: %w", err)
-			}
-			log.Log.Info("✅ rclone.exe installed successfully.")
+	logger.Info("Remote target(s) found, checking rclone setup...")
+	if err := ensureRcloneBinary(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to automatically install rclone: %w", err)
+	}
+	if err := configureRemotes(cfg, remoteTargets); err != nil {
+		return err
+	}
+	// A failed listing doesn't stop the launch: the remote may just be offline,
+	// in which case its offline cache is used. Options such as timeouts can
+	// make or break a listing, so each target's own options are used.
+	for _, target := range backup.RemoteChecks(remoteTargets) {
+		if err := backup.CheckRemote(ctx, cfg, target.RemoteName, target.Rclone); err != nil {
+			logger.Warn("If the credentials of '%s' are wrong, fix them with `remote add` or `--auth`. %v", target.RemoteName, err)
+			continue
 		}
-	} else {
-		log.Log.Info("✅ rclone found in PATH.")
+		logger.Info("✅ Remote '%s' answered a test listing.", target.RemoteName)
 	}
+	logger.Info("✅ Rclone configuration verified.")
+	return nil
+}
+
+// configureRemotes runs the rclone configuration wizard if it was asked for,
+// or if the config file or a remote the targets use is missing.
+func configureRemotes(cfg *config.Config, remoteTargets []config.SyncTarget) error {
 	if cfg.ForceRcloneAuth {
-		log.Log.Warn("`--auth` flag detected. Forcing rclone configuration wizard...")
+		logger.Warn("`--auth` flag detected. Forcing rclone configuration wizard...")
 		return backup.RunRcloneConfigWizard(cfg)
 	}
 	if !util.PathExists(cfg.RcloneConfigPath) {
-		log.Log.Warn("Rclone config not found at '%s'. Starting one-time setup...", cfg.RcloneConfigPath)
+		logger.Warn("Rclone config not found at '%s'. Starting one-time setup...", cfg.RcloneConfigPath)
 		return backup.RunRcloneConfigWizard(cfg)
 	}
 	remotes, err := backup.GetConfiguredRemotes(cfg)
@@ -178,74 +188,27 @@ func ensureRcloneInstalled(ctx context.Context, cfg *config.Config) error {
 		return fmt.Errorf("could not verify rclone configuration: %w", err)
 	}
 	allRemotesFound := true
-	for _, target := range gdriveTargets {
+	for _, target := range remoteTargets {
 		if _, found := remotes[target.RemoteName]; !found {
-			log.Log.Warn("Remote '%s' is specified in a target but not found in the config file.", target.RemoteName)
+			logger.Warn("Remote '%s' is specified in a target but not found in the config file.", target.RemoteName)
 			allRemotesFound = false
 		}
 	}
 	if !allRemotesFound {
-		log.Log.Warn("One or more required remotes are missing. Starting configuration wizard...")
+		logger.Warn("One or more required remotes are missing. Starting configuration wizard...")
 		return backup.RunRcloneConfigWizard(cfg)
 	}
-	log.Log.Info("✅ Rclone configuration verified.")
 	return nil
 }
 
-func getGdriveTargets(cfg *config.Config) []config.SyncTarget {
-	var gdriveTargets []config.SyncTarget
+func getRemoteTargets(cfg *config.Config) []config.SyncTarget {
+	var remoteTargets []config.SyncTarget
 	for _, t := range cfg.SyncTargets {
-		if t.Type == config.Gdrive {
-			gdriveTargets = append(gdriveTargets, t)
+		if t.Type == config.Remote {
+			remoteTargets = append(remoteTargets, t)
 		}
 	}
-	return gdriveTargets
-}
-
-func downloadAndExtractRclone(ctx context.Context, destPath string) error {
-	log.Log.Info("Downloading rclone from %s...", rcloneDownloadURL)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", rcloneDownloadURL, nil)
-	if err != nil {
-		return err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	log.Log.Info("Download complete. Extracting rclone.exe...")
-	zipReader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
-	if err != nil {
-		return err
-	}
-
-	for _, file := range zipReader.File {
-		if strings.HasSuffix(file.Name, "rclone.exe") {
-			rc, _ := file.Open()
-			defer rc.Close()
-			outFile, _ := os.Create(destPath)
-			defer outFile.Close()
-			_, err := io.Copy(outFile, rc)
-			if err != nil {
-				return err
-			}
-			log.Log.Info("Successfully extracted rclone.exe to %s", destPath)
-			return nil
-		}
-	}
-	return fmt.Errorf("could not find rclone.exe in archive")
+	return remoteTargets
 }
 
 func findExtractor() (*Extractor, error) {
@@ -271,14 +234,13 @@ func findExtractor() (*Extractor, error) {
 	return nil, fmt.Errorf("no supported extractor found (WinRAR or 7-Zip)")
 }
 
-func verifySHA1(filePath, expectedHash string) error {
-	log.Log.Info("Verifying SHA-1 hash for %s...", filepath.Base(filePath))
+func verifyHash(filePath, name string, hasher hash.Hash, expectedHash string) error {
+	logger.Info("Verifying %s hash for %s...", name, filepath.Base(filePath))
 	file, err := os.Open(filePath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	hasher := sha1.New()
 	if _, err := io.Copy(hasher, file); err != nil {
 		return err
 	}
@@ -286,7 +248,7 @@ func verifySHA1(filePath, expectedHash string) error {
 	if calculatedHash != expectedHash {
 		return fmt.Errorf("hash mismatch: expected %s, got %s", expectedHash, calculatedHash)
 	}
-	log.Log.Info("✅ SHA-1 hash verification successful.")
+	logger.Info("✅ %s hash verification successful.", name)
 	return nil
 }
 
@@ -305,19 +267,24 @@ func downloadFileWithProgress(ctx context.Context, url, destPath string) error {
 		return fmt.Errorf("bad status: %s", resp.Status)
 	}
 
-	f, _ := os.Create(destPath)
-	defer f.Close()
+	f, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
 
 	bar := progressbar.DefaultBytes(
 		resp.ContentLength,
 		filepath.Base(destPath),
 	)
-	_, err = io.Copy(io.MultiWriter(f, bar), resp.Body)
-	return err
+	if _, err := io.Copy(io.MultiWriter(f, bar), resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 func getFinalURLFromHTMX(ctx context.Context, htmxURL string) (string, error) {
-	log.Log.Info("Simulating htmx request to get redirect URL...")
+	logger.Info("Simulating htmx request to get redirect URL...")
 	client := &http.Client{
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
@@ -352,7 +319,7 @@ func getFinalURLFromHTMX(ctx context.Context, htmxURL string) (string, error) {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		return "", fmt.Errorf("server response did not contain HX-Redirect header. Status: %s, Body: %s", resp.Status, string(bodyBytes))
 	}
-	log.Log.Info("✅ Successfully found HX-Redirect header: %s", redirectURL)
+	logger.Info("✅ Successfully found HX-Redirect header: %s", redirectURL)
 	return redirectURL, nil
 }
 
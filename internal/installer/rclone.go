@@ -0,0 +1,290 @@
+// /internal/installer/rclone.go
+package installer
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/util"
+	"runtime"
+	"strings"
+)
+
+// RcloneInfo describes the rclone the launcher uses and the one it would install.
+type RcloneInfo struct {
+	Path      string
+	Installed string
+	Pinned    string
+	Latest    string
+}
+
+// ensureRcloneBinary makes sure a usable rclone exists. Without a pinned
+// version any rclone will do, preferring the launcher's own copy; with one,
+// the launcher's copy is (re)installed unless an rclone of that version exists.
+func ensureRcloneBinary(ctx context.Context, cfg *config.Config) error {
+	localPath, err := backup.LocalRclonePath()
+	if err != nil {
+		return err
+	}
+	if cfg.RcloneVersion == "" {
+		if util.PathExists(localPath) {
+			logger.Info("✅ rclone found at %s.", localPath)
+			return nil
+		}
+		if _, err := exec.LookPath("rclone"); err == nil {
+			logger.Info("✅ rclone found in PATH.")
+			return nil
+		}
+		logger.Warn("rclone not found. Starting automatic download...")
+	} else {
+		candidates := []string{localPath}
+		if !util.PathExists(localPath) {
+			if p, err := exec.LookPath("rclone"); err == nil {
+				candidates = []string{p}
+			}
+		}
+		for _, p := range candidates {
+			if version, err := installedRcloneVersion(ctx, p); err == nil && version == cfg.RcloneVersion {
+				logger.Info("✅ rclone %s found at %s.", version, p)
+				return nil
+			}
+		}
+		logger.Warn("rclone %s not found. Starting automatic download...", cfg.RcloneVersion)
+	}
+	version, err := installRclone(ctx, cfg, localPath)
+	if err != nil {
+		return err
+	}
+	logger.Info("✅ rclone %s installed successfully.", version)
+	return nil
+}
+
+// UpdateRclone installs the pinned rclone version, or the latest release, as
+// the launcher's own copy, unless that version is already installed.
+func UpdateRclone(ctx context.Context, cfg *config.Config) (string, error) {
+	localPath, err := backup.LocalRclonePath()
+	if err != nil {
+		return "", err
+	}
+	want, err := resolveRcloneVersion(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	if util.PathExists(localPath) {
+		if version, err := installedRcloneVersion(ctx, localPath); err == nil && version == want {
+			logger.Info("rclone %s is already installed at %s.", version, localPath)
+			return version, nil
+		}
+	}
+	return installRclone(ctx, cfg, localPath)
+}
+
+// GetRcloneInfo reports which rclone the launcher runs and which version an
+// update would install. The latest release is only looked up without a pin.
+func GetRcloneInfo(ctx context.Context, cfg *config.Config) (RcloneInfo, error) {
+	info := RcloneInfo{Pinned: cfg.RcloneVersion}
+	localPath, err := backup.LocalRclonePath()
+	if err != nil {
+		return info, err
+	}
+	if util.PathExists(localPath) {
+		info.Path = localPath
+	} else if p, err := exec.LookPath("rclone"); err == nil {
+		info.Path = p
+	}
+	if info.Path != "" {
+		if info.Installed, err = installedRcloneVersion(ctx, info.Path); err != nil {
+			return info, err
+		}
+	}
+	if info.Pinned == "" {
+		if info.Latest, err = latestRcloneVersion(ctx, cfg); err != nil {
+			return info, err
+		}
+	}
+	return info, nil
+}
+
+// installRclone downloads the rclone build for this platform, checks it
+// against the release's SHA256SUMS and extracts the binary to destPath.
+func installRclone(ctx context.Context, cfg *config.Config, destPath string) (string, error) {
+	version, err := resolveRcloneVersion(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	archive := fmt.Sprintf("rclone-%s-%s.zip", version, rcloneBuild())
+	releaseURL := cfg.RcloneDownloadURL + "/" + version
+
+	sums, err := fetchText(ctx, releaseURL+"/SHA256SUMS")
+	if err != nil {
+		return "", fmt.Errorf("could not fetch checksums of rclone %s: %w", version, err)
+	}
+	expected, err := findChecksum(sums, archive)
+	if err != nil {
+		return "", err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "rclone-download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	archivePath := filepath.Join(tmpDir, archive)
+
+	logger.Info("Downloading rclone from %s/%s...", releaseURL, archive)
+	if err := downloadFileWithProgress(ctx, releaseURL+"/"+archive, archivePath); err != nil {
+		return "", fmt.Errorf("could not download '%s': %w", archive, err)
+	}
+	if err := verifyHash(archivePath, "SHA-256", sha256.New(), expected); err != nil {
+		return "", err
+	}
+	if err := extractRclone(archivePath, destPath); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// resolveRcloneVersion returns the pinned version, or looks up the latest one.
+func resolveRcloneVersion(ctx context.Context, cfg *config.Config) (string, error) {
+	if cfg.RcloneVersion != "" {
+		return cfg.RcloneVersion, nil
+	}
+	return latestRcloneVersion(ctx, cfg)
+}
+
+// latestRcloneVersion reads the current release from version.txt, which holds
+// a line like "rclone v1.68.2".
+func latestRcloneVersion(ctx context.Context, cfg *config.Config) (string, error) {
+	text, err := fetchText(ctx, cfg.RcloneDownloadURL+"/version.txt")
+	if err != nil {
+		return "", fmt.Errorf("could not look up the latest rclone version: %w", err)
+	}
+	version, ok := parseRcloneVersion(text)
+	if !ok {
+		return "", fmt.Errorf("unexpected rclone version.txt: %q", strings.TrimSpace(text))
+	}
+	return version, nil
+}
+
+// installedRcloneVersion runs `rclone version` and returns the version it reports.
+func installedRcloneVersion(ctx context.Context, rclonePath string) (string, error) {
+	out, err := exec.CommandContext(ctx, rclonePath, "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not run '%s version': %w", rclonePath, err)
+	}
+	version, ok := parseRcloneVersion(string(out))
+	if !ok {
+		return "", fmt.Errorf("unexpected output from '%s version'", rclonePath)
+	}
+	return version, nil
+}
+
+// parseRcloneVersion extracts "v1.68.2" from text starting with "rclone v1.68.2".
+func parseRcloneVersion(text string) (string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 || fields[0] != "rclone" || !strings.HasPrefix(fields[1], "v") {
+		return "", false
+	}
+	return fields[1], true
+}
+
+// rcloneBuild names the rclone build for this platform, e.g. "windows-amd64".
+func rcloneBuild() string {
+	goos, goarch := runtime.GOOS, runtime.GOARCH
+	if goos == "darwin" {
+		goos = "osx"
+	}
+	if goarch == "arm" {
+		goarch = "arm-v7"
+	}
+	return goos + "-" + goarch
+}
+
+// findChecksum looks up the SHA-256 of name in a SHA256SUMS file. The file is
+// PGP clear-signed, so lines that aren't "<hash>  <name>" are skipped.
+func findChecksum(sums, name string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name && len(fields[0]) == sha256.Size*2 {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for '%s' in SHA256SUMS; is there an rclone build for this platform?", name)
+}
+
+// extractRclone copies the rclone binary out of the release archive. It is
+// written next to destPath first, so a failed update leaves the old binary.
+func extractRclone(archivePath, destPath string) error {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("could not open rclone archive: %w", err)
+	}
+	defer r.Close()
+
+	binary := filepath.Base(destPath)
+	for _, file := range r.File {
+		if path.Base(file.Name) != binary {
+			continue
+		}
+		tmpPath := destPath + ".tmp"
+		if err := extractFile(file, tmpPath); err != nil {
+			_ = os.Remove(tmpPath)
+			return fmt.Errorf("could not extract '%s': %w", file.Name, err)
+		}
+		if err := os.Rename(tmpPath, destPath); err != nil {
+			_ = os.Remove(tmpPath)
+			return err
+		}
+		logger.Info("Successfully extracted %s to %s", binary, destPath)
+		return nil
+	}
+	return fmt.Errorf("could not find %s in archive", binary)
+}
+
+func extractFile(file *zip.File, destPath string) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func fetchText(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status from %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
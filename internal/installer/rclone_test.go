@@ -0,0 +1,179 @@
+// /internal/installer/rclone_test.go
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"testing"
+)
+
+const testRcloneVersion = "v1.0.0"
+
+// rcloneArchive returns a release archive holding binary with the given contents.
+func rcloneArchive(t *testing.T, binary string, contents []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	dir := fmt.Sprintf("rclone-%s-%s", testRcloneVersion, rcloneBuild())
+	for name, data := range map[string][]byte{
+		dir + "/README.txt": []byte("readme"),
+		dir + "/" + binary:  contents,
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rcloneServer serves a release directory with archive and a SHA256SUMS
+// listing sums, in the layout of downloads.rclone.org.
+func rcloneServer(t *testing.T, archive []byte, sums string) *httptest.Server {
+	t.Helper()
+	name := fmt.Sprintf("rclone-%s-%s.zip", testRcloneVersion, rcloneBuild())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+testRcloneVersion+"/SHA256SUMS", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, sums)
+	})
+	mux.HandleFunc("/"+testRcloneVersion+"/"+name, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestInstallRclone(t *testing.T) {
+	name := fmt.Sprintf("rclone-%s-%s.zip", testRcloneVersion, rcloneBuild())
+	binary := []byte("#!/bin/sh\necho rclone\n")
+	archive := rcloneArchive(t, "rclone", binary)
+	tampered := rcloneArchive(t, "rclone", []byte("#!/bin/sh\necho evil\n"))
+	signed := "-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA1\n\n%s  %s\n-----BEGIN PGP SIGNATURE-----\n"
+
+	tests := []struct {
+		name    string
+		served  []byte
+		sums    string
+		wantErr bool
+	}{
+		{"matching archive", archive, fmt.Sprintf(signed, sha256Hex(archive), name), false},
+		{"tampered archive", tampered, fmt.Sprintf(signed, sha256Hex(archive), name), true},
+		{"no checksum for this build", archive, fmt.Sprintf(signed, sha256Hex(archive), "rclone-v1.0.0-plan9-386.zip"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := rcloneServer(t, tt.served, tt.sums)
+			cfg := &config.Config{RcloneDownloadURL: server.URL, RcloneVersion: testRcloneVersion}
+			dest := filepath.Join(t.TempDir(), "rclone")
+
+			version, err := installRclone(context.Background(), cfg, dest)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("installRclone succeeded, want an error")
+				}
+				if _, err := os.Stat(dest); !os.IsNotExist(err) {
+					t.Errorf("a failed install left %s behind", dest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("installRclone: %v", err)
+			}
+			if version != testRcloneVersion {
+				t.Errorf("version = %q, want %q", version, testRcloneVersion)
+			}
+			got, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, binary) {
+				t.Errorf("installed binary = %q, want %q", got, binary)
+			}
+		})
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	hash := sha256Hex([]byte("archive"))
+	sums := "-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA1\n\n" +
+		"0123  rclone-v1.0.0-linux-arm64.zip\n" +
+		hash + "  rclone-v1.0.0-linux-amd64.zip\n" +
+		"-----BEGIN PGP SIGNATURE-----\n"
+
+	tests := []struct {
+		name    string
+		file    string
+		want    string
+		wantErr bool
+	}{
+		{"listed", "rclone-v1.0.0-linux-amd64.zip", hash, false},
+		{"malformed hash", "rclone-v1.0.0-linux-arm64.zip", "", true},
+		{"not listed", "rclone-v1.0.0-osx-arm64.zip", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findChecksum(sums, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findChecksum error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("findChecksum = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractRclone(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "rclone.zip")
+	if err := os.WriteFile(archivePath, rcloneArchive(t, "rclone", []byte("new")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "rclone")
+	if err := os.WriteFile(dest, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractRclone(archivePath, dest); err != nil {
+		t.Fatalf("extractRclone: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "new" {
+		t.Errorf("binary = %q, want %q", got, "new")
+	}
+
+	// An archive without the binary leaves the installed one alone.
+	missing := filepath.Join(dir, "rclone.exe")
+	if err := os.WriteFile(missing, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := extractRclone(archivePath, missing); err == nil {
+		t.Fatal("extractRclone succeeded without the binary in the archive")
+	}
+	if got, _ := os.ReadFile(missing); string(got) != "old" {
+		t.Errorf("binary = %q after a failed extract, want %q", got, "old")
+	}
+	if _, err := os.Stat(missing + ".tmp"); !os.IsNotExist(err) {
+		t.Error("a failed extract left its temporary file behind")
+	}
+}
@@ -0,0 +1,140 @@
+// /internal/launcher/bundle.go
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"time"
+)
+
+// runSavesExport writes one slot, with its backups and a manifest, to a
+// single bundle file that can be imported on another machine.
+func runSavesExport(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return apperr.New(apperr.ConfigInvalid, savesUsage)
+	}
+	stagingDir, err := os.MkdirTemp("", "hk-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+	source, err := openSaveSource(ctx, cfg, args[0], filepath.Join(stagingDir, "saves"))
+	if err != nil {
+		return err
+	}
+
+	dir, slot := source.dir, cfg.SaveSlot
+	if source.file != "" {
+		var ok bool
+		dir = filepath.Dir(source.file)
+		if slot, ok = saves.SlotOf(filepath.Base(source.file)); !ok {
+			return apperr.Errorf(apperr.ConfigInvalid, "'%s' is not named like a save slot (userN.dat)", source.file)
+		}
+	} else if slot == 0 {
+		return apperr.New(apperr.ConfigInvalid, "--slot is required to export from a target")
+	}
+
+	out := cfg.SaveOut
+	if out == "" {
+		out = fmt.Sprintf("slot%d-%s%s", slot, time.Now().Format("20060102-150405"), saves.BundleExt)
+	}
+	manifest, err := saves.WriteBundle(out, dir, slot, source.label)
+	if os.IsNotExist(err) {
+		return apperr.Errorf(apperr.SourceUnavailable, "'%s' has no save in slot %d", source.label, slot)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Prompt("Exported slot %d of %s to '%s':", slot, source.label, out)
+	printBundleSummary(manifest)
+	return nil
+}
+
+// runSavesImport checks a bundle and installs its slot into a target or the
+// live save directory, renumbered to --slot if given. Whatever was in that
+// slot is snapshotted first.
+func runSavesImport(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 2 {
+		return apperr.New(apperr.ConfigInvalid, savesUsage)
+	}
+	bundle, err := saves.ReadBundle(args[0])
+	if err != nil {
+		return apperr.Wrap(apperr.VerifyFailed, fmt.Errorf("'%s': %w", args[0], err))
+	}
+	from := bundle.Manifest.Slot
+	to := from
+	if cfg.SaveSlot > 0 {
+		to = cfg.SaveSlot
+	}
+
+	release, err := lockSaves()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	stagingDir, err := os.MkdirTemp("", "hk-import-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+	dest, err := openSaveSource(ctx, cfg, args[1], filepath.Join(stagingDir, "saves"))
+	if err != nil {
+		return err
+	}
+	if dest.file != "" {
+		return apperr.New(apperr.ConfigInvalid, "bundles are imported into a target or 'live', not a save file")
+	}
+	if err := refuseSnapshot(dest, "import into"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest.dir, 0755); err != nil {
+		return err
+	}
+
+	existing, err := saves.SlotFiles(dest.dir, to)
+	if err != nil {
+		return err
+	}
+	// The saves are snapshotted even if the slot is empty, so that undoing the
+	// import empties it again.
+	backupTarget, err := snapshotBeforeChange(ctx, cfg, dest)
+	if err != nil {
+		return fmt.Errorf("could not snapshot the saves before importing, nothing was changed: %w", err)
+	}
+	if len(existing) > 0 {
+		logger.Prompt("Slot %d of %s was replaced; the previous saves are the newest snapshot of '%s'.", to, dest.label, backupTarget.Original)
+	}
+	for _, name := range existing {
+		if err := os.Remove(filepath.Join(dest.dir, name)); err != nil {
+			return err
+		}
+	}
+	for name, data := range bundle.Files {
+		if err := writeFileAtomic(filepath.Join(dest.dir, saves.RenumberFile(name, from, to)), data); err != nil {
+			return err
+		}
+	}
+	if dest.staged {
+		if err := backup.Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: dest.dir}, dest.target); err != nil {
+			return apperr.Wrap(apperr.SyncFailed, fmt.Errorf("could not write the imported save to '%s': %w", dest.target.Original, err))
+		}
+	}
+
+	logger.Prompt("Imported slot %d of %s (exported %s) into slot %d of %s:", from, bundle.Manifest.Source, formatModTime(bundle.Manifest.Created), to, dest.label)
+	printBundleSummary(&bundle.Manifest)
+	logger.Prompt("Undo with `%s`.", undoCommand(args[1], dest, to))
+	return nil
+}
+
+func printBundleSummary(m *saves.BundleManifest) {
+	s := m.Summary
+	logger.Prompt("  %d file(s), game version %s", len(m.Files), m.GameVersion)
+	logger.Prompt("  %s played, %.1f%% complete, %d geo, %d mask(s), %d soul vessel(s), %d charm(s)", formatPlayTime(s.PlayTime), s.Completion, s.Geo, s.Masks, s.Vessels, s.Charms)
+}
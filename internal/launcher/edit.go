@@ -0,0 +1,333 @@
+// /internal/launcher/edit.go
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"pirated-hollow-knight/internal/util"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// editBackupsDir holds, in the data directory, a snapshot repository for each
+// save that was edited or imported into, with its saves as they were before
+// each change.
+const editBackupsDir = "edit-backups"
+
+// runSavesEdit changes fields of one save slot and writes it back, encoded
+// the way the game expects. The saves are snapshotted first. Editing is
+// refused while the game runs or another launcher holds the lock, since
+// either would overwrite the edit.
+func runSavesEdit(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return apperr.New(apperr.ConfigInvalid, savesUsage)
+	}
+	if len(cfg.SaveSet) == 0 && cfg.SavePatch == "" {
+		return apperr.New(apperr.ConfigInvalid, "nothing to change: use --set field=value or --patch file.json")
+	}
+	var patch []byte
+	if cfg.SavePatch != "" {
+		var err error
+		if patch, err = os.ReadFile(cfg.SavePatch); err != nil {
+			return apperr.Wrap(apperr.ConfigInvalid, err)
+		}
+	}
+
+	release, err := lockSaves()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	stagingDir, err := os.MkdirTemp("", "hk-edit-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+	source, err := openSaveSource(ctx, cfg, args[0], filepath.Join(stagingDir, "saves"))
+	if err != nil {
+		return err
+	}
+	if err := refuseSnapshot(source, "edit"); err != nil {
+		return err
+	}
+	if source.file == "" && cfg.SaveSlot == 0 {
+		return apperr.New(apperr.ConfigInvalid, "--slot is required to edit a target")
+	}
+	path := source.slotFile(cfg.SaveSlot)
+
+	before, err := readSaveJSON(path)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return apperr.Errorf(apperr.SourceUnavailable, "'%s' has no save in slot %d", source.label, cfg.SaveSlot)
+	}
+	doc, err := saves.ParseDocument(before)
+	if err != nil {
+		return err
+	}
+	for _, set := range cfg.SaveSet {
+		field, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return apperr.Errorf(apperr.ConfigInvalid, "--set %q must be written as field=value", set)
+		}
+		if err := doc.Set(field, value); err != nil {
+			return apperr.Wrap(apperr.ConfigInvalid, err)
+		}
+	}
+	if patch != nil {
+		if err := doc.Merge(patch); err != nil {
+			return apperr.Wrap(apperr.ConfigInvalid, fmt.Errorf("%s: %w", cfg.SavePatch, err))
+		}
+	}
+	encoded, err := doc.Encode()
+	if err != nil {
+		return err
+	}
+	after, err := saves.DecodeJSON(encoded)
+	if err != nil {
+		return err
+	}
+	diffs, err := saves.DiffJSON(before, after)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		logger.Prompt("Nothing changed; the save was left as it was.")
+		return nil
+	}
+
+	backupTarget, err := snapshotBeforeChange(ctx, cfg, source)
+	if err != nil {
+		return fmt.Errorf("could not snapshot the saves before editing, nothing was changed: %w", err)
+	}
+	if err := writeFileAtomic(path, encoded); err != nil {
+		return err
+	}
+	if source.staged {
+		if err := backup.Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: source.dir}, source.target); err != nil {
+			return apperr.Wrap(apperr.SyncFailed, fmt.Errorf("could not write the edited save back to '%s': %w", source.target.Original, err))
+		}
+	}
+
+	for _, d := range diffs {
+		logger.Prompt("  %s: %s → %s", d.Path, formatValue(d.Before), formatValue(d.After))
+	}
+	logger.Prompt("Saved %s. The previous version is the newest snapshot of '%s'; undo with `%s`.", source.label, backupTarget.Original, undoCommand(args[0], source, cfg.SaveSlot))
+	return nil
+}
+
+// runSavesUndo puts one slot of a save back as it was before its last edit or
+// import. The saves it replaces are snapshotted like those of any other
+// change, so undoing again redoes the change.
+func runSavesUndo(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return apperr.New(apperr.ConfigInvalid, savesUsage)
+	}
+	release, err := lockSaves()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	stagingDir, err := os.MkdirTemp("", "hk-undo-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+	source, err := openSaveSource(ctx, cfg, args[0], filepath.Join(stagingDir, "saves"))
+	if err != nil {
+		return err
+	}
+	if err := refuseSnapshot(source, "undo"); err != nil {
+		return err
+	}
+	if source.file == "" && cfg.SaveSlot == 0 {
+		return apperr.New(apperr.ConfigInvalid, "--slot is required to undo a change to a target")
+	}
+	previousDir := filepath.Join(stagingDir, "previous")
+	snap, err := backup.RestoreSnapshot(ctx, cfg, editBackupTarget(cfg, source), "", previousDir)
+	if err != nil {
+		return apperr.Wrap(apperr.SourceUnavailable, fmt.Errorf("nothing to undo for %s: %w", source.label, err))
+	}
+
+	// The files of the slot as they were and as they are.
+	dir, what := source.dir, fmt.Sprintf("slot %d of %s", cfg.SaveSlot, source.label)
+	var previous, current []string
+	if source.file != "" {
+		dir, what = filepath.Dir(source.file), source.label
+		current = []string{filepath.Base(source.file)}
+		if util.PathExists(filepath.Join(previousDir, current[0])) {
+			previous = current
+		}
+	} else {
+		if previous, err = saves.SlotFiles(previousDir, cfg.SaveSlot); err != nil {
+			return err
+		}
+		if current, err = saves.SlotFiles(dir, cfg.SaveSlot); err != nil {
+			return err
+		}
+	}
+	if sameSlotFiles(previousDir, previous, dir, current) {
+		logger.Prompt("Nothing to undo: %s is as it was before its last change.", what)
+		return nil
+	}
+
+	if _, err := snapshotBeforeChange(ctx, cfg, source); err != nil {
+		return fmt.Errorf("could not snapshot the saves before undoing, nothing was changed: %w", err)
+	}
+	for _, name := range current {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	for _, name := range previous {
+		data, err := os.ReadFile(filepath.Join(previousDir, name))
+		if err != nil {
+			return err
+		}
+		// Written anew, so the undone save is also the newest by modification time.
+		if err := writeFileAtomic(filepath.Join(dir, name), data); err != nil {
+			return err
+		}
+	}
+	if source.staged {
+		if err := backup.Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: source.dir}, source.target); err != nil {
+			return apperr.Wrap(apperr.SyncFailed, fmt.Errorf("could not write the saves back to '%s': %w", source.target.Original, err))
+		}
+	}
+	logger.Prompt("Put %s back as it was on %s. Run the command again to redo the change.", what, formatModTime(snap.Time))
+	return nil
+}
+
+// undoCommand returns the command that undoes a change to source, given as
+// spec on the command line.
+func undoCommand(spec string, source saveSource, slot int) string {
+	if strings.ContainsAny(spec, " |&;<>()$`\\\"'*?") {
+		spec = strconv.Quote(spec)
+	}
+	if source.file != "" {
+		return "saves undo " + spec
+	}
+	return fmt.Sprintf("saves undo %s --slot=%d", spec, slot)
+}
+
+// sameSlotFiles reports whether the files a in dirA and b in dirB have the
+// same names and contents.
+func sameSlotFiles(dirA string, a []string, dirB string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, name := range a {
+		if !slices.Contains(b, name) {
+			return false
+		}
+		dataA, errA := os.ReadFile(filepath.Join(dirA, name))
+		dataB, errB := os.ReadFile(filepath.Join(dirB, name))
+		if errA != nil || errB != nil || !bytes.Equal(dataA, dataB) {
+			return false
+		}
+	}
+	return true
+}
+
+// refuseSnapshot rejects a snapshot as the saves a command changes. Writing
+// them back would make the old snapshot the newest one of its target, and the
+// next launch would start from it.
+func refuseSnapshot(source saveSource, verb string) error {
+	if source.snapshot == "" {
+		return nil
+	}
+	return apperr.Errorf(apperr.ConfigInvalid, "cannot %s snapshot %s of '%s'; restore it with `restore` first, or %s the target itself", verb, source.snapshot, source.target.Original, verb)
+}
+
+// lockSaves takes the instance lock for a command that writes saves, and
+// makes sure the game isn't running, since it would overwrite them.
+func lockSaves() (release func(), err error) {
+	lockFilePath, err := acquireLock()
+	if err != nil {
+		return nil, err
+	}
+	running, err := gameRunning()
+	if err == nil && running {
+		err = apperr.New(apperr.LockHeld, "Hollow Knight is running; close it before changing its saves")
+	} else if err != nil {
+		err = fmt.Errorf("could not check whether the game is running: %w", err)
+	}
+	if err != nil {
+		releaseLock(lockFilePath)
+		return nil, err
+	}
+	return func() { releaseLock(lockFilePath) }, nil
+}
+
+// editBackupTarget returns the snapshot repository that keeps the saves of
+// source as they were before each change.
+func editBackupTarget(cfg *config.Config, source saveSource) config.SyncTarget {
+	key := source.target.Original
+	if source.file != "" {
+		key = source.file
+		if abs, err := filepath.Abs(source.file); err == nil {
+			key = abs
+		}
+	}
+	sum := sha1.Sum([]byte(key))
+	name := hex.EncodeToString(sum[:])[:12]
+	return config.ParseTarget(filepath.Join(cfg.DataDir, editBackupsDir, name) + "|||format=snapshot")
+}
+
+// snapshotBeforeChange stores the saves about to be edited or imported over
+// as a new snapshot in the data directory and returns the snapshot target.
+func snapshotBeforeChange(ctx context.Context, cfg *config.Config, source saveSource) (config.SyncTarget, error) {
+	target := editBackupTarget(cfg, source)
+	dir := source.dir
+	if source.file != "" {
+		// A lone save file is snapshotted in a directory of its own.
+		tmp, err := os.MkdirTemp("", "hk-edit-file-*")
+		if err != nil {
+			return target, err
+		}
+		defer os.RemoveAll(tmp)
+		if err := util.CopyFile(source.file, filepath.Join(tmp, filepath.Base(source.file))); err != nil {
+			return target, err
+		}
+		dir = tmp
+	}
+	return target, backup.CreateSnapshot(ctx, cfg, dir, target)
+}
+
+// writeFileAtomic replaces path with data without leaving a half-written file
+// if it fails. The temporary file is named so that it never counts as a slot
+// file, should it be left behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".hk-edit-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// /internal/launcher/edit_test.go
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"testing"
+)
+
+func TestRefuseSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	saveDir := filepath.Join(dir, "saves")
+	if err := os.MkdirAll(saveDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(saveDir, "user1.dat"), []byte("save"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := config.ParseTarget(filepath.Join(dir, "snapshots") + "|||format=snapshot")
+	cfg := &config.Config{DataDir: filepath.Join(dir, "data"), SyncTargets: []config.SyncTarget{target}}
+	if err := backup.CreateSnapshot(ctx, cfg, saveDir, target); err != nil {
+		t.Fatal(err)
+	}
+	snaps, err := backup.ListSnapshots(ctx, cfg, target)
+	if err != nil || len(snaps) != 1 {
+		t.Fatalf("ListSnapshots = %v, %v", snaps, err)
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"target", "1", false},
+		{"save file", filepath.Join(saveDir, "user1.dat"), false},
+		{"snapshot", "1@" + snaps[0].ID, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := openSaveSource(ctx, cfg, tt.spec, filepath.Join(dir, "staging", tt.name))
+			if err != nil {
+				t.Fatalf("openSaveSource: %v", err)
+			}
+			err = refuseSnapshot(source, "edit")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("refuseSnapshot error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && apperr.KindOf(err) != apperr.ConfigInvalid {
+				t.Errorf("refuseSnapshot kind = %v, want ConfigInvalid", apperr.KindOf(err))
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "user1.dat")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new" {
+		t.Errorf("contents = %q, want %q", got, "new")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries after the write, want only user1.dat", len(entries))
+	}
+}
@@ -0,0 +1,98 @@
+// /internal/launcher/history.go
+package launcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/history"
+	"pirated-hollow-knight/internal/saves"
+)
+
+// slotState is a save slot as it was at one point of a session.
+type slotState struct {
+	sum  []byte
+	save *saves.Save
+}
+
+// captureSlots records the state of every slot in dir, so that the slots a
+// session changed can be found afterwards.
+func captureSlots(dir string) map[int]slotState {
+	states := make(map[int]slotState)
+	slots, err := saves.Slots(dir)
+	if err != nil {
+		logger.Warn("Could not list the session saves for the history: %v", err)
+		return states
+	}
+	for _, slot := range slots {
+		data, err := os.ReadFile(filepath.Join(dir, saves.SlotFileName(slot)))
+		if err != nil {
+			logger.Warn("Could not read slot %d for the history: %v", slot, err)
+			continue
+		}
+		sum := sha256.Sum256(data)
+		state := slotState{sum: sum[:]}
+		if state.save, err = saves.Decode(data); err != nil {
+			logger.Warn("Could not decode slot %d for the history: %v", slot, err)
+		}
+		states[slot] = state
+	}
+	return states
+}
+
+// changedSlots compares the slots at the start and the end of a session. A
+// slot that is new counts from zero; one that was deleted adds nothing.
+func changedSlots(before, after map[int]slotState) []history.SlotResult {
+	var results []history.SlotResult
+	seen := make(map[int]bool)
+	for _, states := range []map[int]slotState{before, after} {
+		for _, slot := range sortedKeys(states) {
+			if seen[slot] {
+				continue
+			}
+			seen[slot] = true
+			old, new := before[slot], after[slot]
+			if old.sum != nil && bytes.Equal(old.sum, new.sum) {
+				continue
+			}
+			result := history.SlotResult{Slot: slot, Changed: true}
+			if new.save != nil {
+				result.Changes = saves.Compare(old.save, new.save)
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// settleSyncs clears the errors of syncs whose target caught up through a
+// retry before the launcher exited. targets[i] is the target of results[i].
+func settleSyncs(retries *backup.RetryQueue, targets []config.SyncTarget, results []history.SyncResult) {
+	for i, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		if _, behind := retries.Lookup(targets[i]); !behind {
+			results[i].Error, results[i].Retried = "", true
+		}
+	}
+}
+
+// recordSession adds a session to the history. A failure is only reported,
+// since the saves themselves are already safe.
+func recordSession(cfg *config.Config, session *history.Session) {
+	store, err := history.Open(cfg.DataDir)
+	if err != nil {
+		logger.Warn("Could not record the session: %v", err)
+		return
+	}
+	defer store.Close()
+	if err := store.Add(session); err != nil {
+		logger.Warn("Could not record the session: %v", err)
+		return
+	}
+	logger.Info("Recorded session %d in the history.", session.ID)
+}
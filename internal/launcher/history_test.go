@@ -0,0 +1,77 @@
+// /internal/launcher/history_test.go
+package launcher
+
+import (
+	"crypto/sha256"
+	"pirated-hollow-knight/internal/history"
+	"pirated-hollow-knight/internal/saves"
+	"reflect"
+	"testing"
+)
+
+// slotFrom returns the state of a slot holding a save with the given playerData.
+func slotFrom(t *testing.T, playerData string) slotState {
+	t.Helper()
+	data, err := saves.EncodeJSON([]byte(`{"playerData":` + playerData + `}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	save, err := saves.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return slotState{sum: sum[:], save: save}
+}
+
+func TestChangedSlots(t *testing.T) {
+	start := `{"playTime": 600, "geo": 100, "completionPercentage": 5, "maxHealthBase": 5, "MPReserveMax": 0,
+		"gotCharm_1": true, "killedFalseKnight": true, "visitedDirtmouth": true, "visitedCrossroads": true}`
+	played := `{"playTime": 1500, "geo": 40, "completionPercentage": 9, "maxHealthBase": 6, "MPReserveMax": 33,
+		"gotCharm_1": true, "gotCharm_2": true, "killedFalseKnight": true, "killedBigFly": true,
+		"visitedDirtmouth": true, "visitedCrossroads": true, "visitedGreenpath": true}`
+	fresh := `{"playTime": 120, "geo": 30, "completionPercentage": 1, "maxHealthBase": 5, "visitedDirtmouth": true}`
+
+	tests := []struct {
+		name          string
+		before, after map[int]string
+		want          []history.SlotResult
+	}{
+		{"unchanged", map[int]string{1: start}, map[int]string{1: start}, nil},
+		{"played", map[int]string{1: start}, map[int]string{1: played}, []history.SlotResult{{Slot: 1, Changed: true, Changes: saves.Changes{
+			PlayTime: 900, Completion: 4, Geo: -60, Masks: 1, Vessels: 1,
+			Charms: []string{"Wayward Compass"},
+			Bosses: []string{"Gruz Mother"},
+			Areas:  []string{"Greenpath"},
+		}}}},
+		{"new", nil, map[int]string{2: fresh}, []history.SlotResult{{Slot: 2, Changed: true, Changes: saves.Changes{
+			PlayTime: 120, Completion: 1, Geo: 30, Masks: 5,
+			Areas: []string{"Dirtmouth"},
+		}}}},
+		{"deleted", map[int]string{3: start}, nil, []history.SlotResult{{Slot: 3, Changed: true}}},
+		{"mixed", map[int]string{1: start, 3: start, 4: fresh}, map[int]string{1: start, 2: fresh, 4: played}, []history.SlotResult{
+			{Slot: 3, Changed: true},
+			{Slot: 4, Changed: true, Changes: saves.Changes{
+				PlayTime: 1380, Completion: 8, Geo: 10, Masks: 1, Vessels: 1,
+				Charms: []string{"Gathering Swarm", "Wayward Compass"},
+				Bosses: []string{"False Knight", "Gruz Mother"},
+				Areas:  []string{"Forgotten Crossroads", "Greenpath"},
+			}},
+			{Slot: 2, Changed: true, Changes: saves.Changes{PlayTime: 120, Completion: 1, Geo: 30, Masks: 5, Areas: []string{"Dirtmouth"}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := make(map[int]slotState), make(map[int]slotState)
+			for slot, data := range tt.before {
+				before[slot] = slotFrom(t, data)
+			}
+			for slot, data := range tt.after {
+				after[slot] = slotFrom(t, data)
+			}
+			if got := changedSlots(before, after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changedSlots =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,29 +3,39 @@ package launcher
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
 	"pirated-hollow-knight/internal/backup"
 	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/history"
 	"pirated-hollow-knight/internal/log"
+	"pirated-hollow-knight/internal/notify"
+	"pirated-hollow-knight/internal/saves"
 	"pirated-hollow-knight/internal/util"
+	"slices"
 	"strconv"
 	"syscall"
 	"time"
 )
 
+// logger tags the messages of this package with its subsystem.
+var logger = log.For("launcher")
+
 // LaunchGame is the main entry point for the new "Transactional Swap" launcher logic.
 func LaunchGame(ctx context.Context, cfg *config.Config) error {
 	hollowKnightExe := filepath.Join(cfg.HollowKnightInstallPath, "Hollow Knight.exe")
 	if !util.PathExists(hollowKnightExe) {
-		return fmt.Errorf("executable not found at %s", hollowKnightExe)
+		return apperr.Errorf(apperr.DependencyMissing, "executable not found at %s", hollowKnightExe)
 	}
 
 	// If no targets are specified, just launch the game normally.
 	if len(cfg.SyncTargets) == 0 {
+		if cfg.PracticeName != "" || cfg.Sandbox {
+			return launchUntracked(ctx, cfg, hollowKnightExe)
+		}
 		return launchFireAndForget(cfg, hollowKnightExe)
 	}
 
@@ -46,49 +56,306 @@ func LaunchGame(ctx context.Context, cfg *config.Config) error {
 	}
 	// Defer the restoration of the real saves to ensure it always runs.
 	defer restoreRealSaves(backupPath, realSavePath)
+	if backupPath == "" && cfg.Sandbox {
+		// There were no saves to put back, so the sandbox's are removed instead.
+		defer os.RemoveAll(realSavePath)
+	}
+
+	// All rclone work of the session goes through a single rclone instance.
+	if slices.ContainsFunc(cfg.SyncTargets, func(t config.SyncTarget) bool { return t.Type == config.Remote }) {
+		stopRclone, err := backup.StartRcloneDaemon(ctx, cfg)
+		if err != nil {
+			logger.Warn("Could not start rclone remote control, starting rclone for each operation instead: %v", err)
+		} else {
+			defer stopRclone()
+		}
+	}
+
+	// All syncs from here on go through the queue, so that background backups and
+	// the swap-out never write to the same destination at once.
+	retries, err := backup.OpenRetryQueue(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	queue := backup.NewSyncQueue(ctx, cfg, retries)
+	defer queue.Close()
 
 	// 3. Identify Latest Source
-	latestSourceTarget, err := findLatestSource(ctx, cfg)
+	// Offline progress from earlier sessions is uploaded first, so that it takes
+	// part in the comparison like any other save. Failed backups are not pushed
+	// blindly: their copies take part as candidates, unless the target has moved
+	// on since. Sandbox sessions leave every target as it is, so those wait for
+	// the next regular session.
+	if !cfg.Sandbox {
+		backup.ReconcileMirrors(ctx, cfg)
+		retries.SettleStale(ctx, cfg)
+	}
+	stagingDir, err := os.MkdirTemp("", "hk-sources-*")
 	if err != nil {
-		return fmt.Errorf("could not determine latest save source: %w", err)
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+	plan, err := planSession(ctx, cfg, retries, stagingDir)
+	if err != nil {
+		return apperr.Wrap(apperr.SourceUnavailable, fmt.Errorf("could not determine latest save source: %w", err))
+	}
+	logger.Info("Latest save source identified: '%s'", plan.base.target.Original)
+	for _, source := range plan.sources() {
+		if !source.provisional || cfg.Sandbox {
+			continue
+		}
+		logger.Warn("⚠️ Playing from the offline cache of '%s'. This session is provisional and will be uploaded once the remote is reachable.", source.origin.Original)
+		if err := backup.MarkProvisional(cfg, source.origin); err != nil {
+			return err
+		}
 	}
-	log.Log.Info("Latest save source identified: '%s'", latestSourceTarget.Original)
 
-	// 4. Swap In (Populate the real save directory)
+	// 4. Swap In (Populate the real save directory, slot by slot)
 	realSaveTarget := config.SyncTarget{Type: config.Local, Path: realSavePath}
-	if err := backup.Sync(ctx, cfg, latestSourceTarget, realSaveTarget); err != nil {
-		return fmt.Errorf("failed to swap in saves from '%s': %w", latestSourceTarget.Original, err)
+	if err := assembleSession(ctx, cfg, plan, realSaveTarget); err != nil {
+		return apperr.Wrap(apperr.SourceUnavailable, err)
+	}
+	logger.Info("Successfully populated real save directory from latest source.")
+	startSlots := captureSlots(realSavePath)
+	unloadPractice := func() {}
+	if cfg.PracticeName != "" {
+		if unloadPractice, err = loadPractice(cfg, realSavePath); err != nil {
+			return err
+		}
+		defer unloadPractice()
+	}
+	if cfg.Sandbox {
+		announceSandbox()
 	}
-	log.Log.Info("Successfully populated real save directory from latest source.")
 
 	// 5. Launch Game
 	cmd := exec.CommandContext(ctx, hollowKnightExe)
 	cmd.Dir = cfg.HollowKnightInstallPath
+	start := time.Now()
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to launch Hollow Knight: %w", err)
+		return apperr.Errorf(apperr.GameCrashed, "failed to launch Hollow Knight: %w", err)
 	}
-	log.Log.Info("🚀 Game launched. Process ID: %d. Waiting for exit...", cmd.Process.Pid)
+	logger.Info("🚀 Game launched. Process ID: %d. Waiting for exit...", cmd.Process.Pid)
 
 	// 6. Start Background Sync (if applicable)
-	go backup.StartBackgroundSync(ctx, cfg, realSavePath)
+	backgroundCtx, stopBackground := context.WithCancel(ctx)
+	waitBackground := func() {}
+	switch {
+	case cfg.Sandbox:
+		logger.Info("Background backups are off for sandbox sessions.")
+	case cfg.PracticeName != "":
+		logger.Info("Background backups are off for practice sessions, so the practice save never reaches a target.")
+	default:
+		waitBackground = backup.StartBackgroundSync(backgroundCtx, cfg, queue, realSavePath)
+	}
+	waitRetries := func() {}
+	if !cfg.Sandbox {
+		waitRetries = retries.Start(backgroundCtx, queue)
+	}
+	defer func() {
+		stopBackground()
+		waitBackground()
+		waitRetries()
+	}()
 
 	// The context passed to exec.CommandContext will automatically handle process termination on interrupt.
 
 	// 7. Wait for Exit
 	waitErr := cmd.Wait()
-	log.Log.Info("✅ Game process has terminated. Exit code: %v", waitErr)
+	end := time.Now()
+	logger.Info("✅ Game process has terminated. Exit code: %v", waitErr)
+	// The practice slot is put back first, so that only real progress is
+	// reported and swapped out.
+	unloadPractice()
+	session := &history.Session{
+		Profile:  cfg.Profile,
+		Practice: cfg.PracticeName,
+		Sandbox:  cfg.Sandbox,
+		Start:    start,
+		End:      end,
+		Elapsed:  end.Sub(start),
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Source:   plan.base.origin.Original,
+		Slots:    changedSlots(startSlots, captureSlots(realSavePath)),
+	}
+	printReport(session)
+
+	// Stop triggering new backups and retries and let in-flight ones finish before
+	// swapping out.
+	stopBackground()
+	waitBackground()
+	waitRetries()
+	queue.Drain()
+
+	// 8. Swap Out, unless the session was a sandbox.
+	var swapOutErr, keepErr error
+	var syncTargets []config.SyncTarget
+	if cfg.Sandbox {
+		logger.Prompt("🧪 Sandbox session over: its changes were discarded.")
+		keepErr = keepSandbox(ctx, cfg, realSavePath)
+	} else {
+		syncTargets, swapOutErr = swapOut(cfg, queue, plan, realSaveTarget, session)
+	}
+
+	// 9. Give targets that are still behind a last chance before exiting.
+	if !cfg.Sandbox {
+		retries.RetryPending(ctx, queue, cfg.RetryTimeout)
+	}
+	settleSyncs(retries, syncTargets, session.Syncs)
+	recordSession(cfg, session)
+	notify.Send(context.WithoutCancel(ctx), cfg.NotifySinks, session)
+	for _, source := range plan.sources() {
+		if _, behind := retries.Lookup(source.target); behind && swapOutErr != nil {
+			return swapOutErr
+		}
+	}
+	if keepErr != nil {
+		return keepErr
+	}
 
-	// 7. Swap Out (Copy saves back to their origin)
-	log.Log.Info("Copying session saves back to '%s'...", latestSourceTarget.Original)
-	if err := backup.Sync(ctx, cfg, realSaveTarget, latestSourceTarget); err != nil {
-		return fmt.Errorf("failed to swap out saves to '%s': %w", latestSourceTarget.Original, err)
+	// The saves are safe by now, but how the game ended is still reported. An
+	// interrupt kills the game too, which is not the game's fault.
+	switch {
+	case ctx.Err() != nil && cfg.Sandbox:
+		return apperr.Errorf(apperr.Interrupted, "interrupted, the sandbox saves were discarded: %w", ctx.Err())
+	case ctx.Err() != nil:
+		return apperr.Errorf(apperr.Interrupted, "interrupted, the session saves were synced: %w", ctx.Err())
+	case waitErr != nil:
+		return apperr.Errorf(apperr.GameCrashed, "Hollow Knight exited with an error: %w", waitErr)
 	}
-	log.Log.Info("✅ Save data successfully synced back.")
 
-	// 8 & 9 (Restore and Release Lock) are handled by the deferred calls.
+	// Restore and Release Lock are handled by the deferred calls.
 	return nil
 }
 
+// launchUntracked runs a practice or sandbox session without save targets.
+// Unlike a normal launch without targets it waits for the game, to put the
+// saves back when it exits.
+func launchUntracked(ctx context.Context, cfg *config.Config, exePath string) error {
+	lockFilePath, err := acquireLock()
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lockFilePath)
+
+	if cfg.Sandbox {
+		// The game plays a copy of the real saves, which are put back afterwards.
+		backupPath, err := backupRealSaves(cfg.UserSavePath)
+		if err != nil {
+			return fmt.Errorf("failed to backup real saves: %w", err)
+		}
+		defer restoreRealSaves(backupPath, cfg.UserSavePath)
+		if backupPath == "" {
+			defer os.RemoveAll(cfg.UserSavePath)
+		} else if err := util.CopyDir(backupPath, cfg.UserSavePath); err != nil {
+			return err
+		}
+	}
+	unloadPractice := func() {}
+	if cfg.PracticeName != "" {
+		if unloadPractice, err = loadPractice(cfg, cfg.UserSavePath); err != nil {
+			return err
+		}
+		defer unloadPractice()
+	}
+	if cfg.Sandbox {
+		announceSandbox()
+	}
+
+	cmd := exec.CommandContext(ctx, exePath)
+	cmd.Dir = cfg.HollowKnightInstallPath
+	if err := cmd.Start(); err != nil {
+		return apperr.Errorf(apperr.GameCrashed, "failed to launch Hollow Knight: %w", err)
+	}
+	logger.Info("🚀 Game launched. Process ID: %d. Waiting for exit...", cmd.Process.Pid)
+	waitErr := cmd.Wait()
+	logger.Info("✅ Game process has terminated. Exit code: %v", waitErr)
+	unloadPractice()
+	if cfg.Sandbox {
+		logger.Prompt("🧪 Sandbox session over: its changes were discarded.")
+		if err := keepSandbox(ctx, cfg, cfg.UserSavePath); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return apperr.Errorf(apperr.Interrupted, "interrupted, the saves were put back: %w", ctx.Err())
+	case waitErr != nil:
+		return apperr.Errorf(apperr.GameCrashed, "Hollow Knight exited with an error: %w", waitErr)
+	}
+	return nil
+}
+
+// swapOut copies the session saves back to every target a slot came from,
+// adding the results to session. It returns every target synced to and the
+// first swap-out failure.
+func swapOut(cfg *config.Config, queue *backup.SyncQueue, plan sessionPlan, realSaveTarget config.SyncTarget, session *history.Session) ([]config.SyncTarget, error) {
+	warnUncoveredSlots(cfg, realSaveTarget.Path)
+
+	// 8. Swap Out (Copy saves back to every target a slot came from)
+	var swapOutErr error
+	var syncTargets []config.SyncTarget
+	for _, source := range plan.sources() {
+		logger.Info("Copying session saves back to '%s'...", source.target.Original)
+		err := queue.SyncVerified(realSaveTarget, source.target)
+		syncTargets = append(syncTargets, source.target)
+		session.Syncs = append(session.Syncs, syncResult(source.origin, "swap-out", err))
+		if err != nil {
+			logger.Error("Failed to swap out saves to '%s': %v. A copy has been kept for retrying.", source.target.Original, err)
+			if swapOutErr == nil {
+				swapOutErr = apperr.Wrap(apperr.SyncFailed, fmt.Errorf("failed to swap out saves to '%s': %w", source.target.Original, err))
+			}
+			continue
+		}
+		logger.Info("✅ Save data successfully synced back.")
+
+		// A provisional or retry copy source also tries to reach the target it
+		// stands in for. If that fails, the retry queue keeps trying until it is back.
+		switch {
+		case source.provisional:
+			logger.Info("Uploading offline progress to '%s'...", source.origin.Original)
+			if err := queue.SyncVerified(source.target, source.origin); err != nil {
+				logger.Warn("'%s' is still unreachable. Offline progress is kept in the local cache: %v", source.origin.Original, err)
+			}
+		case source.behind:
+			logger.Info("Copying session saves on to '%s', which is behind...", source.origin.Original)
+			if err := queue.SyncVerified(source.target, source.origin); err != nil {
+				logger.Warn("'%s' is still behind. The session saves are kept for retrying: %v", source.origin.Original, err)
+			}
+		}
+	}
+
+	return syncTargets, swapOutErr
+}
+
+// warnUncoveredSlots warns about session slots that no target stores because
+// every target has a slot map and none covers them, e.g. a new game started
+// in an unused slot. Their saves are lost when the real saves are put back.
+func warnUncoveredSlots(cfg *config.Config, saveDir string) {
+	slots, err := saves.Slots(saveDir)
+	if err != nil {
+		return
+	}
+	for _, slot := range slots {
+		covered := slices.ContainsFunc(cfg.SyncTargets, func(t config.SyncTarget) bool {
+			_, mapped := t.Slots[slot]
+			return t.Slots == nil || mapped
+		})
+		if !covered {
+			logger.Prompt("⚠️ Slot %d is not synced to any target: no target's slot map covers it, so its saves from this session will be lost. Add it to a target's 'slots=' option to keep it.", slot)
+		}
+	}
+}
+
+func syncResult(target config.SyncTarget, kind string, err error) history.SyncResult {
+	result := history.SyncResult{Target: target.Original, Kind: kind}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
 func acquireLock() (string, error) {
 	exePath, err := os.Executable()
 	if err != nil {
@@ -99,21 +366,21 @@ func acquireLock() (string, error) {
 	if util.PathExists(lockFilePath) {
 		pidBytes, err := os.ReadFile(lockFilePath)
 		if err != nil {
-			log.Log.Warn("Could not read existing lock file, assuming stale: %v", err)
+			logger.Warn("Could not read existing lock file, assuming stale: %v", err)
 		} else {
 			pid, err := strconv.Atoi(string(pidBytes))
 			if err != nil {
-				log.Log.Warn("Could not parse PID from lock file, assuming stale: %v", err)
+				logger.Warn("Could not parse PID from lock file, assuming stale: %v", err)
 			} else {
 				process, err := os.FindProcess(pid)
 				if err == nil {
 					// On Windows, syscall.Signal(0) is a no-op that can be used to check for process existence.
 					err = process.Signal(syscall.Signal(0))
 					if err == nil {
-						return "", fmt.Errorf("lock file found and process with PID %d is still running. Another instance appears to be active", pid)
+						return "", apperr.Errorf(apperr.LockHeld, "lock file found and process with PID %d is still running. Another instance appears to be active", pid)
 					}
 				}
-				log.Log.Warn("Found stale lock file for non-existent process PID %d. Removing it.", pid)
+				logger.Warn("Found stale lock file for non-existent process PID %d. Removing it.", pid)
 			}
 		}
 
@@ -127,21 +394,21 @@ func acquireLock() (string, error) {
 	if err := os.WriteFile(lockFilePath, []byte(strconv.Itoa(pid)), 0644); err != nil {
 		return "", fmt.Errorf("could not create lock file: %w", err)
 	}
-	log.Log.Info("Acquired instance lock for PID %d.", pid)
+	logger.Info("Acquired instance lock for PID %d.", pid)
 	return lockFilePath, nil
 }
 
 func releaseLock(lockFilePath string) {
 	if err := os.Remove(lockFilePath); err != nil {
-		log.Log.Warn("Failed to remove lock file '%s': %v", lockFilePath, err)
+		logger.Warn("Failed to remove lock file '%s': %v", lockFilePath, err)
 	} else {
-		log.Log.Info("Released instance lock.")
+		logger.Info("Released instance lock.")
 	}
 }
 
 func backupRealSaves(realSavePath string) (string, error) {
 	if !util.PathExists(realSavePath) {
-		log.Log.Info("Real save directory does not exist, no backup needed.")
+		logger.Info("Real save directory does not exist, no backup needed.")
 		return "", nil // Nothing to back up
 	}
 
@@ -150,7 +417,7 @@ func backupRealSaves(realSavePath string) (string, error) {
 		return "", err
 	}
 
-	log.Log.Info("Backing up current saves from '%s' to '%s'", realSavePath, backupPath)
+	logger.Info("Backing up current saves from '%s' to '%s'", realSavePath, backupPath)
 	if err := util.CopyDir(realSavePath, backupPath); err != nil {
 		return "", err
 	}
@@ -164,80 +431,92 @@ func restoreRealSaves(backupPath, realSavePath string) {
 	if backupPath == "" {
 		return // Nothing was backed up.
 	}
-	log.Log.Info("Restoring original saves to '%s'", realSavePath)
+	logger.Info("Restoring original saves to '%s'", realSavePath)
 	// Clean the directory first in case the game created new files.
 	_ = os.RemoveAll(realSavePath)
 	if err := util.CopyDir(backupPath, realSavePath); err != nil {
-		log.Log.Error("CRITICAL: Failed to restore original saves: %v", err)
+		logger.Error("CRITICAL: Failed to restore original saves: %v", err)
 	}
 	_ = os.RemoveAll(backupPath) // Clean up the backup dir.
 }
 
-func findLatestSource(ctx context.Context, cfg *config.Config) (config.SyncTarget, error) {
-	var latestSourceTarget config.SyncTarget
-	var latestModTime time.Time
-	foundAny := false
-
-	for _, target := range cfg.SyncTargets {
-		var currentModTime time.Time
-		var err error
-		if target.Type == config.Local {
-			currentModTime, err = util.GetDirLastModTime(target.Path)
-		} else {
-			currentModTime, err = backup.GetCloudDirLastModTime(ctx, cfg, target)
-		}
+// backupSlot moves the files of one slot out of the save directory, like
+// backupRealSaves does for the whole directory.
+func backupSlot(saveDir string, slot int) (string, error) {
+	names, err := saves.SlotFiles(saveDir, slot)
+	if err != nil {
+		return "", err
+	}
+	backupPath, err := os.MkdirTemp("", "hk-slot-backup-*")
+	if err != nil {
+		return "", err
+	}
 
-		if err != nil {
-			log.Log.Warn("Could not get mod time for target '%s': %v", target.Original, err)
-			continue
+	logger.Info("Backing up slot %d from '%s' to '%s'", slot, saveDir, backupPath)
+	for _, name := range names {
+		if err := util.CopyFile(filepath.Join(saveDir, name), filepath.Join(backupPath, name)); err != nil {
+			return "", err
 		}
-
-		if !foundAny || currentModTime.After(latestModTime) {
-			latestModTime = currentModTime
-			latestSourceTarget = target
-			foundAny = true
+	}
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(saveDir, name)); err != nil {
+			return "", err
 		}
 	}
+	return backupPath, nil
+}
 
-	if !foundAny {
-		return config.SyncTarget{}, errors.New("could not find any valid/accessible save targets")
+// restoreSlot puts the files set aside by backupSlot back, replacing whatever
+// the game wrote to the slot in the meantime.
+func restoreSlot(backupPath, saveDir string, slot int) {
+	logger.Info("Restoring slot %d to '%s'", slot, saveDir)
+	names, err := saves.SlotFiles(saveDir, slot)
+	if err != nil {
+		logger.Error("CRITICAL: Failed to restore slot %d: %v", slot, err)
+		return
 	}
-
-	return latestSourceTarget, nil
+	for _, name := range names {
+		_ = os.Remove(filepath.Join(saveDir, name))
+	}
+	if err := util.CopyDir(backupPath, saveDir); err != nil {
+		logger.Error("CRITICAL: Failed to restore slot %d: %v", slot, err)
+		return
+	}
+	_ = os.RemoveAll(backupPath)
 }
 
-// --- Unchanged Functions ---
-
 func launchFireAndForget(cfg *config.Config, exePath string) error {
-	log.Log.Info("No save targets specified. Launching game and detaching.")
+	logger.Info("No save targets specified. Launching game and detaching.")
 	cmd := exec.Command(exePath)
 	cmd.Dir = cfg.HollowKnightInstallPath
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to launch Hollow Knight: %w", err)
+		return apperr.Errorf(apperr.GameCrashed, "failed to launch Hollow Knight: %w", err)
 	}
-	log.Log.Info("✅ Game launched successfully. This program will now exit.")
+	logger.Info("✅ Game launched successfully. This program will now exit.")
 	return nil
 }
 
 func RunClean(cfg *config.Config) error {
-	log.Log.Info("--- Running Clean Mode ---")
+	logger.Info("--- Running Clean Mode ---")
 	if util.PathExists(cfg.HollowKnightInstallPath) {
-		log.Log.Info("Removing Hollow Knight installation from: %s", cfg.HollowKnightInstallPath)
+		logger.Info("Removing Hollow Knight installation from: %s", cfg.HollowKnightInstallPath)
 		if err := os.RemoveAll(cfg.HollowKnightInstallPath); err != nil {
 			return err
 		}
-		log.Log.Info("✅ Hollow Knight directory removed.")
+		logger.Info("✅ Hollow Knight directory removed.")
+	}
+	localRclonePath, err := backup.LocalRclonePath()
+	if err != nil {
+		return err
 	}
-	exePath, _ := os.Executable()
-	localRclonePath := filepath.Join(filepath.Dir(exePath), "rclone.exe")
 	if util.PathExists(localRclonePath) {
-		log.Log.Info("Removing downloaded rclone.exe from: %s", localRclonePath)
+		logger.Info("Removing downloaded rclone from: %s", localRclonePath)
 		if err := os.Remove(localRclonePath); err != nil {
 			return err
 		}
-		log.Log.Info("✅ rclone.exe removed.")
+		logger.Info("✅ rclone removed.")
 	}
-	log.Log.Warn("Note: 'rclone.conf' is not removed to preserve your configuration.")
-	log.Log.Info("--- Clean-up complete ---")
+	logger.Warn("Note: 'rclone.conf' is not removed to preserve your configuration.")
+	logger.Info("--- Clean-up complete ---")
 	return nil
 }
@@ -0,0 +1,137 @@
+// /internal/launcher/practice.go
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"pirated-hollow-knight/internal/util"
+	"sort"
+	"strings"
+)
+
+// practiceSaveExt is the extension of a plain save file in the practice library.
+const practiceSaveExt = ".dat"
+
+// RunPractice lists the saves in the practice library.
+func RunPractice(cfg *config.Config) error {
+	if len(cfg.Args) > 0 {
+		return apperr.New(apperr.ConfigInvalid, "usage: practice")
+	}
+	names, err := practiceNames(cfg.PracticeDir)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		logger.Prompt("The practice library '%s' is empty. Add saves to it as <name>.dat or <name>%s files.", cfg.PracticeDir, saves.BundleExt)
+		return nil
+	}
+	logger.Prompt("Practice saves in '%s':", cfg.PracticeDir)
+	for _, name := range names {
+		files, err := readPractice(cfg, name, 1)
+		if err != nil {
+			logger.Prompt("  %-24s unreadable: %v", name, err)
+			continue
+		}
+		save, err := saves.Decode(files[saves.SlotFileName(1)])
+		if err != nil {
+			logger.Prompt("  %-24s does not decode: %v", name, err)
+			continue
+		}
+		s := saves.Summarise(save)
+		logger.Prompt("  %-24s %s played, %.1f%% complete, %d geo, %d charm(s)", name, formatPlayTime(s.PlayTime), s.Completion, s.Geo, s.Charms)
+	}
+	return nil
+}
+
+// practiceNames returns the names of the saves in the practice library.
+func practiceNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if !e.IsDir() && (ext == practiceSaveExt || ext == saves.BundleExt) {
+			names = append(names, strings.TrimSuffix(e.Name(), ext))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readPractice reads a practice save, either a bundle or a plain save file,
+// and returns its files named for slot.
+func readPractice(cfg *config.Config, name string, slot int) (map[string][]byte, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name != filepath.Base(name) {
+		return nil, apperr.Errorf(apperr.ConfigInvalid, "invalid practice save name %q", name)
+	}
+	base := filepath.Join(cfg.PracticeDir, name)
+	files := make(map[string][]byte)
+	if util.PathExists(base + saves.BundleExt) {
+		bundle, err := saves.ReadBundle(base + saves.BundleExt)
+		if err != nil {
+			return nil, apperr.Wrap(apperr.VerifyFailed, fmt.Errorf("practice save '%s': %w", name, err))
+		}
+		for file, data := range bundle.Files {
+			files[saves.RenumberFile(file, bundle.Manifest.Slot, slot)] = data
+		}
+		return files, nil
+	}
+	data, err := os.ReadFile(base + practiceSaveExt)
+	if os.IsNotExist(err) {
+		names, _ := practiceNames(cfg.PracticeDir)
+		if len(names) == 0 {
+			return nil, apperr.Errorf(apperr.SourceUnavailable, "no practice save named '%s': the library '%s' is empty", name, cfg.PracticeDir)
+		}
+		return nil, apperr.Errorf(apperr.SourceUnavailable, "no practice save named '%s' in '%s'; available: %s", name, cfg.PracticeDir, strings.Join(names, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := saves.Decode(data); err != nil {
+		return nil, apperr.Wrap(apperr.VerifyFailed, fmt.Errorf("practice save '%s' does not decode: %w", name, err))
+	}
+	files[saves.SlotFileName(slot)] = data
+	return files, nil
+}
+
+// loadPractice puts the configured practice save into its slot of the save
+// directory. The slot's own files are set aside first and put back by the
+// returned function, which is safe to call more than once.
+func loadPractice(cfg *config.Config, saveDir string) (restore func(), err error) {
+	files, err := readPractice(cfg, cfg.PracticeName, cfg.PracticeSlot)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(saveDir, 0755); err != nil {
+		return nil, err
+	}
+	backupPath, err := backupSlot(saveDir, cfg.PracticeSlot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up slot %d: %w", cfg.PracticeSlot, err)
+	}
+	restored := false
+	restore = func() {
+		if !restored {
+			restored = true
+			restoreSlot(backupPath, saveDir, cfg.PracticeSlot)
+			logger.Prompt("Practice save '%s' unloaded; slot %d is back as it was.", cfg.PracticeName, cfg.PracticeSlot)
+		}
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(saveDir, name), data, 0644); err != nil {
+			restore()
+			return nil, err
+		}
+	}
+	logger.Prompt("Loaded practice save '%s' into slot %d. The slot's own saves will be put back when the game exits.", cfg.PracticeName, cfg.PracticeSlot)
+	return restore, nil
+}
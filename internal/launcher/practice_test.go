@@ -0,0 +1,84 @@
+// /internal/launcher/practice_test.go
+package launcher
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// dirContents returns every file in dir by name.
+func dirContents(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = string(data)
+	}
+	return files
+}
+
+func TestLoadPractice(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{PracticeDir: filepath.Join(dir, "practice"), PracticeName: "radiance", PracticeSlot: 1}
+	writeSlots(t, cfg.PracticeDir, map[int]testSlot{1: {playTime: 9999}})
+	if err := os.Rename(filepath.Join(cfg.PracticeDir, saves.SlotFileName(1)), filepath.Join(cfg.PracticeDir, "radiance"+practiceSaveExt)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		slots map[int]testSlot
+		extra map[string]string // other files of the slot, e.g. the game's backups
+	}{
+		{"occupied slot", map[int]testSlot{1: {playTime: 100}, 2: {playTime: 200}}, map[string]string{"user1.dat.bak1": "backup"}},
+		{"empty slot", map[int]testSlot{2: {playTime: 200}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saveDir := filepath.Join(t.TempDir(), "saves")
+			writeSlots(t, saveDir, tt.slots)
+			for name, data := range tt.extra {
+				if err := os.WriteFile(filepath.Join(saveDir, name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := dirContents(t, saveDir)
+
+			unload, err := loadPractice(cfg, saveDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slotPlayTimes(t, saveDir)[1]; got != 9999 {
+				t.Errorf("slot 1 plays %gs after loading, want the practice save's 9999s", got)
+			}
+			// The game saves the practice run, and keeps a backup of it.
+			for _, name := range []string{saves.SlotFileName(1), "user1.dat.bak2"} {
+				if err := os.WriteFile(filepath.Join(saveDir, name), []byte("practice progress"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			unload()
+			if got := dirContents(t, saveDir); !reflect.DeepEqual(got, want) {
+				t.Errorf("after unloading, the saves are %v, want them as before %v", slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+			}
+			unload()
+			if got := dirContents(t, saveDir); !reflect.DeepEqual(got, want) {
+				t.Errorf("after unloading twice, the saves are %v, want them as before %v", slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// /internal/launcher/process.go
+package launcher
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// gameProcessName is the image name of the game's process on Windows.
+const gameProcessName = "Hollow Knight.exe"
+
+// gameRunning reports whether a Hollow Knight process is running, whether or
+// not the launcher started it.
+func gameRunning() (bool, error) {
+	if runtime.GOOS == "windows" {
+		out, err := exec.Command("tasklist", "/FI", "IMAGENAME eq "+gameProcessName, "/NH").Output()
+		if err != nil {
+			return false, err
+		}
+		return strings.Contains(string(out), gameProcessName), nil
+	}
+	// Elsewhere the game runs under Wine or Proton, which keep the Windows
+	// executable as one of the arguments of its process.
+	cmdlines, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil {
+		return false, err
+	}
+	for _, path := range cmdlines {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // The process has exited.
+		}
+		for _, arg := range strings.Split(string(data), "\x00") {
+			if isGameExecutable(arg) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// isGameExecutable reports whether a command line argument is the game's
+// executable, given as a Unix or Windows path.
+func isGameExecutable(arg string) bool {
+	return arg == gameProcessName || strings.HasSuffix(arg, "/"+gameProcessName) || strings.HasSuffix(arg, `\`+gameProcessName)
+}
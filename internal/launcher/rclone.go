@@ -0,0 +1,41 @@
+// /internal/launcher/rclone.go
+package launcher
+
+import (
+	"context"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/installer"
+)
+
+// RunRclone manages the launcher's own copy of rclone.
+func RunRclone(ctx context.Context, cfg *config.Config) error {
+	const usage = "usage: rclone update|version"
+	if len(cfg.Args) != 1 {
+		return apperr.New(apperr.ConfigInvalid, usage)
+	}
+	switch cfg.Args[0] {
+	case "update":
+		version, err := installer.UpdateRclone(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		logger.Prompt("rclone %s is installed.", version)
+		return nil
+	case "version":
+		info, err := installer.GetRcloneInfo(ctx, cfg)
+		if info.Path == "" {
+			logger.Prompt("installed: none")
+		} else {
+			logger.Prompt("installed: %s (%s)", info.Installed, info.Path)
+		}
+		if info.Pinned != "" {
+			logger.Prompt("pinned:    %s", info.Pinned)
+		} else if info.Latest != "" {
+			logger.Prompt("latest:    %s", info.Latest)
+		}
+		return err
+	default:
+		return apperr.New(apperr.ConfigInvalid, usage)
+	}
+}
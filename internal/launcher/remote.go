@@ -0,0 +1,60 @@
+// /internal/launcher/remote.go
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+)
+
+// RunRemote manages the remotes in the launcher's rclone.conf.
+func RunRemote(ctx context.Context, cfg *config.Config) error {
+	const usage = "usage: remote add <name> <type> [key=value...] [--service-account-file=path] [--token=json]"
+	if len(cfg.Args) < 3 || cfg.Args[0] != "add" {
+		return apperr.New(apperr.ConfigInvalid, usage)
+	}
+	name, backend := cfg.Args[1], cfg.Args[2]
+	options, err := backup.ParseRemoteOptions(cfg.Args[3:])
+	if err != nil {
+		return apperr.Wrap(apperr.ConfigInvalid, err)
+	}
+	if cfg.RemoteServiceAccountFile != "" {
+		path, err := filepath.Abs(cfg.RemoteServiceAccountFile)
+		if err != nil {
+			return err
+		}
+		options["service_account_file"] = path
+	}
+	if cfg.RemoteToken != "" {
+		if !json.Valid([]byte(cfg.RemoteToken)) {
+			return apperr.Errorf(apperr.ConfigInvalid, "--token must be the JSON token printed by 'rclone authorize'")
+		}
+		options["token"] = cfg.RemoteToken
+	}
+
+	if err := backup.CreateRemote(ctx, cfg, name, backend, options); err != nil {
+		return err
+	}
+	logger.Prompt("Remote '%s' saved to '%s'.", name, cfg.RcloneConfigPath)
+	// The remote is checked with the options of every target that uses it.
+	var users []config.SyncTarget
+	for _, t := range cfg.SyncTargets {
+		if t.Type == config.Remote && t.RemoteName == name {
+			users = append(users, t)
+		}
+	}
+	if len(users) == 0 {
+		users = []config.SyncTarget{{Type: config.Remote, RemoteName: name}}
+	}
+	for _, t := range backup.RemoteChecks(users) {
+		if err := backup.CheckRemote(ctx, cfg, name, t.Rclone); err != nil {
+			return fmt.Errorf("the remote was saved, but %w", err)
+		}
+	}
+	logger.Prompt("Test listing of '%s:' succeeded.", name)
+	return nil
+}
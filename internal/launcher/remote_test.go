@@ -0,0 +1,86 @@
+// /internal/launcher/remote_test.go
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/config"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunRemoteInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		token string
+	}{
+		{"no subcommand", nil, ""},
+		{"unknown subcommand", []string{"remove", "gdrive", "drive"}, ""},
+		{"no type", []string{"add", "gdrive"}, ""},
+		{"malformed option", []string{"add", "gdrive", "drive", "scope"}, ""},
+		{"token is not JSON", []string{"add", "gdrive", "drive"}, "ya29.token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Args: tt.args, RemoteToken: tt.token}
+			if err := RunRemote(context.Background(), cfg); apperr.KindOf(err) != apperr.ConfigInvalid {
+				t.Errorf("RunRemote error = %v, want a ConfigInvalid error", err)
+			}
+		})
+	}
+}
+
+func TestRunRemoteAdd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake rclone is a shell script")
+	}
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	fake := `#!/bin/sh
+echo "$@" >> '` + calls + `'
+[ "$1" = "--config" ] && shift 2
+case "$1" in
+config) echo '{"State":"","Option":null,"Error":""}' ;;
+lsjson) echo '[]' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "rclone"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Chdir(dir)
+
+	cfg := &config.Config{
+		Args:                     []string{"add", "gdrive", "drive", "scope=drive"},
+		RemoteServiceAccountFile: "sa.json",
+		RemoteToken:              `{"access_token":"x"}`,
+		SyncTargets: []config.SyncTarget{
+			config.ParseTarget("gdrive:HKSaves"),
+			config.ParseTarget("gdrive:Backups|||bwlimit=1M"),
+			config.ParseTarget("box:HKSaves"),
+		},
+	}
+	if err := RunRemote(context.Background(), cfg); err != nil {
+		t.Fatalf("RunRemote: %v", err)
+	}
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	wantCreate := `config create gdrive drive scope drive service_account_file ` + filepath.Join(dir, "sa.json") + ` token {"access_token":"x"} --non-interactive`
+	if !strings.Contains(got, wantCreate) {
+		t.Errorf("rclone was not called with %q; calls:\n%s", wantCreate, got)
+	}
+	// The remote is checked once per set of options its targets use.
+	if n := strings.Count(got, "lsjson --max-depth 1 --dirs-only gdrive:"); n != 2 {
+		t.Errorf("remote checked %d times, want 2; calls:\n%s", n, got)
+	}
+	if !strings.Contains(got, "gdrive: --bwlimit 1M") {
+		t.Errorf("the bandwidth limited target's check was not limited; calls:\n%s", got)
+	}
+}
@@ -0,0 +1,51 @@
+// /internal/launcher/report.go
+package launcher
+
+import (
+	"fmt"
+	"pirated-hollow-knight/internal/history"
+	"strings"
+)
+
+// printReport shows what the session changed in each slot.
+func printReport(session *history.Session) {
+	title := "Session report"
+	if session.Sandbox {
+		title = "Sandbox session report (discarded)"
+	}
+	if len(session.Slots) == 0 {
+		logger.Prompt("%s: %s played, no save slot changed.", title, formatPlayTime(session.Elapsed.Seconds()))
+		return
+	}
+	logger.Prompt("%s: %s played.", title, formatPlayTime(session.Elapsed.Seconds()))
+	for _, slot := range session.Slots {
+		logger.Prompt("  Slot %d: %s", slot.Slot, summariseSlot(slot))
+		if len(slot.Charms) > 0 {
+			logger.Prompt("    New charms: %s", strings.Join(slot.Charms, ", "))
+		}
+		if len(slot.Bosses) > 0 {
+			logger.Prompt("    Bosses defeated: %s", strings.Join(slot.Bosses, ", "))
+		}
+		if len(slot.Areas) > 0 {
+			logger.Prompt("    Areas visited: %s", strings.Join(slot.Areas, ", "))
+		}
+	}
+}
+
+// summariseSlot lists the counters that changed, e.g. "+0h25m in game, +350 geo".
+func summariseSlot(slot history.SlotResult) string {
+	parts := []string{fmt.Sprintf("+%s in game", formatPlayTime(max(slot.PlayTime, 0)))}
+	if slot.Geo != 0 {
+		parts = append(parts, fmt.Sprintf("%+d geo", slot.Geo))
+	}
+	if slot.Masks != 0 {
+		parts = append(parts, fmt.Sprintf("%+d mask(s)", slot.Masks))
+	}
+	if slot.Vessels != 0 {
+		parts = append(parts, fmt.Sprintf("%+d soul vessel(s)", slot.Vessels))
+	}
+	if slot.Completion != 0 {
+		parts = append(parts, fmt.Sprintf("%+.1f%% completion", slot.Completion))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,43 @@
+// /internal/launcher/sandbox.go
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"strings"
+)
+
+// sandboxesDir holds, in the data directory, one snapshot repository for
+// each name sandbox saves were kept under.
+const sandboxesDir = "sandboxes"
+
+// sandboxTarget returns the snapshot target sandbox saves kept as name go to.
+func sandboxTarget(cfg *config.Config, name string) config.SyncTarget {
+	return config.ParseTarget(filepath.Join(cfg.DataDir, sandboxesDir, name) + "|||format=snapshot")
+}
+
+// keepSandbox stores the saves of a sandbox session as the newest snapshot
+// of its name, if --keep-sandbox asked for it.
+func keepSandbox(ctx context.Context, cfg *config.Config, saveDir string) error {
+	if cfg.SandboxKeep == "" {
+		return nil
+	}
+	// The name becomes a directory, so it must not lead out of sandboxesDir.
+	if strings.ContainsAny(cfg.SandboxKeep, `/\:.`) {
+		return apperr.Errorf(apperr.ConfigInvalid, "--keep-sandbox %q must be a plain name", cfg.SandboxKeep)
+	}
+	target := sandboxTarget(cfg, cfg.SandboxKeep)
+	if err := backup.CreateSnapshot(ctx, cfg, saveDir, target); err != nil {
+		return apperr.Wrap(apperr.SyncFailed, fmt.Errorf("could not keep the sandbox saves as '%s': %w", cfg.SandboxKeep, err))
+	}
+	logger.Prompt("Kept the sandbox saves as '%s', the newest snapshot of '%s'.", cfg.SandboxKeep, target.Original)
+	return nil
+}
+
+func announceSandbox() {
+	logger.Prompt("🧪 Sandbox session: nothing the game changes will be kept. No target is written to and the saves are put back when the game exits.")
+}
@@ -0,0 +1,48 @@
+// /internal/launcher/sandbox_test.go
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestKeepSandbox(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	saveDir := filepath.Join(dir, "saves")
+	writeSlots(t, saveDir, map[int]testSlot{1: {playTime: 1200}, 3: {playTime: 60}})
+	want := dirContents(t, saveDir)
+
+	for _, name := range []string{"../escape", `a\b`, "c:d", "e.f", ".."} {
+		cfg := &config.Config{DataDir: filepath.Join(dir, "data"), SandboxKeep: name}
+		if err := keepSandbox(ctx, cfg, saveDir); apperr.KindOf(err) != apperr.ConfigInvalid {
+			t.Errorf("keepSandbox(%q) = %v, want a ConfigInvalid error", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data")); !os.IsNotExist(err) {
+		t.Errorf("keepSandbox with a bad name wrote to the data directory: %v", err)
+	}
+
+	cfg := &config.Config{DataDir: filepath.Join(dir, "data"), SandboxKeep: "boss-rush"}
+	if err := keepSandbox(ctx, cfg, saveDir); err != nil {
+		t.Fatal(err)
+	}
+	target := sandboxTarget(cfg, cfg.SandboxKeep)
+	snaps, err := backup.ListSnapshots(ctx, cfg, target)
+	if err != nil || len(snaps) != 1 {
+		t.Fatalf("ListSnapshots = %d snapshot(s), %v, want 1", len(snaps), err)
+	}
+	restored := filepath.Join(dir, "restored")
+	if _, err := backup.RestoreSnapshot(ctx, cfg, target, snaps[0].ID, restored); err != nil {
+		t.Fatal(err)
+	}
+	if got := dirContents(t, restored); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored sandbox saves = %v files, want %v files", len(got), len(want))
+	}
+}
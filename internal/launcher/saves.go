@@ -0,0 +1,281 @@
+// /internal/launcher/saves.go
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"slices"
+	"strings"
+)
+
+const savesUsage = `usage: saves diff <A> <B> [--slot=N] [--json]
+       saves edit <save> [--slot=N] [--set field=value...] [--patch file.json]
+       saves export <save> [--slot=N] [--out=file.hkbundle]
+       saves import <bundle> <save> [--slot=N]
+       saves undo <save> [--slot=N]`
+
+// RunSaves runs the commands that work on individual save slots.
+func RunSaves(ctx context.Context, cfg *config.Config) error {
+	if len(cfg.Args) == 0 {
+		return apperr.New(apperr.ConfigInvalid, savesUsage)
+	}
+	switch cfg.Args[0] {
+	case "diff":
+		return runSavesDiff(ctx, cfg, cfg.Args[1:])
+	case "edit":
+		return runSavesEdit(ctx, cfg, cfg.Args[1:])
+	case "export":
+		return runSavesExport(ctx, cfg, cfg.Args[1:])
+	case "import":
+		return runSavesImport(ctx, cfg, cfg.Args[1:])
+	case "undo":
+		return runSavesUndo(ctx, cfg, cfg.Args[1:])
+	}
+	return apperr.New(apperr.ConfigInvalid, savesUsage)
+}
+
+// saveSource is where a "saves" command reads saves from: a single save
+// file, or a save directory with one file per slot.
+type saveSource struct {
+	label string
+	file  string
+	dir   string
+	// staged is set if dir is a copy of target rather than the target itself.
+	staged bool
+	target config.SyncTarget
+	// snapshot is the ID of the snapshot of target that dir holds, if any.
+	snapshot string
+}
+
+// slotFile returns the main save file of slot. A single file stands for any slot.
+func (s saveSource) slotFile(slot int) string {
+	if s.file != "" {
+		return s.file
+	}
+	return filepath.Join(s.dir, saves.SlotFileName(slot))
+}
+
+// liveSaves names the game's own save directory in "saves" commands.
+const liveSaves = "live"
+
+// openSaveSource resolves spec to saves on disk. spec is a save file, a
+// target (its number or a target string), "<target>@<snapshot-id>" for a
+// snapshot of a snapshot target or "live" for the game's save directory.
+// Anything not already a local directory is copied to stagingDir first.
+func openSaveSource(ctx context.Context, cfg *config.Config, spec, stagingDir string) (saveSource, error) {
+	source := saveSource{label: spec}
+	if info, err := os.Stat(spec); err == nil && !info.IsDir() {
+		source.file = spec
+		return source, nil
+	}
+	if spec == liveSaves {
+		source.label = "live save directory"
+		source.dir = cfg.UserSavePath
+		source.target = config.SyncTarget{Type: config.Local, Path: cfg.UserSavePath, Original: cfg.UserSavePath}
+		return source, os.MkdirAll(source.dir, 0755)
+	}
+
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		if target, err := resolveTarget(cfg, spec[:i]); err == nil && target.Format == config.FormatSnapshot {
+			if _, err := backup.RestoreSnapshot(ctx, cfg, target, spec[i+1:], stagingDir); err != nil {
+				return source, err
+			}
+			source.dir, source.staged, source.target, source.snapshot = stagingDir, true, target, spec[i+1:]
+			return source, nil
+		}
+	}
+
+	target, err := resolveTarget(cfg, spec)
+	if err != nil {
+		return source, err
+	}
+	source.label, source.target = target.Original, target
+	if target.IsPlainLocal() {
+		// A directory that doesn't exist yet simply has no saves.
+		source.dir = target.Path
+		return source, nil
+	}
+	source.dir, source.staged = stagingDir, true
+	if mod, err := backup.LastModTime(ctx, cfg, target); err == nil && mod.IsZero() {
+		// Nothing has been saved to this target yet.
+		return source, os.MkdirAll(stagingDir, 0755)
+	}
+	if err := backup.Sync(ctx, cfg, target, config.SyncTarget{Type: config.Local, Path: stagingDir}); err != nil {
+		return source, apperr.Wrap(apperr.SourceUnavailable, fmt.Errorf("could not read '%s': %w", target.Original, err))
+	}
+	return source, nil
+}
+
+// slotDiff is the comparison of one slot in two save sources.
+type slotDiff struct {
+	Slot int `json:"slot"`
+	// OnlyIn names the source the slot exists in, if it is missing in the other.
+	OnlyIn      string             `json:"only_in,omitempty"`
+	Differences []saves.Difference `json:"differences"`
+}
+
+// runSavesDiff decodes the same slots of two saves and prints how their
+// player and scene data differ.
+func runSavesDiff(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 2 {
+		return apperr.New(apperr.ConfigInvalid, savesUsage)
+	}
+	stagingDir, err := os.MkdirTemp("", "hk-diff-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+	a, err := openSaveSource(ctx, cfg, args[0], filepath.Join(stagingDir, "a"))
+	if err != nil {
+		return err
+	}
+	b, err := openSaveSource(ctx, cfg, args[1], filepath.Join(stagingDir, "b"))
+	if err != nil {
+		return err
+	}
+
+	slots, err := diffSlots(cfg, a, b)
+	if err != nil {
+		return err
+	}
+	var results []slotDiff
+	for _, slot := range slots {
+		result, err := diffSlot(a, b, slot)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	if cfg.SaveJSON {
+		out, err := json.MarshalIndent(map[string]any{"a": a.label, "b": b.label, "slots": results}, "", "  ")
+		if err != nil {
+			return err
+		}
+		logger.Prompt("%s", out)
+		return nil
+	}
+	printSlotDiffs(a, b, results)
+	return nil
+}
+
+// diffSlots picks the slots to compare: the --slot one, or every slot that
+// exists on either side.
+func diffSlots(cfg *config.Config, a, b saveSource) ([]int, error) {
+	if cfg.SaveSlot > 0 {
+		return []int{cfg.SaveSlot}, nil
+	}
+	if a.file != "" && b.file != "" {
+		return []int{0}, nil
+	}
+	if a.file != "" || b.file != "" {
+		return nil, apperr.New(apperr.ConfigInvalid, "--slot is required to compare a save file with a target")
+	}
+	var slots []int
+	for _, dir := range []string{a.dir, b.dir} {
+		found, err := saves.Slots(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, slot := range found {
+			if !slices.Contains(slots, slot) {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	slices.Sort(slots)
+	if len(slots) == 0 {
+		return nil, apperr.New(apperr.SourceUnavailable, "neither side has any saves")
+	}
+	return slots, nil
+}
+
+func diffSlot(a, b saveSource, slot int) (slotDiff, error) {
+	result := slotDiff{Slot: slot}
+	docA, err := readSaveJSON(a.slotFile(slot))
+	if err != nil {
+		return result, err
+	}
+	docB, err := readSaveJSON(b.slotFile(slot))
+	if err != nil {
+		return result, err
+	}
+	switch {
+	case docA == nil && docB == nil:
+		return result, apperr.Errorf(apperr.SourceUnavailable, "slot %d exists on neither side", slot)
+	case docA == nil:
+		result.OnlyIn = b.label
+		docA = []byte("{}")
+	case docB == nil:
+		result.OnlyIn = a.label
+		docB = []byte("{}")
+	}
+	if result.Differences, err = saves.DiffJSON(docA, docB); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// readSaveJSON decodes a save file into its JSON document, or returns nil if
+// the file doesn't exist.
+func readSaveJSON(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := saves.DecodeJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+func printSlotDiffs(a, b saveSource, results []slotDiff) {
+	logger.Prompt("A: %s", a.label)
+	logger.Prompt("B: %s", b.label)
+	for _, r := range results {
+		name := fmt.Sprintf("Slot %d", r.Slot)
+		if r.Slot == 0 {
+			name = "Save"
+		}
+		switch {
+		case r.OnlyIn != "":
+			logger.Prompt("%s: only in %s", name, r.OnlyIn)
+			continue
+		case len(r.Differences) == 0:
+			logger.Prompt("%s: identical", name)
+			continue
+		}
+		logger.Prompt("%s: %d difference(s)", name, len(r.Differences))
+		section := ""
+		for _, d := range r.Differences {
+			if d.Section != section {
+				section = d.Section
+				logger.Prompt("  %s:", strings.ToUpper(section[:1])+section[1:])
+			}
+			logger.Prompt("    %s: %s → %s", d.Path, formatValue(d.Before), formatValue(d.After))
+		}
+	}
+}
+
+// formatValue renders a decoded JSON value, or "(none)" for a missing one.
+func formatValue(v any) string {
+	if v == nil {
+		return "(none)"
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
@@ -0,0 +1,424 @@
+// /internal/launcher/select.go
+package launcher
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"pirated-hollow-knight/internal/util"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sourceCandidate is a place the session's saves can be read from.
+type sourceCandidate struct {
+	target      config.SyncTarget // where saves are swapped in from and back out to
+	origin      config.SyncTarget // the configured target this candidate stands for
+	provisional bool              // target is the offline cache of an unreachable remote
+	behind      bool              // target is the retry copy of a failed backup to origin
+	modTime     time.Time
+	dir         string // local directory holding the candidate's saves, if available
+	staged      bool   // dir is a plain copy of a remote, snapshot or encrypted target
+	slotFiles   map[int][]string
+	slotMod     map[int]time.Time
+	slots       map[int]*saves.Save
+}
+
+// sessionPlan records where each part of the session's save directory comes from.
+type sessionPlan struct {
+	// base provides the files that belong to no slot, and every slot not in slots.
+	base  *sourceCandidate
+	slots map[int]*sourceCandidate
+}
+
+// sources returns each candidate the plan reads from once, base first.
+func (p sessionPlan) sources() []*sourceCandidate {
+	list := []*sourceCandidate{p.base}
+	for _, slot := range sortedKeys(p.slots) {
+		c := p.slots[slot]
+		if !slices.Contains(list, c) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// planSession picks, for every save slot, the target holding its newest
+// version according to the configured strategy, warning when the other
+// strategies disagree.
+func planSession(ctx context.Context, cfg *config.Config, retries *backup.RetryQueue, stagingDir string) (sessionPlan, error) {
+	candidates := collectCandidates(ctx, cfg, retries)
+	if len(candidates) == 0 {
+		return sessionPlan{}, errors.New("could not find any valid/accessible save targets")
+	}
+	if err := loadCandidateSaves(ctx, cfg, candidates, stagingDir); err != nil {
+		return sessionPlan{}, err
+	}
+	return pickSources(cfg, candidates), nil
+}
+
+// pickSources plans the session from candidates whose saves are loaded.
+func pickSources(cfg *config.Config, candidates []*sourceCandidate) sessionPlan {
+	base := pickByMtime(candidates)
+	switch cfg.SourceStrategy {
+	case config.StrategyPriority:
+		base = pickByPriority(candidates)
+	case config.StrategyPlaytime:
+		if pick := pickByPlaytime(candidates); pick >= 0 {
+			base = pick
+		}
+	}
+	plan := sessionPlan{base: candidates[base], slots: make(map[int]*sourceCandidate)}
+
+	for _, slot := range allSlots(candidates) {
+		picks := map[string]int{
+			config.StrategyMtime:    pickSlotByMtime(candidates, slot),
+			config.StrategyPriority: pickSlotByPriority(candidates, slot),
+			config.StrategyPlaytime: pickSlotByPlaytime(candidates, slot),
+		}
+
+		var chosen int
+		switch cfg.SourceStrategy {
+		case config.StrategyAsk:
+			chosen = askForSlotSource(candidates, slot, picks)
+		case config.StrategyPlaytime:
+			chosen = picks[config.StrategyPlaytime]
+			if chosen < 0 {
+				logger.Warn("No decodable save found to compare play time for slot %d. Falling back to 'mtime'.", slot)
+				chosen = picks[config.StrategyMtime]
+			}
+		default:
+			chosen = picks[cfg.SourceStrategy]
+		}
+
+		for _, name := range []string{config.StrategyMtime, config.StrategyPriority, config.StrategyPlaytime} {
+			if pick := picks[name]; pick >= 0 && pick != chosen {
+				logger.Warn("Slot %d: source strategy '%s' would have picked '%s' instead of '%s'.",
+					slot, name, candidates[pick].target.Original, candidates[chosen].target.Original)
+			}
+		}
+		plan.slots[slot] = candidates[chosen]
+	}
+	return plan
+}
+
+// collectCandidates returns every reachable target, substituting the offline
+// cache for remotes that are unreachable or hold unsynced offline progress,
+// and the retry copy for targets a failed backup left behind. A slot-mapped
+// target is as new as the slots it maps, whatever others save next to them.
+func collectCandidates(ctx context.Context, cfg *config.Config, retries *backup.RetryQueue) []*sourceCandidate {
+	var candidates []*sourceCandidate
+	for _, target := range cfg.SyncTargets {
+		candidate := &sourceCandidate{target: target, origin: target}
+		var err error
+		if target.Type == config.Local {
+			candidate.modTime, err = backup.SlotsLastModTime(ctx, cfg, target)
+		} else if info, ok := backup.LoadMirror(cfg, target); ok && info.Provisional {
+			// Offline progress that could not be uploaded yet is newer than the remote.
+			candidate.target, ok = backup.CachedTarget(cfg, target)
+			candidate.provisional = ok
+			if !ok {
+				logger.Warn("Offline cache for target '%s' is missing.", target.Original)
+				continue
+			}
+			candidate.modTime, err = backup.SlotsLastModTime(ctx, cfg, candidate.target)
+		} else {
+			candidate.modTime, err = backup.SlotsLastModTime(ctx, cfg, target)
+			if err != nil {
+				if cached, ok := backup.CachedTarget(cfg, target); ok {
+					logger.Warn("Target '%s' is unreachable (%v). Using its offline cache.", target.Original, err)
+					candidate.target, candidate.provisional = cached, true
+					candidate.modTime, err = backup.SlotsLastModTime(ctx, cfg, cached)
+				}
+			}
+		}
+
+		if spool, behind := retries.SpoolTarget(target); behind && !candidate.provisional {
+			// The retry copy holds saves the target never received, unless the
+			// target has been written to since.
+			spoolMod, spoolErr := util.GetDirLastModTime(spool.Path)
+			if spoolErr == nil && (err != nil || spoolMod.After(candidate.modTime)) {
+				logger.Warn("Target '%s' is behind a failed backup. Using the copy kept for retrying it.", target.Original)
+				candidate.target, candidate.behind = spool, true
+				candidate.modTime, err = spoolMod, nil
+			}
+		}
+
+		if err != nil {
+			logger.Warn("Could not get mod time for target '%s': %v", target.Original, err)
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// loadCandidateSaves decodes each candidate's save slots, downloading remote,
+// snapshot and encrypted targets into stagingDir first. A target that cannot
+// be decrypted stops the launch, so its saves are never silently skipped.
+func loadCandidateSaves(ctx context.Context, cfg *config.Config, candidates []*sourceCandidate, stagingDir string) error {
+	for i, c := range candidates {
+		c.dir = c.target.Path
+		if c.modTime.IsZero() {
+			// Nothing has been saved to this target yet.
+			continue
+		}
+		if !c.target.IsPlainLocal() {
+			dir := filepath.Join(stagingDir, strconv.Itoa(i))
+			if err := backup.Sync(ctx, cfg, c.target, config.SyncTarget{Type: config.Local, Path: dir}); err != nil {
+				if errors.Is(err, backup.ErrWrongKey) {
+					return err
+				}
+				logger.Warn("Could not download '%s' to inspect its saves: %v", c.target.Original, err)
+				c.dir = ""
+				continue
+			}
+			c.dir, c.staged = dir, true
+		}
+
+		c.slotFiles, c.slotMod = make(map[int][]string), make(map[int]time.Time)
+		slots, err := saves.Slots(c.dir)
+		if err != nil {
+			logger.Warn("Could not list saves of '%s': %v", c.target.Original, err)
+		}
+		for _, slot := range slots {
+			names, err := saves.SlotFiles(c.dir, slot)
+			if err != nil {
+				logger.Warn("Could not list slot %d of '%s': %v", slot, c.target.Original, err)
+				continue
+			}
+			c.slotFiles[slot] = names
+			for _, name := range names {
+				if info, err := os.Stat(filepath.Join(c.dir, name)); err == nil && info.ModTime().After(c.slotMod[slot]) {
+					c.slotMod[slot] = info.ModTime()
+				}
+			}
+		}
+
+		loaded, failed := saves.LoadSlots(c.dir)
+		for slot, err := range failed {
+			logger.Warn("Could not read slot %d of '%s': %v", slot, c.target.Original, err)
+		}
+		c.slots = loaded
+	}
+	return nil
+}
+
+// assembleSession fills the real save directory according to plan: the base
+// source is copied in whole, then each slot taken from another source replaces
+// the base's version of that slot.
+func assembleSession(ctx context.Context, cfg *config.Config, plan sessionPlan, realSaveTarget config.SyncTarget) error {
+	swapInSource := plan.base.target
+	if plan.base.staged {
+		// Already downloaded while comparing sources.
+		swapInSource = config.SyncTarget{Type: config.Local, Path: plan.base.dir}
+	}
+	if err := backup.Sync(ctx, cfg, swapInSource, realSaveTarget); err != nil {
+		return fmt.Errorf("failed to swap in saves from '%s': %w", plan.base.target.Original, err)
+	}
+	if err := os.MkdirAll(realSaveTarget.Path, 0755); err != nil {
+		return err
+	}
+
+	for _, slot := range sortedKeys(plan.slots) {
+		c := plan.slots[slot]
+		if c == plan.base {
+			continue
+		}
+		logger.Info("Slot %d: using the newer version from '%s'.", slot, c.target.Original)
+		stale, err := saves.SlotFiles(realSaveTarget.Path, slot)
+		if err != nil {
+			return err
+		}
+		for _, name := range stale {
+			if err := os.Remove(filepath.Join(realSaveTarget.Path, name)); err != nil {
+				return fmt.Errorf("could not replace slot %d: %w", slot, err)
+			}
+		}
+		for _, name := range c.slotFiles[slot] {
+			if err := util.CopyFile(filepath.Join(c.dir, name), filepath.Join(realSaveTarget.Path, name)); err != nil {
+				return fmt.Errorf("could not copy slot %d from '%s': %w", slot, c.target.Original, err)
+			}
+		}
+	}
+	return nil
+}
+
+func pickByMtime(candidates []*sourceCandidate) int {
+	best := 0
+	for i, c := range candidates {
+		if c.modTime.After(candidates[best].modTime) {
+			best = i
+		}
+	}
+	return best
+}
+
+// pickByPriority returns the first target, in configured order, that holds any saves.
+func pickByPriority(candidates []*sourceCandidate) int {
+	for i, c := range candidates {
+		if len(c.slots) > 0 {
+			return i
+		}
+	}
+	for i, c := range candidates {
+		if !c.modTime.IsZero() {
+			return i
+		}
+	}
+	return 0
+}
+
+// pickByPlaytime returns the target holding the largest in-save play time for
+// the most slots, or -1 if no candidate has decodable saves. Ties go to the
+// larger total play time, then to configured order.
+func pickByPlaytime(candidates []*sourceCandidate) int {
+	bestPerSlot := make(map[int]float64)
+	for _, c := range candidates {
+		for slot, save := range c.slots {
+			bestPerSlot[slot] = max(bestPerSlot[slot], save.PlayerData.PlayTime)
+		}
+	}
+	if len(bestPerSlot) == 0 {
+		return -1
+	}
+
+	best, bestWins, bestTotal := -1, -1, -1.0
+	for i, c := range candidates {
+		wins, total := 0, 0.0
+		for slot, save := range c.slots {
+			total += save.PlayerData.PlayTime
+			if save.PlayerData.PlayTime >= bestPerSlot[slot] {
+				wins++
+			}
+		}
+		if wins > bestWins || (wins == bestWins && total > bestTotal) {
+			best, bestWins, bestTotal = i, wins, total
+		}
+	}
+	return best
+}
+
+func pickSlotByMtime(candidates []*sourceCandidate, slot int) int {
+	best := -1
+	for i, c := range candidates {
+		if _, ok := c.slotFiles[slot]; ok && (best < 0 || c.slotMod[slot].After(candidates[best].slotMod[slot])) {
+			best = i
+		}
+	}
+	return best
+}
+
+func pickSlotByPriority(candidates []*sourceCandidate, slot int) int {
+	for i, c := range candidates {
+		if _, ok := c.slotFiles[slot]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// pickSlotByPlaytime returns the candidate with the largest in-save play time
+// for slot, or -1 if no candidate has a decodable save for it.
+func pickSlotByPlaytime(candidates []*sourceCandidate, slot int) int {
+	best := -1
+	for i, c := range candidates {
+		save, ok := c.slots[slot]
+		if ok && (best < 0 || save.PlayerData.PlayTime > candidates[best].slots[slot].PlayerData.PlayTime) {
+			best = i
+		}
+	}
+	return best
+}
+
+// askForSlotSource lists the candidates holding slot and lets the player
+// choose one. Without an answer it falls back to the 'mtime' pick.
+func askForSlotSource(candidates []*sourceCandidate, slot int, picks map[string]int) int {
+	fallback := picks[config.StrategyMtime]
+	var holders []int
+	for i, c := range candidates {
+		if _, ok := c.slotFiles[slot]; ok {
+			holders = append(holders, i)
+		}
+	}
+	if len(holders) == 1 {
+		return holders[0]
+	}
+
+	logger.Prompt("Choose the source for slot %d:", slot)
+	for n, i := range holders {
+		c := candidates[i]
+		var suggested []string
+		for name, pick := range picks {
+			if pick == i {
+				suggested = append(suggested, name)
+			}
+		}
+		sort.Strings(suggested)
+		note := ""
+		if len(suggested) > 0 {
+			note = fmt.Sprintf("  <- %s", strings.Join(suggested, ", "))
+		}
+		details := "unreadable save"
+		if save, ok := c.slots[slot]; ok {
+			pd := save.PlayerData
+			details = fmt.Sprintf("%s played, %.0f%% complete", formatPlayTime(pd.PlayTime), pd.CompletionPercentage)
+		}
+		logger.Prompt("  [%d] %s (modified %s, %s)%s", n+1, c.target.Original, formatModTime(c.slotMod[slot]), details, note)
+	}
+	def := slices.Index(holders, fallback)
+	logger.Prompt("Enter a number [default %d]: ", def+1)
+
+	line, _ := stdin.ReadString('\n')
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(holders) {
+		logger.Prompt("Using [%d] %s.", def+1, candidates[fallback].target.Original)
+		return fallback
+	}
+	return holders[choice-1]
+}
+
+// stdin is shared by every prompt so buffered input is not lost between them.
+var stdin = bufio.NewReader(os.Stdin)
+
+// allSlots returns every slot held by any candidate, in order.
+func allSlots(candidates []*sourceCandidate) []int {
+	seen := make(map[int]bool)
+	for _, c := range candidates {
+		for slot := range c.slotFiles {
+			seen[slot] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func formatModTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+// formatPlayTime renders an in-save play time (seconds) as hours and minutes.
+func formatPlayTime(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
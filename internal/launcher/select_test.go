@@ -0,0 +1,316 @@
+// /internal/launcher/select_test.go
+package launcher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/saves"
+	"pirated-hollow-knight/internal/util"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSlot is a save slot written for a test: its in-save play time and how
+// long ago it was modified.
+type testSlot struct {
+	playTime float64
+	age      time.Duration
+}
+
+// slotsWritten is the time testSlot ages are counted back from, the same for
+// every test so that equal ages give equal modification times.
+var slotsWritten = time.Now()
+
+// writeSlots writes a decodable save for each slot into dir.
+func writeSlots(t *testing.T, dir string, slots map[int]testSlot) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for slot, s := range slots {
+		data, err := saves.EncodeJSON(fmt.Appendf(nil, `{"playerData":{"playTime":%g,"completionPercentage":10},"sceneData":{}}`, s.playTime))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, saves.SlotFileName(slot))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, slotsWritten.Add(-s.age), slotsWritten.Add(-s.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// slotPlayTimes returns the play time of every slot saved in dir.
+func slotPlayTimes(t *testing.T, dir string) map[int]float64 {
+	t.Helper()
+	loaded, failed := saves.LoadSlots(dir)
+	if len(failed) > 0 {
+		t.Fatalf("LoadSlots: %v", failed)
+	}
+	times := make(map[int]float64)
+	for slot, save := range loaded {
+		times[slot] = save.PlayerData.PlayTime
+	}
+	return times
+}
+
+func TestPlanSession(t *testing.T) {
+	// Slot 1 is newest in the second target but played longest in the first,
+	// slot 2 is only in the second target, and slot 3 is newest in the first
+	// target but played longest in the second.
+	disagree := []map[int]testSlot{
+		{1: {100, 3 * time.Hour}, 3: {10, 0}},
+		{1: {50, time.Hour}, 2: {30, 2 * time.Hour}, 3: {20, 4 * time.Hour}},
+	}
+	tied := []map[int]testSlot{
+		{1: {100, time.Hour}},
+		{1: {100, time.Hour}},
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		answers  string
+		targets  []map[int]testSlot
+		wantBase int
+		want     map[int]int // the target each slot is taken from
+	}{
+		{"mtime", config.StrategyMtime, "", disagree, 0, map[int]int{1: 1, 2: 1, 3: 0}},
+		{"priority", config.StrategyPriority, "", disagree, 0, map[int]int{1: 0, 2: 1, 3: 0}},
+		{"playtime", config.StrategyPlaytime, "", disagree, 1, map[int]int{1: 0, 2: 1, 3: 1}},
+		// Slot 2 has a single holder and is not asked about.
+		{"ask", config.StrategyAsk, "1\n2\n", disagree, 0, map[int]int{1: 0, 2: 1, 3: 1}},
+		{"ask without answers", config.StrategyAsk, "", disagree, 0, map[int]int{1: 1, 2: 1, 3: 0}},
+		{"mtime tie", config.StrategyMtime, "", tied, 0, map[int]int{1: 0}},
+		{"playtime tie", config.StrategyPlaytime, "", tied, 0, map[int]int{1: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := &config.Config{DataDir: filepath.Join(dir, "data"), SourceStrategy: tt.strategy}
+			for i, slots := range tt.targets {
+				target := filepath.Join(dir, fmt.Sprint("target", i))
+				writeSlots(t, target, slots)
+				cfg.SyncTargets = append(cfg.SyncTargets, config.ParseTarget(target))
+			}
+			retries, err := backup.OpenRetryQueue(cfg.DataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdin = bufio.NewReader(strings.NewReader(tt.answers))
+			t.Cleanup(func() { stdin = bufio.NewReader(os.Stdin) })
+
+			plan, err := planSession(context.Background(), cfg, retries, filepath.Join(dir, "staging"))
+			if err != nil {
+				t.Fatalf("planSession: %v", err)
+			}
+			if plan.base.origin.Path != cfg.SyncTargets[tt.wantBase].Path {
+				t.Errorf("base = %s, want target %d", plan.base.origin.Original, tt.wantBase)
+			}
+			got := make(map[int]int)
+			for slot, c := range plan.slots {
+				for i, target := range cfg.SyncTargets {
+					if c.origin.Path == target.Path {
+						got[slot] = i
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("slots taken from %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectCandidatesSlotMap(t *testing.T) {
+	dir := t.TempDir()
+	own := filepath.Join(dir, "own")
+	shared := filepath.Join(dir, "shared")
+	writeSlots(t, own, map[int]testSlot{1: {100, 2 * time.Hour}})
+	// Another player saved slot 2 to the shared target after this machine's slot 1.
+	writeSlots(t, shared, map[int]testSlot{1: {50, 3 * time.Hour}, 2: {10, 0}})
+	cfg := &config.Config{
+		DataDir:     filepath.Join(dir, "data"),
+		SyncTargets: []config.SyncTarget{config.ParseTarget(own), config.ParseTarget(shared + "|||slots=1")},
+	}
+	retries, err := backup.OpenRetryQueue(cfg.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := collectCandidates(context.Background(), cfg, retries)
+	if len(candidates) != 2 {
+		t.Fatalf("got %d candidates, want 2", len(candidates))
+	}
+	if want := slotsWritten.Add(-3 * time.Hour); !candidates[1].modTime.Equal(want) {
+		t.Errorf("slot-mapped target modified %s, want %s of its mapped slot", candidates[1].modTime, want)
+	}
+	if base := pickByMtime(candidates); base != 0 {
+		t.Errorf("pickByMtime picked %s, newer only in a slot it doesn't map", candidates[base].target.Original)
+	}
+}
+
+func TestPickSources(t *testing.T) {
+	// candidate builds a loaded source candidate. A negative play time stands
+	// for a slot whose save could not be decoded.
+	candidate := func(name string, slots map[int]testSlot) *sourceCandidate {
+		c := &sourceCandidate{
+			target:    config.SyncTarget{Type: config.Local, Path: name, Original: name},
+			slotFiles: make(map[int][]string),
+			slotMod:   make(map[int]time.Time),
+			slots:     make(map[int]*saves.Save),
+		}
+		for slot, s := range slots {
+			mod := slotsWritten.Add(-s.age)
+			c.slotFiles[slot] = []string{saves.SlotFileName(slot)}
+			c.slotMod[slot] = mod
+			if mod.After(c.modTime) {
+				c.modTime = mod
+			}
+			if s.playTime >= 0 {
+				c.slots[slot] = &saves.Save{PlayerData: saves.PlayerData{PlayTime: s.playTime}}
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		answers  string
+		targets  []map[int]testSlot
+		wantBase int
+		want     map[int]int // the target each slot is taken from
+	}{
+		{"mtime", config.StrategyMtime, "", []map[int]testSlot{{1: {100, time.Hour}}, {1: {50, 0}}}, 1, map[int]int{1: 1}},
+		{"mtime tie goes to configured order", config.StrategyMtime, "", []map[int]testSlot{{1: {50, time.Hour}}, {1: {100, time.Hour}}}, 0, map[int]int{1: 0}},
+		{"mtime ignores undecodable saves", config.StrategyMtime, "", []map[int]testSlot{{1: {100, time.Hour}}, {1: {-1, 0}}}, 1, map[int]int{1: 1}},
+		{"priority", config.StrategyPriority, "", []map[int]testSlot{{1: {50, time.Hour}}, {1: {100, 0}, 2: {10, 0}}}, 0, map[int]int{1: 0, 2: 1}},
+		{"priority base skips targets without decodable saves", config.StrategyPriority, "", []map[int]testSlot{{1: {-1, 0}}, {1: {100, time.Hour}}}, 1, map[int]int{1: 0}},
+		{"playtime", config.StrategyPlaytime, "", []map[int]testSlot{{1: {50, 0}}, {1: {100, time.Hour}}}, 1, map[int]int{1: 1}},
+		{"playtime tie goes to configured order", config.StrategyPlaytime, "", []map[int]testSlot{{1: {100, time.Hour}}, {1: {100, 0}}}, 0, map[int]int{1: 0}},
+		{"playtime base tie goes to total play time", config.StrategyPlaytime, "", []map[int]testSlot{{1: {100, 0}, 2: {10, 0}}, {1: {50, 0}, 2: {80, 0}}}, 1, map[int]int{1: 0, 2: 1}},
+		{"playtime prefers decodable saves", config.StrategyPlaytime, "", []map[int]testSlot{{1: {-1, 0}}, {1: {10, time.Hour}}}, 1, map[int]int{1: 1}},
+		{"playtime falls back to mtime", config.StrategyPlaytime, "", []map[int]testSlot{{1: {-1, time.Hour}}, {1: {-1, 0}}}, 1, map[int]int{1: 1}},
+		{"ask", config.StrategyAsk, "2\n", []map[int]testSlot{{1: {100, 0}}, {1: {50, time.Hour}}}, 0, map[int]int{1: 1}},
+		{"ask offers undecodable saves", config.StrategyAsk, "1\n", []map[int]testSlot{{1: {-1, time.Hour}}, {1: {50, 0}}}, 1, map[int]int{1: 0}},
+		{"ask out of range falls back to mtime", config.StrategyAsk, "3\n", []map[int]testSlot{{1: {100, 0}}, {1: {50, time.Hour}}}, 0, map[int]int{1: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var candidates []*sourceCandidate
+			for i, slots := range tt.targets {
+				candidates = append(candidates, candidate(fmt.Sprint("target", i), slots))
+			}
+			stdin = bufio.NewReader(strings.NewReader(tt.answers))
+			t.Cleanup(func() { stdin = bufio.NewReader(os.Stdin) })
+
+			plan := pickSources(&config.Config{SourceStrategy: tt.strategy}, candidates)
+			if plan.base != candidates[tt.wantBase] {
+				t.Errorf("base = %s, want target%d", plan.base.target.Original, tt.wantBase)
+			}
+			got := make(map[int]int)
+			for slot, c := range plan.slots {
+				got[slot] = slices.Index(candidates, c)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("slots taken from %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanSessionWithoutTargets(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DataDir: dir}
+	retries, err := backup.OpenRetryQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := planSession(context.Background(), cfg, retries, filepath.Join(dir, "staging")); err == nil {
+		t.Error("planSession succeeded without any target")
+	}
+}
+
+func TestAssembleSessionFromOfflineCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	local := config.ParseTarget(filepath.Join(dir, "local"))
+	remote := config.ParseTarget("gdrive:HKSaves")
+	// The offline cache of the unreachable remote holds the newest save, slot 2,
+	// and an older slot 1 with a backup file the local target's slot 1 lacks.
+	cache := config.SyncTarget{Type: config.Local, Path: filepath.Join(dir, "cache"), Original: "gdrive:HKSaves (offline cache)"}
+	writeSlots(t, local.Path, map[int]testSlot{1: {200, 10 * time.Minute}})
+	writeSlots(t, cache.Path, map[int]testSlot{1: {100, 3 * time.Hour}, 2: {50, 0}})
+	writeFiles(t, cache.Path, map[string]string{"user1.dat.bak1": "old backup", "shared.dat": "settings"})
+	old := slotsWritten.Add(-3 * time.Hour)
+	if err := os.Chtimes(filepath.Join(cache.Path, "user1.dat.bak1"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{SourceStrategy: config.StrategyMtime, SyncTargets: []config.SyncTarget{local, remote}}
+	candidates := []*sourceCandidate{
+		{target: local, origin: local},
+		{target: cache, origin: remote, provisional: true},
+	}
+	for _, c := range candidates {
+		var err error
+		if c.modTime, err = util.GetDirLastModTime(c.target.Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := loadCandidateSaves(ctx, cfg, candidates, filepath.Join(dir, "staging")); err != nil {
+		t.Fatal(err)
+	}
+	plan := pickSources(cfg, candidates)
+	if plan.base != candidates[1] || plan.slots[1] != candidates[0] || plan.slots[2] != candidates[1] {
+		t.Fatalf("plan takes the base from %s, slot 1 from %s and slot 2 from %s",
+			plan.base.target.Original, plan.slots[1].target.Original, plan.slots[2].target.Original)
+	}
+	if sources := plan.sources(); len(sources) != 2 || !sources[0].provisional {
+		t.Errorf("sources = %v, want the offline cache first and marked provisional", sources)
+	}
+
+	session := config.SyncTarget{Type: config.Local, Path: filepath.Join(dir, "real")}
+	writeFiles(t, session.Path, map[string]string{"user3.dat": "left from the last session"})
+	if err := assembleSession(ctx, cfg, plan, session); err != nil {
+		t.Fatalf("assembleSession: %v", err)
+	}
+	if got, want := slotPlayTimes(t, session.Path), map[int]float64{1: 200, 2: 50}; !reflect.DeepEqual(got, want) {
+		t.Errorf("session slots have play times %v, want %v", got, want)
+	}
+	entries, err := os.ReadDir(session.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"shared.dat", "user1.dat", "user2.dat"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("session directory holds %v, want %v", names, want)
+	}
+}
+
+// writeFiles creates dir with files, named to their contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// /internal/launcher/snapshots.go
+package launcher
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"strconv"
+	"time"
+)
+
+// RunSnapshots lists the snapshots stored in a snapshot target.
+func RunSnapshots(ctx context.Context, cfg *config.Config) error {
+	if len(cfg.Args) != 1 {
+		return apperr.New(apperr.ConfigInvalid, "usage: snapshots <target>")
+	}
+	target, err := resolveTarget(cfg, cfg.Args[0])
+	if err != nil {
+		return err
+	}
+	if target.Format != config.FormatSnapshot {
+		return apperr.Errorf(apperr.ConfigInvalid, "'%s' is not a snapshot target (add '|||format=snapshot')", target.Original)
+	}
+
+	snaps, err := backup.ListSnapshots(ctx, cfg, target)
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		logger.Prompt("No snapshots in '%s'.", target.Original)
+		return nil
+	}
+	logger.Prompt("Snapshots in '%s':", target.Original)
+	for _, s := range snaps {
+		var size int64
+		for _, f := range s.Files {
+			size += f.Size
+		}
+		logger.Prompt("  %s  %s  %d file(s), %d bytes", s.ID, s.Time.Local().Format(time.DateTime), len(s.Files), size)
+	}
+	return nil
+}
+
+// RunRestore restores a snapshot of a snapshot target. With --to, the snapshot
+// is extracted into that directory. Otherwise it is committed again as the
+// target's newest snapshot, so the next launch starts from it.
+func RunRestore(ctx context.Context, cfg *config.Config) error {
+	if len(cfg.Args) < 1 || len(cfg.Args) > 2 {
+		return apperr.New(apperr.ConfigInvalid, "usage: restore <target> [snapshot-id] [--to dir]")
+	}
+	target, err := resolveTarget(cfg, cfg.Args[0])
+	if err != nil {
+		return err
+	}
+	if target.Format != config.FormatSnapshot {
+		return apperr.Errorf(apperr.ConfigInvalid, "'%s' is not a snapshot target (add '|||format=snapshot')", target.Original)
+	}
+	id := ""
+	if len(cfg.Args) == 2 {
+		id = cfg.Args[1]
+	}
+
+	if cfg.RestoreTo != "" {
+		if err := checkRestoreDir(cfg.RestoreTo); err != nil {
+			return err
+		}
+		snap, err := backup.RestoreSnapshot(ctx, cfg, target, id, cfg.RestoreTo)
+		if err != nil {
+			return err
+		}
+		logger.Prompt("Restored snapshot %s to '%s'.", snap.ID, cfg.RestoreTo)
+		return nil
+	}
+
+	if id == "" {
+		return apperr.New(apperr.ConfigInvalid, "specify a snapshot ID to make it the newest, or --to to extract the latest one")
+	}
+	tmp, err := os.MkdirTemp("", "hk-restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	snap, err := backup.RestoreSnapshot(ctx, cfg, target, id, tmp)
+	if err != nil {
+		return err
+	}
+	// Committed with the current time, the snapshot is also the newest by
+	// modification time, which the default source strategy goes by.
+	if err := touchTree(tmp, time.Now()); err != nil {
+		return err
+	}
+	if err := backup.CreateSnapshot(ctx, cfg, tmp, target); err != nil {
+		return err
+	}
+	logger.Prompt("Snapshot %s is now the newest in '%s'.", snap.ID, target.Original)
+	return nil
+}
+
+// checkRestoreDir refuses to extract a snapshot into anything but a new or
+// empty directory, since extracting replaces what the directory holds.
+func checkRestoreDir(dir string) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return apperr.Errorf(apperr.ConfigInvalid, "'%s' is not a directory", dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return apperr.Errorf(apperr.ConfigInvalid, "'%s' is not empty; --to only extracts into a new or empty directory", dir)
+	}
+	return nil
+}
+
+// touchTree sets the modification time of every file below dir to t.
+func touchTree(dir string, t time.Time) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		return os.Chtimes(path, t, t)
+	})
+}
+
+// resolveTarget accepts either the 1-based index of a configured target or a
+// target string in the usual "path|interval|quit_sync|options" format.
+func resolveTarget(cfg *config.Config, arg string) (config.SyncTarget, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(cfg.SyncTargets) {
+			return config.SyncTarget{}, apperr.Errorf(apperr.ConfigInvalid, "target %d does not exist (%d configured)", n, len(cfg.SyncTargets))
+		}
+		return cfg.SyncTargets[n-1], nil
+	}
+	target := config.ParseTarget(arg)
+	if err := target.Validate(); err != nil {
+		return target, apperr.Errorf(apperr.ConfigInvalid, "target %q: %w", arg, err)
+	}
+	return target, nil
+}
@@ -0,0 +1,48 @@
+// /internal/launcher/snapshots_test.go
+package launcher
+
+import (
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"testing"
+)
+
+func TestCheckRestoreDir(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	full := filepath.Join(dir, "full")
+	file := filepath.Join(dir, "file")
+	for _, d := range []string{empty, full} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{filepath.Join(full, "notes.txt"), file} {
+		if err := os.WriteFile(f, []byte("keep"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{"new directory", filepath.Join(dir, "new"), false},
+		{"empty directory", empty, false},
+		{"directory with files", full, true},
+		{"file", file, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRestoreDir(tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRestoreDir error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && apperr.KindOf(err) != apperr.ConfigInvalid {
+				t.Errorf("kind = %v, want %v", apperr.KindOf(err), apperr.ConfigInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// /internal/launcher/stats.go
+package launcher
+
+import (
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/config"
+	"pirated-hollow-knight/internal/history"
+	"slices"
+	"sort"
+	"time"
+)
+
+// playTotals adds up the sessions of one day, profile or slot.
+type playTotals struct {
+	sessions   int
+	elapsed    time.Duration
+	playTime   float64
+	completion float64
+	last       time.Time
+}
+
+func (t *playTotals) add(s history.Session, playTime, completion float64) {
+	t.sessions++
+	t.elapsed += s.Elapsed
+	t.playTime += playTime
+	t.completion += completion
+	if s.Start.After(t.last) {
+		t.last = s.Start
+	}
+}
+
+// slotKey identifies a slot; slot 1 of one profile is not slot 1 of another.
+type slotKey struct {
+	profile string
+	slot    int
+}
+
+// playStats is the session history added up in total and per day, profile
+// and slot.
+type playStats struct {
+	total    playTotals
+	days     map[string]*playTotals
+	profiles map[string]*playTotals
+	slots    map[slotKey]*playTotals
+}
+
+// summariseSessions adds up sessions. Days are the local dates the sessions
+// started on, in time.DateOnly format.
+func summariseSessions(sessions []history.Session) playStats {
+	stats := playStats{
+		days:     make(map[string]*playTotals),
+		profiles: make(map[string]*playTotals),
+		slots:    make(map[slotKey]*playTotals),
+	}
+	for _, s := range sessions {
+		var playTime, completion float64
+		kept := s.Slots
+		if s.Sandbox {
+			// A sandbox session made no progress that was kept.
+			kept = nil
+		}
+		for _, slot := range kept {
+			playTime += slot.PlayTime
+			completion += slot.Completion
+			totalsFor(stats.slots, slotKey{s.Profile, slot.Slot}).add(s, slot.PlayTime, slot.Completion)
+		}
+		stats.total.add(s, playTime, completion)
+		totalsFor(stats.days, s.Start.Local().Format(time.DateOnly)).add(s, playTime, completion)
+		totalsFor(stats.profiles, s.Profile).add(s, playTime, completion)
+	}
+	return stats
+}
+
+// RunStats summarises the session history: play time per day, per profile
+// and per slot. An optional argument limits it to one profile.
+func RunStats(cfg *config.Config) error {
+	if len(cfg.Args) > 1 {
+		return apperr.New(apperr.ConfigInvalid, "usage: stats [profile] [--days=N]")
+	}
+	store, err := history.Open(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	sessions, err := store.Sessions()
+	store.Close()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Args) == 1 {
+		sessions = slices.DeleteFunc(sessions, func(s history.Session) bool { return s.Profile != cfg.Args[0] })
+	}
+	if len(sessions) == 0 {
+		logger.Prompt("No sessions recorded yet.")
+		return nil
+	}
+
+	stats := summariseSessions(sessions)
+
+	logger.Prompt("%d session(s), %s played, %s in game, last on %s", stats.total.sessions, formatPlayTime(stats.total.elapsed.Seconds()), formatPlayTime(stats.total.playTime), formatModTime(stats.total.last))
+
+	logger.Prompt("Last %d day(s):", cfg.StatsDays)
+	today := time.Now()
+	for i := cfg.StatsDays - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format(time.DateOnly)
+		if t, ok := stats.days[day]; ok {
+			logger.Prompt("  %s  %3d session(s)  %s played  %s in game", day, t.sessions, formatPlayTime(t.elapsed.Seconds()), formatPlayTime(t.playTime))
+		}
+	}
+
+	logger.Prompt("Profiles:")
+	for _, name := range sortedNames(stats.profiles) {
+		t := stats.profiles[name]
+		logger.Prompt("  %s: %d session(s), %s played, %s in game, last on %s", name, t.sessions, formatPlayTime(t.elapsed.Seconds()), formatPlayTime(t.playTime), formatModTime(t.last))
+	}
+
+	logger.Prompt("Slots:")
+	keys := make([]slotKey, 0, len(stats.slots))
+	for k := range stats.slots {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].profile != keys[j].profile {
+			return keys[i].profile < keys[j].profile
+		}
+		return keys[i].slot < keys[j].slot
+	})
+	for _, k := range keys {
+		t := stats.slots[k]
+		logger.Prompt("  %s, slot %d: %d session(s), %s in game, %+.1f%% completion, last on %s", k.profile, k.slot, t.sessions, formatPlayTime(t.playTime), t.completion, formatModTime(t.last))
+	}
+	return nil
+}
+
+func totalsFor[K comparable](m map[K]*playTotals, key K) *playTotals {
+	t, ok := m[key]
+	if !ok {
+		t = &playTotals{}
+		m[key] = t
+	}
+	return t
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,65 @@
+// /internal/launcher/stats_test.go
+package launcher
+
+import (
+	"pirated-hollow-knight/internal/history"
+	"pirated-hollow-knight/internal/saves"
+	"testing"
+	"time"
+)
+
+func TestSummariseSessions(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	// 23:30 UTC is already the next day in UTC+2.
+	late := time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC)
+	sessions := []history.Session{
+		{Profile: "main", Start: late.Add(-3 * time.Hour), Elapsed: time.Hour, Slots: []history.SlotResult{
+			{Slot: 1, Changed: true, Changes: saves.Changes{PlayTime: 3000, Completion: 2}},
+			{Slot: 2, Changed: true, Changes: saves.Changes{PlayTime: 600, Completion: 1}},
+		}},
+		{Profile: "main", Start: late, Elapsed: 30 * time.Minute, Slots: []history.SlotResult{
+			{Slot: 1, Changed: true, Changes: saves.Changes{PlayTime: 1200, Completion: 0.5}},
+		}},
+		{Profile: "speedrun", Start: late.Add(time.Hour), Elapsed: 10 * time.Minute, Slots: []history.SlotResult{
+			{Slot: 1, Changed: true, Changes: saves.Changes{PlayTime: 500, Completion: 3}},
+		}},
+		// A sandbox session counts as played, but its progress was thrown away.
+		{Profile: "speedrun", Start: late.Add(2 * time.Hour), Elapsed: 20 * time.Minute, Sandbox: true, Slots: []history.SlotResult{
+			{Slot: 2, Changed: true, Changes: saves.Changes{PlayTime: 900, Completion: 4}},
+		}},
+	}
+	stats := summariseSessions(sessions)
+
+	if got := stats.total; got.sessions != 4 || got.elapsed != 120*time.Minute || got.playTime != 5300 || got.completion != 6.5 || !got.last.Equal(late.Add(2*time.Hour)) {
+		t.Errorf("total = %+v", got)
+	}
+
+	days := map[string]int{"2026-03-01": 1, "2026-03-02": 3}
+	if len(stats.days) != len(days) {
+		t.Errorf("days = %v, want %v", stats.days, days)
+	}
+	for day, n := range days {
+		if got, ok := stats.days[day]; !ok || got.sessions != n {
+			t.Errorf("day %s has %+v, want %d session(s)", day, got, n)
+		}
+	}
+
+	if got := stats.profiles["main"]; got == nil || got.sessions != 2 || got.playTime != 4800 {
+		t.Errorf("profile main = %+v, want 2 sessions with 4800s in game", got)
+	}
+	if got := stats.profiles["speedrun"]; got == nil || got.sessions != 2 || got.playTime != 500 {
+		t.Errorf("profile speedrun = %+v, want 2 sessions with 500s in game", got)
+	}
+	slots := map[slotKey]float64{{"main", 1}: 4200, {"main", 2}: 600, {"speedrun", 1}: 500}
+	if len(stats.slots) != len(slots) {
+		t.Errorf("got %d slots, want %d", len(stats.slots), len(slots))
+	}
+	for key, playTime := range slots {
+		if got, ok := stats.slots[key]; !ok || got.playTime != playTime {
+			t.Errorf("slot %+v = %+v, want %.0fs in game", key, got, playTime)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// /internal/launcher/status.go
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"strings"
+	"time"
+)
+
+// RunStatus prints every configured target and whether it is behind.
+func RunStatus(ctx context.Context, cfg *config.Config) error {
+	retries, err := backup.OpenRetryQueue(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	logger.Prompt("Targets:")
+	if len(cfg.SyncTargets) == 0 {
+		logger.Prompt("  (none configured)")
+	}
+	known := make(map[string]bool)
+	for i, t := range cfg.SyncTargets {
+		role := "backup"
+		if i == 0 {
+			role = "primary"
+		}
+		logger.Prompt("  [%d] %s (%s)", i+1, t.Original, role)
+		if e, behind := retries.Lookup(t); behind {
+			printBehind(e)
+			known[e.Target] = true
+		} else {
+			logger.Prompt("      up to date")
+		}
+		if t.Slots != nil {
+			printSlotMap(t.Slots)
+		}
+		if t.Encryption != nil {
+			printEncryption(ctx, cfg, t)
+		}
+		if t.Type == config.Remote {
+			printBackend(ctx, cfg, t)
+			printMirror(cfg, t)
+		}
+	}
+
+	for _, e := range retries.Entries() {
+		if known[e.Target] {
+			continue
+		}
+		logger.Prompt("  %s (not configured for this run)", e.Target)
+		printBehind(e)
+	}
+	return nil
+}
+
+// printSlotMap lists which live slot each slot of the target holds.
+func printSlotMap(m config.SlotMap) {
+	var pairs []string
+	for _, live := range sortedKeys(m) {
+		pairs = append(pairs, fmt.Sprintf("%d → %d", live, m[live]))
+	}
+	logger.Prompt("      slots (live → target): %s", strings.Join(pairs, ", "))
+}
+
+func printEncryption(ctx context.Context, cfg *config.Config, t config.SyncTarget) {
+	if err := backup.CheckEncryption(ctx, cfg, t); err != nil {
+		logger.Prompt("      encrypted: %v", err)
+		return
+	}
+	logger.Prompt("      encrypted: key OK")
+}
+
+func printBackend(ctx context.Context, cfg *config.Config, t config.SyncTarget) {
+	info, err := backup.GetRemoteInfo(ctx, cfg, t.RemoteName)
+	if err != nil {
+		logger.Prompt("      backend: %v", err)
+		return
+	}
+	modTimes := "not kept, manifest times are used"
+	if info.HasModTime() {
+		modTimes = "kept to " + info.Precision.String()
+	}
+	hashes := "none"
+	if len(info.Hashes) > 0 {
+		hashes = strings.Join(info.Hashes, ", ")
+	}
+	logger.Prompt("      backend: %s", info.Type)
+	logger.Prompt("      modification times: %s", modTimes)
+	if info.HasHash("sha256") {
+		logger.Prompt("      hashes: %s", hashes)
+	} else {
+		logger.Prompt("      hashes: %s (verify downloads files)", hashes)
+	}
+	if info.EventuallyConsistent() {
+		logger.Prompt("      listings may lag behind writes; mismatches are rechecked")
+	}
+}
+
+func printMirror(cfg *config.Config, t config.SyncTarget) {
+	info, ok := backup.LoadMirror(cfg, t)
+	if !ok {
+		logger.Prompt("      offline cache: none yet")
+		return
+	}
+	logger.Prompt("      offline cache: updated %s", info.UpdatedAt.Format(time.DateTime))
+	if info.Provisional {
+		logger.Prompt("      PROVISIONAL: holds offline progress not yet uploaded")
+	}
+}
+
+func printBehind(e backup.RetryEntry) {
+	logger.Prompt("      BEHIND since %s, %d failed attempt(s)", e.Since.Format(time.DateTime), e.Attempts)
+	logger.Prompt("      next retry: %s", e.NextAttempt.Format(time.DateTime))
+	logger.Prompt("      last error: %s", e.LastError)
+}
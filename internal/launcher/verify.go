@@ -0,0 +1,65 @@
+// /internal/launcher/verify.go
+package launcher
+
+import (
+	"context"
+	"errors"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+)
+
+// RunVerify checks one target, or every configured target, against its
+// integrity manifest and fails if any of them is damaged.
+func RunVerify(ctx context.Context, cfg *config.Config) error {
+	var targets []config.SyncTarget
+	switch len(cfg.Args) {
+	case 0:
+		targets = cfg.SyncTargets
+	case 1:
+		target, err := resolveTarget(cfg, cfg.Args[0])
+		if err != nil {
+			return err
+		}
+		targets = []config.SyncTarget{target}
+	default:
+		return apperr.New(apperr.ConfigInvalid, "usage: verify [target]")
+	}
+	if len(targets) == 0 {
+		return apperr.New(apperr.ConfigInvalid, "no targets to verify")
+	}
+
+	damaged := 0
+	for _, t := range targets {
+		logger.Prompt("%s:", t.Original)
+		report, err := backup.VerifyTarget(ctx, cfg, t)
+		if errors.Is(err, backup.ErrNoManifest) {
+			logger.Prompt("  not verified: %v", err)
+			continue
+		}
+		if err != nil {
+			logger.Prompt("  could not verify: %v", err)
+			damaged++
+			continue
+		}
+		for _, name := range report.Missing {
+			logger.Prompt("  MISSING     %s", name)
+		}
+		for _, name := range report.Mismatched {
+			logger.Prompt("  MISMATCHED  %s", name)
+		}
+		for _, name := range report.Extra {
+			logger.Prompt("  extra       %s", name)
+		}
+		if report.Damaged() {
+			damaged++
+			logger.Prompt("  DAMAGED: %d of %d file(s) checked are missing or altered", len(report.Missing)+len(report.Mismatched), report.Checked)
+		} else {
+			logger.Prompt("  OK: %d file(s) checked", report.Checked)
+		}
+	}
+	if damaged > 0 {
+		return apperr.Errorf(apperr.VerifyFailed, "%d target(s) failed verification", damaged)
+	}
+	return nil
+}
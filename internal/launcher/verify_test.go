@@ -0,0 +1,34 @@
+// /internal/launcher/verify_test.go
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/apperr"
+	"pirated-hollow-knight/internal/backup"
+	"pirated-hollow-knight/internal/config"
+	"testing"
+)
+
+func TestRunVerify(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	liveDir := filepath.Join(dir, "live")
+	writeSlots(t, liveDir, map[int]testSlot{1: {playTime: 10}, 2: {playTime: 20}})
+	mirror := filepath.Join(dir, "mirror")
+	cfg := &config.Config{SyncTargets: []config.SyncTarget{config.ParseTarget(mirror)}}
+	if err := backup.Sync(ctx, cfg, config.SyncTarget{Type: config.Local, Path: liveDir}, cfg.SyncTargets[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunVerify(ctx, cfg); err != nil {
+		t.Fatalf("RunVerify on an intact target: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(mirror, "user1.dat"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunVerify(ctx, cfg); apperr.KindOf(err) != apperr.VerifyFailed {
+		t.Errorf("RunVerify on a damaged target = %v, want a VerifyFailed error", err)
+	}
+}
@@ -0,0 +1,84 @@
+// /internal/log/handler.go
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// consoleHandler writes errors to stderr and everything else to stdout. In
+// text format, lines look like "[WARN] message", followed by any attributes
+// other than the subsystem.
+type consoleHandler struct {
+	level  slog.Level
+	format string
+	mu     sync.Mutex
+	out    slog.Handler
+	errOut slog.Handler
+}
+
+func newConsoleHandler(format string, level slog.Level) *consoleHandler {
+	h := &consoleHandler{level: level, format: format}
+	if format == FormatJSON {
+		// The level is checked by Enabled, so forced messages get through.
+		opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+		h.out = slog.NewJSONHandler(os.Stdout, opts)
+		h.errOut = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	return h
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *consoleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.format == FormatJSON {
+		if r.Level >= slog.LevelError {
+			return h.errOut.Handle(ctx, r)
+		}
+		return h.out.Handle(ctx, r)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", levelName(r.Level), r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != "subsystem" {
+			fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		}
+		return true
+	})
+	b.WriteByte('\n')
+
+	var w io.Writer = os.Stdout
+	if r.Level >= slog.LevelError {
+		w = os.Stderr
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Records are built by Logger with their attributes already attached, so
+// the console handler is never derived.
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+func (h *consoleHandler) WithGroup(name string) slog.Handler       { return h }
+
+func levelName(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "ERROR"
+	case level >= slog.LevelWarn:
+		return "WARN"
+	case level >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
@@ -2,79 +2,198 @@
 package log
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
-type LogLevel int
+// levelQuiet disables console output; only prompts and critical errors are shown.
+const levelQuiet = slog.Level(100)
 
+// Output formats for Options.Format.
 const (
-	levelInfo LogLevel = iota
-	levelWarn
-	levelError
-	levelQuiet
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
+// Options configures the console output and the log file.
+type Options struct {
+	// Level is the console level: debug, info, warn, error or quiet.
+	Level string
+	// Format is the format of both the console and the log file: text or json.
+	Format string
+	// File is the log file. It is always written at info level, whatever the
+	// console level is, and rotated when it grows too large. Empty disables it.
+	File string
+}
+
 var (
-	infoLogger   *log.Logger
-	warnLogger   *log.Logger
-	errorLogger  *log.Logger
-	promptLogger *log.Logger
+	mu      sync.RWMutex
+	console slog.Handler = newConsoleHandler(FormatText, levelQuiet)
+	file    slog.Handler
+	fileOut *rotatingFile
+
+	printMu     sync.Mutex
+	beforePrint func()
 )
 
+// Logger writes printf-style messages to the console and the log file. A
+// logger created with For tags every message with its subsystem.
 type Logger struct {
-	level LogLevel
+	subsystem string
+}
+
+// Log is the logger for messages that don't belong to a subsystem.
+var Log = &Logger{}
+
+// For returns the logger of a subsystem, e.g. "backup". It can be created
+// before Init; output goes wherever Init directs it.
+func For(subsystem string) *Logger {
+	return &Logger{subsystem: subsystem}
 }
 
-var Log *Logger
+// Init sets up the console and the log file.
+func Init(opts Options) error {
+	level, err := parseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	if opts.Format != FormatText && opts.Format != FormatJSON {
+		return fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	var out *rotatingFile
+	var fileHandler slog.Handler
+	if opts.File != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.File), 0755); err != nil {
+			return fmt.Errorf("could not create log directory: %w", err)
+		}
+		if out, err = openRotatingFile(opts.File, maxLogSize, maxLogBackups); err != nil {
+			return fmt.Errorf("could not open log file: %w", err)
+		}
+		handlerOpts := &slog.HandlerOptions{Level: slog.LevelInfo}
+		if opts.Format == FormatJSON {
+			fileHandler = slog.NewJSONHandler(out, handlerOpts)
+		} else {
+			fileHandler = slog.NewTextHandler(out, handlerOpts)
+		}
+	}
 
-func Init(levelStr string) {
-	infoLogger = log.New(os.Stdout, "[INFO] ", 0)
-	warnLogger = log.New(os.Stdout, "[WARN] ", 0)
-	errorLogger = log.New(os.Stderr, "[ERROR] ", 0)
-	promptLogger = log.New(os.Stdout, "", 0)
+	mu.Lock()
+	defer mu.Unlock()
+	if fileOut != nil {
+		_ = fileOut.Close()
+	}
+	console = newConsoleHandler(opts.Format, level)
+	file, fileOut = fileHandler, out
+	return nil
+}
 
-	Log = &Logger{}
-	Log.setLevelFromString(levelStr)
+// Close flushes and closes the log file.
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	if fileOut != nil {
+		_ = fileOut.Close()
+		file, fileOut = nil, nil
+	}
 }
 
-func (l *Logger) setLevelFromString(levelStr string) {
-	switch strings.ToLower(levelStr) {
+func parseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
 	case "info":
-		l.level = levelInfo
+		return slog.LevelInfo, nil
 	case "warn":
-		l.level = levelWarn
+		return slog.LevelWarn, nil
 	case "error":
-		l.level = levelError
-	default:
-		l.level = levelQuiet
+		return slog.LevelError, nil
+	case "quiet", "":
+		return levelQuiet, nil
 	}
+	return 0, fmt.Errorf("unknown log level %q", s)
 }
 
-func (l *Logger) Info(format string, v ...interface{}) {
-	if l.level <= levelInfo {
-		infoLogger.Printf(format, v...)
+// SetBeforePrint registers a function that runs before every log line, so
+// that anything drawn on the terminal can get out of the way first.
+func SetBeforePrint(fn func()) {
+	printMu.Lock()
+	defer printMu.Unlock()
+	beforePrint = fn
+}
+
+func prepare() {
+	printMu.Lock()
+	fn := beforePrint
+	printMu.Unlock()
+	if fn != nil {
+		fn()
 	}
 }
 
-func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.level <= levelWarn {
-		warnLogger.Printf(format, v...)
+// log writes a message to every handler that accepts its level. Forced
+// messages are shown on the console even if its level would hide them.
+func (l *Logger) log(level slog.Level, force bool, format string, v ...any) {
+	ctx := context.Background()
+	mu.RLock()
+	defer mu.RUnlock()
+	toConsole := force || console.Enabled(ctx, level)
+	toFile := file != nil && file.Enabled(ctx, level)
+	if !toConsole && !toFile {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, v...), pcs[0])
+	if l.subsystem != "" {
+		r.AddAttrs(slog.String("subsystem", l.subsystem))
+	}
+	if toConsole {
+		prepare()
+		_ = console.Handle(ctx, r)
 	}
+	if toFile {
+		_ = file.Handle(ctx, r)
+	}
+}
+
+func (l *Logger) Debug(format string, v ...interface{}) {
+	l.log(slog.LevelDebug, false, format, v...)
+}
+
+func (l *Logger) Info(format string, v ...interface{}) {
+	l.log(slog.LevelInfo, false, format, v...)
+}
+
+func (l *Logger) Warn(format string, v ...interface{}) {
+	l.log(slog.LevelWarn, false, format, v...)
 }
 
 func (l *Logger) Error(format string, v ...interface{}) {
-	if l.level <= levelError {
-		errorLogger.Printf(format, v...)
-	}
+	l.log(slog.LevelError, false, format, v...)
 }
 
-func (l *Logger) Fatal(format string, v ...interface{}) {
-	errorLogger.Printf(format, v...)
-	os.Exit(1)
+// Critical logs the error that ends the run. It is shown on the console
+// whatever the level, since it explains the exit code.
+func (l *Logger) Critical(format string, v ...interface{}) {
+	l.log(slog.LevelError, true, format, v...)
 }
 
+// Prompt prints a line of command output or an interactive question. Prompts
+// are always shown, never decorated and never written to the log file.
 func (l *Logger) Prompt(format string, v ...interface{}) {
-	promptLogger.Printf(format, v...)
+	msg := fmt.Sprintf(format, v...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	prepare()
+	fmt.Fprint(os.Stdout, msg)
 }
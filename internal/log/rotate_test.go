@@ -0,0 +1,131 @@
+// /internal/log/rotate_test.go
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readLogs returns the contents of the log file and each backup, by name.
+func readLogs(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := make(map[string]string)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs[e.Name()] = string(data)
+	}
+	return logs
+}
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		writes  []string
+		existed string
+		want    map[string]string
+	}{
+		{
+			name:   "under the limit",
+			writes: []string{"aaaa\n", "bbbb\n"},
+			want:   map[string]string{"app.log": "aaaa\nbbbb\n"},
+		},
+		{
+			name:   "rotates past the limit",
+			writes: []string{"aaaa\n", "bbbb\n", "cccc\n"},
+			want:   map[string]string{"app.log": "cccc\n", "app.log.1": "aaaa\nbbbb\n"},
+		},
+		{
+			name:   "keeps only the newest backups",
+			writes: []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"},
+			want:   map[string]string{"app.log": "dddddddd\n", "app.log.1": "cccccccc\n", "app.log.2": "bbbbbbbb\n"},
+		},
+		{
+			name:    "appends to an existing file",
+			existed: "old\n",
+			writes:  []string{"aaaa\n", "bbbb\n"},
+			want:    map[string]string{"app.log": "bbbb\n", "app.log.1": "old\naaaa\n"},
+		},
+		{
+			name:   "a write larger than the limit goes into a file of its own",
+			writes: []string{"aaaa\n", "bbbbbbbbbbbbbbbb\n", "cccc\n"},
+			want:   map[string]string{"app.log": "cccc\n", "app.log.1": "bbbbbbbbbbbbbbbb\n", "app.log.2": "aaaa\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			if tt.existed != "" {
+				if err := os.WriteFile(path, []byte(tt.existed), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r, err := openRotatingFile(path, 10, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.writes {
+				if _, err := r.Write([]byte(w)); err != nil {
+					t.Fatalf("Write(%q): %v", w, err)
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readLogs(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("logs = %q, want %q", got, tt.want)
+			}
+			if _, err := r.Write([]byte("late\n")); err != os.ErrClosed {
+				t.Errorf("Write after Close = %v, want os.ErrClosed", err)
+			}
+		})
+	}
+}
+
+func TestRotatingFileKeepsLoggingWhenRotationFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	r, err := openRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// A directory in the way of the backup makes renaming the log fail.
+	blocker := path + ".1"
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if _, err := fmt.Fprintf(r, "line %d...\n", i); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	if got, want := readLogs(t, dir), map[string]string{"app.log": "line 0...\nline 1...\nline 2...\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("logs while rotation fails = %q, want %q", got, want)
+	}
+
+	// Once the way is clear, the next write rotates.
+	if err := os.RemoveAll(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fmt.Fprint(r, "line 3...\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readLogs(t, dir), map[string]string{"app.log": "line 3...\n", "app.log.1": "line 0...\nline 1...\nline 2...\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("logs after rotating = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+// /internal/notify/notify.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"pirated-hollow-knight/internal/log"
+	"strings"
+	"time"
+)
+
+// ExecPrefix marks a sink that is a command rather than a webhook URL.
+const ExecPrefix = "exec:"
+
+// sendTimeout bounds each delivery, so a dead sink can't hold up the exit.
+const sendTimeout = 15 * time.Second
+
+// logger tags the messages of this package with its subsystem.
+var logger = log.For("notify")
+
+// Send delivers a report as JSON to every sink. A sink is either an http(s)
+// webhook, which receives it as a POST body, or "exec:command args", which
+// receives it on stdin. Failures are logged, never returned.
+func Send(ctx context.Context, sinks []string, report any) {
+	if len(sinks) == 0 {
+		return
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		logger.Error("Could not encode the session report: %v", err)
+		return
+	}
+	for _, sink := range sinks {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		if command, ok := strings.CutPrefix(sink, ExecPrefix); ok {
+			err = runCommand(sendCtx, command, body)
+		} else {
+			err = postWebhook(sendCtx, sink, body)
+		}
+		cancel()
+		if err != nil {
+			logger.Warn("Could not send the session report to '%s': %v", sink, err)
+			continue
+		}
+		logger.Info("Sent the session report to '%s'.", sink)
+	}
+}
+
+// Validate checks that a sink is a webhook URL or a command.
+func Validate(sink string) error {
+	if command, ok := strings.CutPrefix(sink, ExecPrefix); ok {
+		if strings.TrimSpace(command) == "" {
+			return fmt.Errorf("notification sink %q has no command", sink)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(sink, "http://") && !strings.HasPrefix(sink, "https://") {
+		return fmt.Errorf("notification sink %q must be an http(s) URL or start with %q", sink, ExecPrefix)
+	}
+	return nil
+}
+
+func postWebhook(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return nil
+}
+
+func runCommand(ctx context.Context, command string, body []byte) error {
+	args := strings.Fields(command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
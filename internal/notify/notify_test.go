@@ -0,0 +1,75 @@
+// /internal/notify/notify_test.go
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pirated-hollow-knight/internal/log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testReport struct {
+	Profile string   `json:"profile"`
+	Charms  []string `json:"charms"`
+}
+
+func TestSendWebhook(t *testing.T) {
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		received <- body
+	}))
+	defer srv.Close()
+
+	report := testReport{Profile: "main", Charms: []string{"Wayward Compass"}}
+	Send(context.Background(), []string{srv.URL}, report)
+	select {
+	case body := <-received:
+		var got testReport
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("webhook body %q is not JSON: %v", body, err)
+		}
+		if !reflect.DeepEqual(got, report) {
+			t.Errorf("webhook received %+v, want %+v", got, report)
+		}
+	default:
+		t.Fatal("the webhook received nothing")
+	}
+}
+
+func TestSendLogsFailures(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "launcher.log")
+	if err := log.Init(log.Options{Level: "quiet", Format: log.FormatText, File: logFile}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = log.Init(log.Options{Level: "quiet", Format: log.FormatText}) })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// Send has no error to return; the failure only shows up in the log.
+	Send(context.Background(), []string{srv.URL}, testReport{Profile: "main"})
+	log.Close()
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged := string(data); !strings.Contains(logged, "WARN") || !strings.Contains(logged, srv.URL) || !strings.Contains(logged, "503") {
+		t.Errorf("log = %q, want a warning naming the sink and its status", logged)
+	}
+}
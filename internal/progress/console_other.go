@@ -0,0 +1,12 @@
+// /internal/progress/console_other.go
+//go:build !windows
+
+package progress
+
+import "os"
+
+// enableVT reports whether f understands escape sequences, which terminals
+// outside Windows always do.
+func enableVT(f *os.File) bool {
+	return true
+}
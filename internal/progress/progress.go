@@ -0,0 +1,219 @@
+// /internal/progress/progress.go
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"pirated-hollow-knight/internal/log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Output modes for Init.
+const (
+	ModeAuto = "auto"
+	ModeBar  = "bar"
+	ModeJSON = "json"
+	ModeNone = "none"
+)
+
+// updateInterval limits how often a transfer reports intermediate progress.
+const updateInterval = 200 * time.Millisecond
+
+// Event is one progress update of a transfer to or from a target.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Target     string    `json:"target"`
+	Bytes      int64     `json:"bytes"`
+	TotalBytes int64     `json:"total_bytes"`
+	Files      int64     `json:"files"`
+	TotalFiles int64     `json:"total_files"`
+	// Rate is in bytes per second.
+	Rate float64 `json:"rate"`
+	// ETA is the estimated number of seconds left, or -1 if unknown.
+	ETA   float64 `json:"eta"`
+	Done  bool    `json:"done"`
+	Error string  `json:"error,omitempty"`
+}
+
+type renderer interface {
+	render(ev Event)
+	clear()
+}
+
+var (
+	mu     sync.Mutex
+	active renderer
+)
+
+// Init selects how progress is shown. Bars are drawn on stderr, and only if it
+// is a terminal; JSON lines are written to out. In auto mode, bars are shown
+// unless the log level is quiet.
+func Init(mode, logLevel string, out io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if mode == ModeAuto {
+		mode = ModeNone
+		if logLevel != "quiet" {
+			mode = ModeBar
+		}
+	}
+	switch mode {
+	case ModeBar:
+		// Without escape sequences the bars can't be redrawn, so none are shown.
+		if term.IsTerminal(int(os.Stderr.Fd())) && enableVT(os.Stderr) {
+			bars := &barRenderer{out: os.Stderr, lines: make(map[string]Event)}
+			active = bars
+			// Log lines would otherwise be printed into the middle of the bars.
+			log.SetBeforePrint(func() {
+				mu.Lock()
+				defer mu.Unlock()
+				bars.clear()
+			})
+		}
+	case ModeJSON:
+		active = &jsonRenderer{enc: json.NewEncoder(out)}
+	}
+}
+
+// Report passes an event to the configured renderer.
+func Report(ev Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if active != nil {
+		active.render(ev)
+	}
+}
+
+// Tracker turns running totals of a transfer into events, adding rate and ETA.
+type Tracker struct {
+	target string
+	start  time.Time
+	last   time.Time
+	ev     Event
+}
+
+// Start begins tracking a transfer for target.
+func Start(target string) *Tracker {
+	return &Tracker{target: target, start: time.Now()}
+}
+
+// Update records the transfer's current totals. Updates are reported at most
+// every updateInterval.
+func (t *Tracker) Update(bytes, totalBytes, files, totalFiles int64) {
+	now := time.Now()
+	t.ev = Event{
+		Target:     t.target,
+		Bytes:      bytes,
+		TotalBytes: totalBytes,
+		Files:      files,
+		TotalFiles: totalFiles,
+		ETA:        -1,
+	}
+	if elapsed := now.Sub(t.start).Seconds(); elapsed > 0 {
+		t.ev.Rate = float64(bytes) / elapsed
+	}
+	if t.ev.Rate > 0 && totalBytes >= bytes {
+		t.ev.ETA = float64(totalBytes-bytes) / t.ev.Rate
+	}
+	if now.Sub(t.last) < updateInterval {
+		return
+	}
+	t.last = now
+	t.ev.Time = now
+	Report(t.ev)
+}
+
+// Finish reports the end of the transfer.
+func (t *Tracker) Finish(err error) {
+	ev := t.ev
+	ev.Target = t.target
+	ev.Time = time.Now()
+	ev.Done = true
+	ev.ETA = 0
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	Report(ev)
+}
+
+type jsonRenderer struct {
+	enc *json.Encoder
+}
+
+func (r *jsonRenderer) render(ev Event) {
+	_ = r.enc.Encode(ev)
+}
+
+func (r *jsonRenderer) clear() {}
+
+// barRenderer draws one line per running transfer and redraws them in place.
+type barRenderer struct {
+	out   io.Writer
+	lines map[string]Event
+	drawn int
+}
+
+func (r *barRenderer) render(ev Event) {
+	if ev.Done {
+		delete(r.lines, ev.Target)
+	} else {
+		r.lines[ev.Target] = ev
+	}
+	r.clear()
+	targets := make([]string, 0, len(r.lines))
+	for target := range r.lines {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	var b strings.Builder
+	for _, target := range targets {
+		b.WriteString(formatBar(r.lines[target]))
+		b.WriteByte('\n')
+	}
+	fmt.Fprint(r.out, b.String())
+	r.drawn = len(targets)
+}
+
+func (r *barRenderer) clear() {
+	if r.drawn > 0 {
+		fmt.Fprintf(r.out, "\033[%dA\033[J", r.drawn)
+		r.drawn = 0
+	}
+}
+
+const barWidth = 24
+
+func formatBar(ev Event) string {
+	fraction := 0.0
+	if ev.TotalBytes > 0 {
+		fraction = min(float64(ev.Bytes)/float64(ev.TotalBytes), 1)
+	}
+	filled := int(fraction * barWidth)
+	eta := "-"
+	if ev.ETA >= 0 {
+		eta = time.Duration(ev.ETA * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("%s [%s%s] %3.0f%% %s/%s %d/%d file(s) %s/s ETA %s",
+		ev.Target, strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), fraction*100,
+		formatBytes(ev.Bytes), formatBytes(ev.TotalBytes), ev.Files, ev.TotalFiles, formatBytes(int64(ev.Rate)), eta)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
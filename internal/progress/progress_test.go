@@ -0,0 +1,71 @@
+// /internal/progress/progress_test.go
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	var out bytes.Buffer
+	Init(ModeJSON, "info", &out)
+	t.Cleanup(func() {
+		mu.Lock()
+		active = nil
+		mu.Unlock()
+	})
+	events := func() []Event {
+		t.Helper()
+		var evs []Event
+		dec := json.NewDecoder(&out)
+		for dec.More() {
+			var ev Event
+			if err := dec.Decode(&ev); err != nil {
+				t.Fatal(err)
+			}
+			evs = append(evs, ev)
+		}
+		return evs
+	}
+
+	tr := Start("gdrive:HK")
+	tr.start = time.Now().Add(-2 * time.Second)
+	tr.Update(100, 300, 1, 3)
+	evs := events()
+	if len(evs) != 1 {
+		t.Fatalf("first update reported %d events, want 1", len(evs))
+	}
+	ev := evs[0]
+	if ev.Target != "gdrive:HK" || ev.Bytes != 100 || ev.TotalBytes != 300 || ev.Files != 1 || ev.TotalFiles != 3 || ev.Done {
+		t.Errorf("first update = %+v", ev)
+	}
+	if math.Abs(ev.Rate-50) > 5 || math.Abs(ev.ETA-4) > 0.5 {
+		t.Errorf("rate %.1f B/s, ETA %.1fs, want about 50 B/s and 4s", ev.Rate, ev.ETA)
+	}
+
+	tr.Update(150, 300, 1, 3)
+	if evs := events(); len(evs) != 0 {
+		t.Errorf("update within %s reported %d events, want none", updateInterval, len(evs))
+	}
+	tr.last = time.Now().Add(-updateInterval)
+	tr.Update(200, 300, 2, 3)
+	if evs := events(); len(evs) != 1 || evs[0].Bytes != 200 {
+		t.Errorf("update after %s reported %+v, want one event at 200 bytes", updateInterval, evs)
+	}
+
+	tr.Finish(errors.New("connection reset"))
+	evs = events()
+	if len(evs) != 1 || !evs[0].Done || evs[0].ETA != 0 || evs[0].Error != "connection reset" || evs[0].Bytes != 200 {
+		t.Errorf("Finish reported %+v, want one final event with the error", evs)
+	}
+
+	unknown := Start("local")
+	unknown.Update(0, 0, 0, 0)
+	if evs := events(); len(evs) != 1 || evs[0].ETA != -1 {
+		t.Errorf("update without progress reported %+v, want an unknown ETA", evs)
+	}
+}
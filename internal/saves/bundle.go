@@ -0,0 +1,234 @@
+// /internal/saves/bundle.go
+package saves
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// BundleExt is the file extension of save bundles.
+	BundleExt = ".hkbundle"
+	// bundleVersion is the bundle format version this launcher writes and reads.
+	bundleVersion    = 1
+	bundleManifest   = "manifest.json"
+	bundleFilesDir   = "files/"
+	maxBundleFileLen = 64 << 20
+)
+
+// BundleManifest describes the contents of a save bundle.
+type BundleManifest struct {
+	FormatVersion int          `json:"format_version"`
+	Created       time.Time    `json:"created"`
+	Source        string       `json:"source"`
+	Slot          int          `json:"slot"`
+	GameVersion   string       `json:"game_version"`
+	Summary       SlotSummary  `json:"summary"`
+	Files         []BundleFile `json:"files"`
+}
+
+// SlotSummary is the decoded state of a slot, for telling bundles apart.
+type SlotSummary struct {
+	PlayTime   float64 `json:"play_time"`
+	Completion float64 `json:"completion"`
+	Geo        int     `json:"geo"`
+	Masks      int     `json:"masks"`
+	Vessels    int     `json:"vessels"`
+	Charms     int     `json:"charms"`
+}
+
+// BundleFile is one slot file in a bundle.
+type BundleFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Bundle is a validated save bundle with the contents of its files.
+type Bundle struct {
+	Manifest BundleManifest
+	Files    map[string][]byte
+}
+
+// Summarise returns the summary of a decoded save.
+func Summarise(save *Save) SlotSummary {
+	p := save.PlayerData
+	summary := SlotSummary{
+		PlayTime:   p.PlayTime,
+		Completion: p.CompletionPercentage,
+		Geo:        p.Geo,
+		Masks:      p.MaxHealthBase,
+		Vessels:    p.MPReserveMax / soulPerVessel,
+	}
+	for i := range charmNames {
+		if save.flags["gotCharm_"+strconv.Itoa(i+1)] {
+			summary.Charms++
+		}
+	}
+	return summary
+}
+
+// WriteBundle writes the files of slot in dir as a bundle to destPath.
+func WriteBundle(destPath, dir string, slot int, source string) (*BundleManifest, error) {
+	save, err := Load(filepath.Join(dir, SlotFileName(slot)))
+	if err != nil {
+		return nil, err
+	}
+	names, err := SlotFiles(dir, slot)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	manifest := &BundleManifest{
+		FormatVersion: bundleVersion,
+		Created:       time.Now().UTC(),
+		Source:        source,
+		Slot:          slot,
+		GameVersion:   save.PlayerData.Version,
+		Summary:       Summarise(save),
+	}
+	contents := make(map[string][]byte)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, BundleFile{Name: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+		contents[name] = data
+	}
+
+	tmpPath := destPath + ".tmp"
+	if err := writeBundleArchive(tmpPath, manifest, contents); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeBundleArchive(path string, manifest *BundleManifest, contents map[string][]byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	add := func(name string, data []byte) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = add(bundleManifest, data)
+	}
+	for _, file := range manifest.Files {
+		if err != nil {
+			break
+		}
+		err = add(bundleFilesDir+file.Name, contents[file.Name])
+	}
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ReadBundle reads a bundle and checks it: the manifest must be of a known
+// version, every file must be listed, belong to the bundle's slot and match
+// its hash, and the slot's main save must decode.
+func ReadBundle(bundlePath string) (*Bundle, error) {
+	zr, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("not a save bundle: %w", err)
+	}
+	defer zr.Close()
+
+	entries := make(map[string][]byte)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.UncompressedSize64 > maxBundleFileLen {
+			return nil, fmt.Errorf("bundle entry '%s' is too large", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxBundleFileLen+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read bundle entry '%s': %w", f.Name, err)
+		}
+		entries[f.Name] = data
+	}
+
+	data, ok := entries[bundleManifest]
+	if !ok {
+		return nil, errors.New("not a save bundle: no manifest")
+	}
+	b := &Bundle{Files: make(map[string][]byte)}
+	if err := json.Unmarshal(data, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	m := b.Manifest
+	if m.FormatVersion != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d", m.FormatVersion)
+	}
+	for _, file := range m.Files {
+		// Names come from a file that may have been crafted; keep them to
+		// plain slot file names so they can't escape the save directory.
+		if slot, ok := SlotOf(file.Name); !ok || slot != m.Slot || strings.ContainsAny(file.Name, `/\:`) {
+			return nil, fmt.Errorf("bundle file '%s' is not a file of slot %d", file.Name, m.Slot)
+		}
+		content, ok := entries[bundleFilesDir+file.Name]
+		if !ok {
+			return nil, fmt.Errorf("bundle file '%s' is missing", file.Name)
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != file.SHA256 || int64(len(content)) != file.Size {
+			return nil, fmt.Errorf("bundle file '%s' does not match its hash", file.Name)
+		}
+		b.Files[file.Name] = content
+	}
+	for name := range entries {
+		if _, listed := b.Files[strings.TrimPrefix(name, bundleFilesDir)]; name != bundleManifest && !listed {
+			return nil, fmt.Errorf("bundle entry '%s' is not in the manifest", name)
+		}
+	}
+	main, ok := b.Files[SlotFileName(m.Slot)]
+	if !ok {
+		return nil, fmt.Errorf("bundle has no %s", SlotFileName(m.Slot))
+	}
+	if _, err := Decode(main); err != nil {
+		return nil, fmt.Errorf("bundle save does not decode: %w", err)
+	}
+	return b, nil
+}
+
+// RenumberFile renames a slot file to the same file of another slot, e.g.
+// "user1.dat.bak1" to "user3.dat.bak1".
+func RenumberFile(name string, from, to int) string {
+	return "user" + strconv.Itoa(to) + strings.TrimPrefix(name, "user"+strconv.Itoa(from))
+}
@@ -0,0 +1,172 @@
+// /internal/saves/bundle_test.go
+package saves
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestBundle writes a bundle of slot 2 and returns its path, its
+// manifest and the contents of its files.
+func writeTestBundle(t *testing.T) (string, *BundleManifest, map[string][]byte) {
+	t.Helper()
+	dir := t.TempDir()
+	save, err := EncodeJSON([]byte(testDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"user2.dat":      save,
+		"user2.dat.bak1": save,
+		"user1.dat":      save,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "slot2"+BundleExt)
+	manifest, err := WriteBundle(path, dir, 2, "test")
+	if err != nil {
+		t.Fatalf("WriteBundle: %v", err)
+	}
+	delete(files, "user1.dat")
+	return path, manifest, files
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	path, manifest, files := writeTestBundle(t)
+	if len(manifest.Files) != 2 || manifest.Slot != 2 || manifest.GameVersion != "1.5.78.11833" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	b, err := ReadBundle(path)
+	if err != nil {
+		t.Fatalf("ReadBundle: %v", err)
+	}
+	if len(b.Files) != len(files) {
+		t.Errorf("bundle has %d files, want %d", len(b.Files), len(files))
+	}
+	for name, data := range files {
+		if string(b.Files[name]) != string(data) {
+			t.Errorf("%s does not round trip", name)
+		}
+	}
+	if b.Manifest.Summary.Charms != 1 {
+		t.Errorf("summary = %+v", b.Manifest.Summary)
+	}
+}
+
+func TestReadBundleRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(m *BundleManifest, contents map[string][]byte)
+		wantErr string
+	}{
+		{
+			name: "tampered file",
+			tamper: func(m *BundleManifest, contents map[string][]byte) {
+				contents["user2.dat.bak1"] = append([]byte{}, contents["user2.dat.bak1"]...)
+				contents["user2.dat.bak1"][30] ^= 1
+			},
+			wantErr: "does not match its hash",
+		},
+		{
+			name: "path escaping the save directory",
+			tamper: func(m *BundleManifest, contents map[string][]byte) {
+				addFile(m, contents, "../user2.dat", []byte("x"))
+			},
+			wantErr: "is not a file of slot 2",
+		},
+		{
+			name: "file of another slot",
+			tamper: func(m *BundleManifest, contents map[string][]byte) {
+				addFile(m, contents, "user3.dat", contents["user2.dat"])
+			},
+			wantErr: "is not a file of slot 2",
+		},
+		{
+			name: "unknown version",
+			tamper: func(m *BundleManifest, contents map[string][]byte) {
+				m.FormatVersion = bundleVersion + 1
+			},
+			wantErr: "unsupported bundle format version",
+		},
+		{
+			name: "save that does not decode",
+			tamper: func(m *BundleManifest, contents map[string][]byte) {
+				m.Files = nil
+				addFile(m, contents, "user2.dat", []byte("not a save"))
+			},
+			wantErr: "does not decode",
+		},
+		{
+			name: "no main save",
+			tamper: func(m *BundleManifest, contents map[string][]byte) {
+				m.Files = m.Files[1:]
+				delete(contents, "user2.dat")
+			},
+			wantErr: "has no user2.dat",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, manifest, contents := writeTestBundle(t)
+			tt.tamper(manifest, contents)
+			if err := writeBundleArchive(path, manifest, contents); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadBundle(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadBundle error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadBundleUnlistedEntry(t *testing.T) {
+	path, manifest, contents := writeTestBundle(t)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string][]byte{bundleManifest: data, bundleFilesDir + "user2.dat.bak2": []byte("extra")}
+	for name, data := range contents {
+		entries[bundleFilesDir+name] = data
+	}
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBundle(path); err == nil || !strings.Contains(err.Error(), "not in the manifest") {
+		t.Errorf("ReadBundle error = %v, want an unlisted entry error", err)
+	}
+}
+
+// addFile lists a file in the manifest, with its hash, and adds its contents.
+func addFile(m *BundleManifest, contents map[string][]byte, name string, data []byte) {
+	sum := sha256.Sum256(data)
+	m.Files = append(m.Files, BundleFile{Name: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+	contents[name] = data
+}
@@ -0,0 +1,175 @@
+// /internal/saves/diff.go
+package saves
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Sections of a save diff, in the order they are shown.
+const (
+	SectionInventory = "inventory"
+	SectionCharms    = "charms"
+	SectionBosses    = "bosses"
+	SectionMap       = "map"
+	SectionPlayer    = "player"
+	SectionScenes    = "scene data"
+)
+
+// Sections lists every section in display order.
+var Sections = []string{SectionInventory, SectionCharms, SectionBosses, SectionMap, SectionPlayer, SectionScenes}
+
+// inventoryFields are the PlayerData fields, other than "has*" flags, that
+// hold items and upgrades.
+var inventoryFields = map[string]bool{
+	"geo": true, "ore": true, "simpleKeys": true, "rancidEggs": true, "dreamOrbs": true,
+	"nailSmithUpgrades": true, "nailDamage": true, "maxHealthBase": true, "MPReserveMax": true,
+	"trinket1": true, "trinket2": true, "trinket3": true, "trinket4": true,
+	"grubsCollected": true, "paleOre": true, "royalCharmState": true,
+}
+
+// Difference is one value that differs between two saves. Before or After is
+// nil if the value only exists in one of them.
+type Difference struct {
+	Section string `json:"section"`
+	Path    string `json:"path"`
+	Before  any    `json:"before"`
+	After   any    `json:"after"`
+}
+
+// DiffJSON compares two decoded save documents, as returned by DecodeJSON,
+// value by value. Lists of scene items are matched by scene and ID, and lists
+// of names, such as the visited scenes, are compared as sets.
+func DiffJSON(before, after []byte) ([]Difference, error) {
+	var a, b any
+	if err := json.Unmarshal(before, &a); err != nil {
+		return nil, fmt.Errorf("invalid save JSON: %w", err)
+	}
+	if err := json.Unmarshal(after, &b); err != nil {
+		return nil, fmt.Errorf("invalid save JSON: %w", err)
+	}
+	flatA, flatB := make(map[string]any), make(map[string]any)
+	flatten("", a, flatA)
+	flatten("", b, flatB)
+
+	var diffs []Difference
+	for path, va := range flatA {
+		if vb, ok := flatB[path]; !ok || !reflect.DeepEqual(va, vb) {
+			diffs = append(diffs, Difference{Section: sectionOf(path), Path: displayPath(path), Before: va, After: vb})
+		}
+	}
+	for path, vb := range flatB {
+		if _, ok := flatA[path]; !ok {
+			diffs = append(diffs, Difference{Section: sectionOf(path), Path: displayPath(path), After: vb})
+		}
+	}
+	order := make(map[string]int)
+	for i, s := range Sections {
+		order[s] = i
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Section != diffs[j].Section {
+			return order[diffs[i].Section] < order[diffs[j].Section]
+		}
+		return diffs[i].Path < diffs[j].Path
+	})
+	return diffs, nil
+}
+
+// flatten records every leaf value of v under its dotted path.
+func flatten(path string, v any, out map[string]any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			flatten(join(path, k), child, out)
+		}
+	case []any:
+		switch {
+		case len(v) > 0 && allSceneItems(v):
+			for _, item := range v {
+				m := item.(map[string]any)
+				key := fmt.Sprintf("%s[%v/%v]", path, m["sceneName"], m["id"])
+				for k, child := range m {
+					if k != "sceneName" && k != "id" {
+						flatten(join(key, k), child, out)
+					}
+				}
+			}
+		case len(v) > 0 && allStrings(v):
+			for _, item := range v {
+				out[fmt.Sprintf("%s[%s]", path, item)] = true
+			}
+		default:
+			for i, item := range v {
+				flatten(fmt.Sprintf("%s[%d]", path, i), item, out)
+			}
+		}
+	default:
+		out[path] = v
+	}
+}
+
+func allSceneItems(items []any) bool {
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok || m["sceneName"] == nil || m["id"] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func allStrings(items []any) bool {
+	for _, item := range items {
+		if _, ok := item.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sectionOf groups a flattened path by what it describes.
+func sectionOf(path string) string {
+	field, ok := strings.CutPrefix(path, "playerData.")
+	if !ok {
+		return SectionScenes
+	}
+	name, _, _ := strings.Cut(field, "[")
+	name, _, _ = strings.Cut(name, ".")
+	lower := strings.ToLower(name)
+	switch {
+	case inventoryFields[name] || strings.HasPrefix(name, "has"):
+		return SectionInventory
+	case strings.Contains(lower, "charm"):
+		return SectionCharms
+	case isBossFlag(name) || strings.Contains(lower, "defeated"):
+		return SectionBosses
+	case strings.HasPrefix(name, "visited") || strings.HasPrefix(lower, "map") || strings.Contains(lower, "scenes"):
+		return SectionMap
+	}
+	return SectionPlayer
+}
+
+func isBossFlag(name string) bool {
+	for _, boss := range bossFlags {
+		if boss.flag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// displayPath drops the "playerData." prefix, which nearly every path has.
+func displayPath(path string) string {
+	return strings.TrimPrefix(path, "playerData.")
+}
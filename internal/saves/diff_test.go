@@ -0,0 +1,74 @@
+// /internal/saves/diff_test.go
+package saves
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []Difference
+	}{
+		{
+			name:   "identical",
+			before: `{"playerData":{"geo":1}}`,
+			after:  `{"playerData":{"geo":1}}`,
+		},
+		{
+			name:   "sections in display order",
+			before: `{"playerData":{"geo":1,"gotCharm_1":false,"killedFalseKnight":false,"playTime":10}}`,
+			after:  `{"playerData":{"geo":2,"gotCharm_1":true,"killedFalseKnight":true,"playTime":20}}`,
+			want: []Difference{
+				{SectionInventory, "geo", 1.0, 2.0},
+				{SectionCharms, "gotCharm_1", false, true},
+				{SectionBosses, "killedFalseKnight", false, true},
+				{SectionPlayer, "playTime", 10.0, 20.0},
+			},
+		},
+		{
+			name:   "added and removed fields",
+			before: `{"playerData":{"hasDash":true}}`,
+			after:  `{"playerData":{"hasWalljump":true}}`,
+			want: []Difference{
+				{SectionInventory, "hasDash", true, nil},
+				{SectionInventory, "hasWalljump", nil, true},
+			},
+		},
+		{
+			name:   "name lists compare as sets",
+			before: `{"playerData":{"scenesVisited":["Town","Crossroads_01"]}}`,
+			after:  `{"playerData":{"scenesVisited":["Crossroads_01","Town","Tutorial_01"]}}`,
+			want: []Difference{
+				{SectionMap, "scenesVisited[Tutorial_01]", nil, true},
+			},
+		},
+		{
+			name:   "scene items match by scene and id",
+			before: `{"sceneData":{"persistentBoolItems":[{"sceneName":"Town","id":"Chest","activated":false},{"sceneName":"Town","id":"Door","activated":true}]}}`,
+			after:  `{"sceneData":{"persistentBoolItems":[{"sceneName":"Town","id":"Door","activated":true},{"sceneName":"Town","id":"Chest","activated":true}]}}`,
+			want: []Difference{
+				{SectionScenes, "sceneData.persistentBoolItems[Town/Chest].activated", false, true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffJSON([]byte(tt.before), []byte(tt.after))
+			if err != nil {
+				t.Fatalf("DiffJSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffJSON =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffJSONInvalid(t *testing.T) {
+	if _, err := DiffJSON([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("DiffJSON accepted invalid JSON")
+	}
+}
@@ -0,0 +1,162 @@
+// /internal/saves/edit.go
+package saves
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Document is a decoded save document that can be edited and encoded again.
+// Numbers keep their original text, so untouched values are written back
+// exactly as the game wrote them.
+type Document map[string]any
+
+// ParseDocument parses the JSON returned by DecodeJSON.
+func ParseDocument(doc []byte) (Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var d Document
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("invalid save JSON: %w", err)
+	}
+	return d, nil
+}
+
+// Encode serialises the document and encodes it as a save file. The result
+// is decoded again before it is returned, so a save that the launcher can't
+// read back is never written.
+func (d Document) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+	data, err := EncodeJSON(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Decode(data); err != nil {
+		return nil, fmt.Errorf("edited save does not decode: %w", err)
+	}
+	return data, nil
+}
+
+// Set assigns value to the field at path, e.g. "geo" or
+// "sceneData.persistentBoolItems". Paths not starting with "playerData." or
+// "sceneData." refer to PlayerData. value is JSON, or a plain string. The
+// field must already exist and keep its type, so a typo can't add a field
+// the game ignores or turn a number into a string.
+func (d Document) Set(path, value string) error {
+	if !strings.HasPrefix(path, "playerData.") && !strings.HasPrefix(path, "sceneData.") {
+		path = "playerData." + path
+	}
+	keys := strings.Split(path, ".")
+	parent := map[string]any(d)
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := parent[key].(map[string]any)
+		if !ok {
+			return fmt.Errorf("'%s' is not a field of the save", path)
+		}
+		parent = child
+	}
+	last := keys[len(keys)-1]
+	old, ok := parent[last]
+	if !ok {
+		return fmt.Errorf("'%s' is not a field of the save", path)
+	}
+
+	var parsed any
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+	if err := dec.Decode(&parsed); err != nil || dec.More() {
+		parsed = value
+	}
+	if _, isString := old.(string); isString {
+		// "1.5" is a version string, not a number, if that's what was there.
+		parsed = value
+	}
+	if err := checkReplace(path, old, parsed); err != nil {
+		return err
+	}
+	parent[last] = parsed
+	return nil
+}
+
+// Merge applies a JSON merge patch (RFC 7396): objects are merged key by
+// key, null deletes a key and anything else replaces the value. As with Set,
+// every key must already exist and replaced values must keep their type.
+func (d Document) Merge(patch []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.UseNumber()
+	var p map[string]any
+	if err := dec.Decode(&p); err != nil {
+		return fmt.Errorf("invalid patch: %w", err)
+	}
+	return mergeInto(d, p, "")
+}
+
+func mergeInto(target, patch map[string]any, path string) error {
+	for key, value := range patch {
+		field := join(path, key)
+		old, exists := target[key]
+		if !exists {
+			return fmt.Errorf("'%s' is not a field of the save", field)
+		}
+		switch value := value.(type) {
+		case nil:
+			delete(target, key)
+		case map[string]any:
+			child, ok := old.(map[string]any)
+			if !ok {
+				return fmt.Errorf("'%s' is a %s, not an object", field, kindName(old))
+			}
+			if err := mergeInto(child, value, field); err != nil {
+				return err
+			}
+		default:
+			if err := checkReplace(field, old, value); err != nil {
+				return err
+			}
+			target[key] = value
+		}
+	}
+	return nil
+}
+
+// checkReplace makes sure a new value has the type of the old one, and that
+// whole numbers stay whole, since the game would fail to load the save.
+func checkReplace(path string, old, value any) error {
+	if kindName(old) != kindName(value) {
+		return fmt.Errorf("'%s' is a %s, not a %s", path, kindName(old), kindName(value))
+	}
+	oldNum, isNum := old.(json.Number)
+	newNum, _ := value.(json.Number)
+	if isNum && isWhole(oldNum) && !isWhole(newNum) {
+		return fmt.Errorf("'%s' must be a whole number, not %s", path, newNum)
+	}
+	return nil
+}
+
+func isWhole(n json.Number) bool {
+	_, err := n.Int64()
+	return err == nil
+}
+
+func kindName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	}
+	return "object"
+}
@@ -0,0 +1,132 @@
+// /internal/saves/progress.go
+package saves
+
+import "fmt"
+
+// soulPerVessel is the soul each soul vessel adds to MPReserveMax.
+const soulPerVessel = 33
+
+// charmNames are the charms in the order of the game's "gotCharm_N" flags.
+var charmNames = []string{
+	"Gathering Swarm", "Wayward Compass", "Grubsong", "Stalwart Shell", "Baldur Shell",
+	"Fury of the Fallen", "Quick Focus", "Lifeblood Heart", "Lifeblood Core", "Defender's Crest",
+	"Flukenest", "Thorns of Agony", "Mark of Pride", "Steady Body", "Heavy Blow",
+	"Sharp Shadow", "Spore Shroom", "Longnail", "Shaman Stone", "Soul Catcher",
+	"Soul Eater", "Glowing Womb", "Fragile Heart", "Fragile Greed", "Fragile Strength",
+	"Nailmaster's Glory", "Joni's Blessing", "Shape of Unn", "Hiveblood", "Dream Wielder",
+	"Dashmaster", "Quick Slash", "Spell Twister", "Deep Focus", "Grubberfly's Elegy",
+	"Kingsoul", "Sprintmaster", "Dreamshield", "Weaversong", "Grimmchild",
+}
+
+// bossFlags are the PlayerData flags set when a boss is defeated, in roughly
+// the order they are met.
+var bossFlags = []struct{ flag, name string }{
+	{"killedFalseKnight", "False Knight"},
+	{"killedBigFly", "Gruz Mother"},
+	{"killedBigBuzzer", "Vengefly King"},
+	{"killedMawlek", "Brooding Mawlek"},
+	{"killedHornet", "Hornet"},
+	{"killedMantisLord", "Mantis Lords"},
+	{"killedMageLord", "Soul Master"},
+	{"killedDungDefender", "Dung Defender"},
+	{"killedInfectedKnight", "Broken Vessel"},
+	{"killedMegaJellyfish", "Uumuu"},
+	{"killedBlackKnight", "Watcher Knights"},
+	{"killedMimicSpider", "Nosk"},
+	{"killedMegaBeamMiner", "Crystal Guardian"},
+	{"killedTraitorLord", "Traitor Lord"},
+	{"killedHiveKnight", "Hive Knight"},
+	{"killedFlukeMother", "Flukemarm"},
+	{"killedJarCollector", "The Collector"},
+	{"killedLobsterLancer", "God Tamer"},
+	{"killedGhostAladar", "Gorb"},
+	{"killedGhostXero", "Xero"},
+	{"killedGhostHu", "Elder Hu"},
+	{"killedGhostMarmu", "Marmu"},
+	{"killedGhostNoEyes", "No Eyes"},
+	{"killedGhostMarkoth", "Markoth"},
+	{"killedGhostGalien", "Galien"},
+	{"killedGrimm", "Troupe Master Grimm"},
+	{"killedNightmareGrimm", "Nightmare King Grimm"},
+	{"killedWhiteDefender", "White Defender"},
+	{"killedGreyPrince", "Grey Prince Zote"},
+	{"killedHollowKnight", "The Hollow Knight"},
+	{"killedFinalBoss", "The Radiance"},
+}
+
+// areaFlags are the PlayerData flags set when an area is first entered.
+var areaFlags = []struct{ flag, name string }{
+	{"visitedDirtmouth", "Dirtmouth"},
+	{"visitedCrossroads", "Forgotten Crossroads"},
+	{"visitedGreenpath", "Greenpath"},
+	{"visitedFungus", "Fungal Wastes"},
+	{"visitedFogCanyon", "Fog Canyon"},
+	{"visitedCliffs", "Howling Cliffs"},
+	{"visitedRuins", "City of Tears"},
+	{"visitedWaterways", "Royal Waterways"},
+	{"visitedMines", "Crystal Peak"},
+	{"visitedRestingGrounds", "Resting Grounds"},
+	{"visitedRoyalGardens", "Queen's Gardens"},
+	{"visitedDeepnest", "Deepnest"},
+	{"visitedOutskirts", "Kingdom's Edge"},
+	{"visitedHive", "The Hive"},
+	{"visitedAbyss", "Ancient Basin"},
+	{"visitedWhitePalace", "White Palace"},
+	{"visitedGodhome", "Godhome"},
+}
+
+// Changes is what changed in a slot between two versions of its save.
+type Changes struct {
+	// PlayTime is the in-game play time added, in seconds.
+	PlayTime float64 `json:"play_time"`
+	// Completion is the completion percentage added.
+	Completion float64 `json:"completion"`
+	Geo        int     `json:"geo"`
+	Masks      int     `json:"masks"`
+	Vessels    int     `json:"vessels"`
+	// Charms, Bosses and Areas are the charms obtained, bosses defeated and
+	// areas visited for the first time.
+	Charms []string `json:"charms,omitempty"`
+	Bosses []string `json:"bosses,omitempty"`
+	Areas  []string `json:"areas,omitempty"`
+}
+
+// Compare returns what changed from before to after. A nil before is a slot
+// that did not exist yet, so everything in after counts as new.
+func Compare(before, after *Save) Changes {
+	if before == nil {
+		before = &Save{}
+	}
+	if after == nil {
+		after = &Save{}
+	}
+	b, a := before.PlayerData, after.PlayerData
+	changes := Changes{
+		PlayTime:   a.PlayTime - b.PlayTime,
+		Completion: a.CompletionPercentage - b.CompletionPercentage,
+		Geo:        a.Geo - b.Geo,
+		Masks:      a.MaxHealthBase - b.MaxHealthBase,
+		Vessels:    a.MPReserveMax/soulPerVessel - b.MPReserveMax/soulPerVessel,
+	}
+	for i, name := range charmNames {
+		if flag := fmt.Sprintf("gotCharm_%d", i+1); gained(before, after, flag) {
+			changes.Charms = append(changes.Charms, name)
+		}
+	}
+	for _, boss := range bossFlags {
+		if gained(before, after, boss.flag) {
+			changes.Bosses = append(changes.Bosses, boss.name)
+		}
+	}
+	for _, area := range areaFlags {
+		if gained(before, after, area.flag) {
+			changes.Areas = append(changes.Areas, area.name)
+		}
+	}
+	return changes
+}
+
+// gained reports whether a flag is set in after but not in before.
+func gained(before, after *Save, flag string) bool {
+	return after.flags[flag] && !before.flags[flag]
+}
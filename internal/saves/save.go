@@ -0,0 +1,179 @@
+// /internal/saves/save.go
+package saves
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// saveKey is the AES key the game uses for every PC save file.
+var saveKey = []byte("UKu52ePUBwetZ9wNX88o54dnfKRu0T1l")
+
+// fileHeader is the .NET BinaryFormatter preamble the game writes in front of
+// the encrypted payload: a stream header followed by a string record.
+var fileHeader = []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x01, 0x00, 0x00, 0x00}
+
+// endOfMessage is the BinaryFormatter record that ends a save file.
+const endOfMessage = 0x0B
+
+// Save is a decoded save slot.
+type Save struct {
+	PlayerData PlayerData `json:"playerData"`
+	// flags holds every boolean field of the game's PlayerData, such as
+	// "gotCharm_1" or "killedFalseKnight".
+	flags map[string]bool
+}
+
+// PlayerData holds the fields of the game's PlayerData the launcher cares about.
+type PlayerData struct {
+	Version              string  `json:"version"`
+	PlayTime             float64 `json:"playTime"`
+	Geo                  int     `json:"geo"`
+	CompletionPercentage float64 `json:"completionPercentage"`
+	// MaxHealthBase is the number of masks, without charm bonuses.
+	MaxHealthBase int `json:"maxHealthBase"`
+	// MPReserveMax is the soul held by the soul vessels, 33 per vessel.
+	MPReserveMax int `json:"MPReserveMax"`
+}
+
+// Load reads and decodes a save file.
+func Load(path string) (*Save, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	save, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode save %s: %w", path, err)
+	}
+	return save, nil
+}
+
+// Decode parses the contents of a save file.
+func Decode(data []byte) (*Save, error) {
+	plain, err := DecodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	var save Save
+	if err := json.Unmarshal(plain, &save); err != nil {
+		return nil, fmt.Errorf("invalid save JSON: %w", err)
+	}
+	var raw struct {
+		PlayerData map[string]json.RawMessage `json:"playerData"`
+	}
+	if err := json.Unmarshal(plain, &raw); err != nil {
+		return nil, fmt.Errorf("invalid save JSON: %w", err)
+	}
+	save.flags = make(map[string]bool)
+	for name, value := range raw.PlayerData {
+		var flag bool
+		if json.Unmarshal(value, &flag) == nil {
+			save.flags[name] = flag
+		}
+	}
+	return &save, nil
+}
+
+// DecodeJSON strips the file framing and encryption from a save file and
+// returns the JSON document inside it.
+func DecodeJSON(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, fileHeader) {
+		return nil, errors.New("not a Hollow Knight save file (unexpected header)")
+	}
+	data = data[len(fileHeader):]
+
+	// The payload is a .NET length-prefixed string: a 7-bit encoded length
+	// followed by that many bytes, then a one byte end-of-message record.
+	length, n := readLength(data)
+	if n == 0 || n+length > len(data) {
+		return nil, errors.New("truncated save file")
+	}
+	encoded := data[n : n+length]
+
+	encrypted, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid save payload: %w", err)
+	}
+	return decryptECB(encrypted)
+}
+
+// EncodeJSON is the inverse of DecodeJSON: it encrypts a JSON document and
+// frames it the way the game writes save files.
+func EncodeJSON(doc []byte) ([]byte, error) {
+	encrypted, err := encryptECB(doc)
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(encrypted)
+
+	out := bytes.NewBuffer(append([]byte{}, fileHeader...))
+	for n := len(encoded); ; n >>= 7 {
+		if n < 0x80 {
+			out.WriteByte(byte(n))
+			break
+		}
+		out.WriteByte(byte(n&0x7F | 0x80))
+	}
+	out.WriteString(encoded)
+	out.WriteByte(endOfMessage)
+	return out.Bytes(), nil
+}
+
+func readLength(data []byte) (length, n int) {
+	for shift := 0; n < len(data) && shift < 35; shift += 7 {
+		b := data[n]
+		n++
+		length |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return length, n
+		}
+	}
+	return 0, 0
+}
+
+func encryptECB(data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(saveKey)
+	if err != nil {
+		return nil, err
+	}
+	size := block.BlockSize()
+	pad := size - len(data)%size
+	plain := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	encrypted := make([]byte, len(plain))
+	for i := 0; i < len(plain); i += size {
+		block.Encrypt(encrypted[i:i+size], plain[i:i+size])
+	}
+	return encrypted, nil
+}
+
+func decryptECB(data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(saveKey)
+	if err != nil {
+		return nil, err
+	}
+	size := block.BlockSize()
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, errors.New("encrypted payload is not a whole number of blocks")
+	}
+	plain := make([]byte, len(data))
+	for i := 0; i < len(data); i += size {
+		block.Decrypt(plain[i:i+size], data[i:i+size])
+	}
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > size || pad > len(plain) {
+		return nil, errors.New("invalid padding in decrypted save")
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("invalid padding in decrypted save")
+		}
+	}
+	return plain[:len(plain)-pad], nil
+}
@@ -0,0 +1,224 @@
+// /internal/saves/save_test.go
+package saves
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDoc = `{"playerData":{"version":"1.5.78.11833","playTime":3600.5,"geo":120,"completionPercentage":12.5,"maxHealthBase":6,"MPReserveMax":66,"gotCharm_1":true,"gotCharm_2":false,"scenesVisited":["Town","Crossroads_01"]},"sceneData":{"persistentBoolItems":[{"sceneName":"Town","id":"Chest","activated":true}]}}`
+
+func TestEncodeDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"empty object", `{}`},
+		{"block sized", `{"playerData":{"a":"0123456789ab"}}`},
+		{"save", testDoc},
+		// Long enough that the payload length takes more than one byte.
+		{"long", `{"playerData":{"pad":"` + strings.Repeat("x", 300) + `"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeJSON([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("EncodeJSON: %v", err)
+			}
+			if !bytes.HasPrefix(data, fileHeader) || data[len(data)-1] != endOfMessage {
+				t.Error("encoded save is not framed like a game save")
+			}
+			got, err := DecodeJSON(data)
+			if err != nil {
+				t.Fatalf("DecodeJSON: %v", err)
+			}
+			if string(got) != tt.doc {
+				t.Errorf("round trip = %s, want %s", got, tt.doc)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data, err := EncodeJSON([]byte(testDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	save, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	p := save.PlayerData
+	if p.Version != "1.5.78.11833" || p.PlayTime != 3600.5 || p.Geo != 120 || p.MaxHealthBase != 6 || p.MPReserveMax != 66 {
+		t.Errorf("PlayerData = %+v", p)
+	}
+	if !save.flags["gotCharm_1"] || save.flags["gotCharm_2"] {
+		t.Errorf("flags = %v", save.flags)
+	}
+	if s := Summarise(save); s.Charms != 1 || s.Vessels != 2 {
+		t.Errorf("Summarise = %+v, want 1 charm and 2 vessels", s)
+	}
+}
+
+// fixtureDoc is the JSON inside testdata/user1.dat, which was encrypted and
+// framed with openssl rather than EncodeJSON, the way the game does.
+const fixtureDoc = `{"playerData":{"version":"1.5.78.11833","playTime":5025.75,"geo":873,"completionPercentage":34,"maxHealthBase":7,"MPReserveMax":33,"gotCharm_2":true,"gotCharm_3":true,"killedFalseKnight":true},"sceneData":{}}`
+
+func TestDecodeFixture(t *testing.T) {
+	path := filepath.Join("testdata", "user1.dat")
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := DecodeJSON(fixture)
+	if err != nil {
+		t.Fatalf("DecodeJSON: %v", err)
+	}
+	if string(plain) != fixtureDoc {
+		t.Errorf("DecodeJSON = %s, want %s", plain, fixtureDoc)
+	}
+	encoded, err := EncodeJSON(plain)
+	if err != nil {
+		t.Fatalf("EncodeJSON: %v", err)
+	}
+	if !bytes.Equal(encoded, fixture) {
+		t.Error("re-encoding the fixture does not give back the same bytes")
+	}
+
+	save, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := PlayerData{Version: "1.5.78.11833", PlayTime: 5025.75, Geo: 873, CompletionPercentage: 34, MaxHealthBase: 7, MPReserveMax: 33}
+	if save.PlayerData != want {
+		t.Errorf("PlayerData = %+v, want %+v", save.PlayerData, want)
+	}
+	if s := Summarise(save); s.Charms != 2 || s.Vessels != 1 {
+		t.Errorf("Summarise = %+v, want 2 charms and 1 vessel", s)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	valid, err := EncodeJSON([]byte(testDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"wrong header", append([]byte{0x01}, valid[1:]...)},
+		{"truncated", valid[:len(valid)/2]},
+		{"bad base64", append(append([]byte{}, fileHeader...), 4, '!', '!', '!', '!', endOfMessage)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); err == nil {
+				t.Error("Decode succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestDocumentEdit(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     map[string]string
+		patch   string
+		wantErr string
+		check   func(t *testing.T, s *Save)
+	}{
+		{
+			name: "set number",
+			set:  map[string]string{"geo": "9999"},
+			check: func(t *testing.T, s *Save) {
+				if s.PlayerData.Geo != 9999 {
+					t.Errorf("geo = %d, want 9999", s.PlayerData.Geo)
+				}
+			},
+		},
+		{
+			name: "set version string",
+			set:  map[string]string{"playerData.version": "1.5"},
+			check: func(t *testing.T, s *Save) {
+				if s.PlayerData.Version != "1.5" {
+					t.Errorf("version = %q, want %q", s.PlayerData.Version, "1.5")
+				}
+			},
+		},
+		{name: "set unknown field", set: map[string]string{"geoo": "1"}, wantErr: "not a field"},
+		{name: "set changes type", set: map[string]string{"geo": "lots"}, wantErr: "is a number, not a string"},
+		{name: "set fraction", set: map[string]string{"geo": "1.5"}, wantErr: "whole number"},
+		{
+			name:  "merge",
+			patch: `{"playerData":{"geo":5,"gotCharm_2":true}}`,
+			check: func(t *testing.T, s *Save) {
+				if s.PlayerData.Geo != 5 || !s.flags["gotCharm_2"] {
+					t.Errorf("geo = %d, gotCharm_2 = %v", s.PlayerData.Geo, s.flags["gotCharm_2"])
+				}
+			},
+		},
+		{name: "merge unknown key", patch: `{"playerData":{"gotCharm_99":true}}`, wantErr: "'playerData.gotCharm_99' is not a field"},
+		{name: "merge unknown section", patch: `{"extra":{}}`, wantErr: "'extra' is not a field"},
+		{name: "merge into a value", patch: `{"playerData":{"geo":{"x":1}}}`, wantErr: "not an object"},
+		{name: "merge invalid", patch: `[1]`, wantErr: "invalid patch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseDocument([]byte(testDoc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for path, value := range tt.set {
+				if err = doc.Set(path, value); err != nil {
+					break
+				}
+			}
+			if err == nil && tt.patch != "" {
+				err = doc.Merge([]byte(tt.patch))
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := doc.Encode()
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			save, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, save)
+		})
+	}
+}
+
+func TestDocumentEncodeKeepsNumbers(t *testing.T) {
+	doc, err := ParseDocument([]byte(testDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := doc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, number := range []string{`"playTime":3600.5`, `"completionPercentage":12.5`} {
+		if !bytes.Contains(plain, []byte(number)) {
+			t.Errorf("re-encoded save lost %s: %s", number, plain)
+		}
+	}
+}
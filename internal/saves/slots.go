@@ -0,0 +1,91 @@
+// /internal/saves/slots.go
+package saves
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// slotFilePattern matches a slot's main file and its companions, such as
+// "user1.dat", "user1.dat.bak1" or "user1_1.4.3.2.dat".
+var slotFilePattern = regexp.MustCompile(`^user(\d+)(?:[._].*)?$`)
+
+// SlotOf returns the slot number a save directory entry belongs to.
+func SlotOf(name string) (int, bool) {
+	m := slotFilePattern.FindStringSubmatch(name)
+	if m == nil {
+		return 0, false
+	}
+	slot, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return slot, true
+}
+
+// SlotFileName returns the name of a slot's main save file.
+func SlotFileName(slot int) string {
+	return fmt.Sprintf("user%d.dat", slot)
+}
+
+// Slots returns the slot numbers that have a main save file in dir, in order.
+func Slots(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var slots []int
+	for _, e := range entries {
+		if slot, ok := SlotOf(e.Name()); ok && !e.IsDir() && e.Name() == SlotFileName(slot) {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Ints(slots)
+	return slots, nil
+}
+
+// LoadSlots decodes the main save file of every slot in dir. Slots that
+// cannot be decoded are reported in the returned error map instead.
+func LoadSlots(dir string) (map[int]*Save, map[int]error) {
+	loaded := make(map[int]*Save)
+	failed := make(map[int]error)
+	slots, err := Slots(dir)
+	if err != nil {
+		failed[0] = err
+		return loaded, failed
+	}
+	for _, slot := range slots {
+		save, err := Load(filepath.Join(dir, SlotFileName(slot)))
+		if err != nil {
+			failed[slot] = err
+			continue
+		}
+		loaded[slot] = save
+	}
+	return loaded, failed
+}
+
+// SlotFiles returns the names of every file in dir that belongs to slot.
+func SlotFiles(dir string, slot int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if s, ok := SlotOf(e.Name()); ok && s == slot && !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
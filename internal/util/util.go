@@ -41,6 +41,41 @@ func PathExists(path string) bool {
 	return !os.IsNotExist(err)
 }
 func CopyDir(src, dst string) error {
+	return copyDir(src, dst, nil)
+}
+
+// CopyProgress receives the running totals of a CopyDirWithProgress call.
+type CopyProgress func(bytes, totalBytes, files, totalFiles int64)
+
+// CopyDirWithProgress copies like CopyDir and reports progress after every file.
+func CopyDirWithProgress(src, dst string, progress CopyProgress) error {
+	var totalBytes, totalFiles int64
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		totalBytes += info.Size()
+		totalFiles++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var bytes, files int64
+	progress(0, totalBytes, 0, totalFiles)
+	return copyDir(src, dst, func(size int64) {
+		bytes += size
+		files++
+		progress(bytes, totalBytes, files, totalFiles)
+	})
+}
+
+func copyDir(src, dst string, copied func(size int64)) error {
 	srcInfo, err := os.Stat(src)
 	if err != nil {
 		return err
@@ -56,28 +91,45 @@ func CopyDir(src, dst string) error {
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 		if entry.IsDir() {
-			if err := CopyDir(srcPath, dstPath); err != nil {
+			if err := copyDir(srcPath, dstPath, copied); err != nil {
 				return err
 			}
 		} else {
-			if err := copyFile(srcPath, dstPath); err != nil {
+			if err := CopyFile(srcPath, dstPath); err != nil {
 				return err
 			}
+			if copied != nil {
+				if info, err := entry.Info(); err == nil {
+					copied(info.Size())
+				}
+			}
 		}
 	}
 	return nil
 }
-func copyFile(src, dst string) error {
+
+// CopyFile copies a single file, keeping its modification time so that
+// copies do not look newer than the saves they were made from.
+func CopyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
 	out, err := os.Create(dst)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-	_, err = io.Copy(out, in)
-	return err
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
 }